@@ -1,6 +1,9 @@
 package ast
 
-import "strings"
+import (
+	"strconv"
+	"strings"
+)
 
 // Statement is the base interface for all AST statements
 type Statement interface {
@@ -20,10 +23,68 @@ type DMLStatement interface {
 	DMLStatement()
 }
 
+// DDLStatement represents data definition statements
+type DDLStatement interface {
+	Statement
+	DDLStatement()
+}
+
+// TransactionStatement represents statements that start or end a transaction
+type TransactionStatement interface {
+	Statement
+	TransactionStatement()
+}
+
 // SELECTQueryStatement represents a SELECT query
 type SELECTQueryStatement struct {
-	Fields []string // Column names to select (or "*" for all)
-	Table  string   // Table name to select from
+	Fields  []Expression  // Columns ("*" for all) and aggregates to select
+	Table   string        // Table name to select from
+	Joins   []JoinClause  // Tables joined to Table (optional)
+	Where   Expression    // WHERE clause predicate (optional)
+	GroupBy []string      // GROUP BY columns (optional)
+	Having  Expression    // HAVING clause predicate (optional)
+	OrderBy []OrderByItem // ORDER BY columns (optional)
+	Limit   *int          // LIMIT row count (optional)
+	Offset  *int          // OFFSET row count (optional)
+}
+
+// JoinType is the kind of a JOIN
+type JoinType string
+
+const (
+	InnerJoin JoinType = "INNER"
+	LeftJoin  JoinType = "LEFT"
+	CrossJoin JoinType = "CROSS" // Comma join; rows are filtered by WHERE
+)
+
+// JoinClause joins another table to the rows selected so far
+type JoinClause struct {
+	Type  JoinType
+	Table string
+	On    Expression // Join condition; nil for comma joins
+}
+
+// String returns a string representation of the join clause
+func (j JoinClause) String() string {
+	if j.Type == CrossJoin {
+		return ", " + j.Table
+	}
+	return " " + string(j.Type) + " JOIN " + j.Table + " ON " + j.On.String()
+}
+
+// OrderByItem is a single ORDER BY column and its direction. Column may name
+// an aggregate result such as "COUNT(*)".
+type OrderByItem struct {
+	Column     string
+	Descending bool
+}
+
+// String returns a string representation of the ORDER BY item
+func (o OrderByItem) String() string {
+	if o.Descending {
+		return o.Column + " DESC"
+	}
+	return o.Column + " ASC"
 }
 
 // Statement implements the Statement interface
@@ -34,23 +95,77 @@ func (s *SELECTQueryStatement) QueryStatement() {}
 
 // String returns a string representation of the SELECT statement
 func (s *SELECTQueryStatement) String() string {
-	fields := strings.Join(s.Fields, ", ")
-	return "SELECT " + fields + " FROM " + s.Table
+	fields := make([]string, len(s.Fields))
+	for i, field := range s.Fields {
+		fields[i] = field.String()
+	}
+	result := "SELECT " + strings.Join(fields, ", ") + " FROM " + s.Table
+
+	for _, join := range s.Joins {
+		result += join.String()
+	}
+
+	if s.Where != nil {
+		result += " WHERE " + s.Where.String()
+	}
+
+	if len(s.GroupBy) > 0 {
+		result += " GROUP BY " + strings.Join(s.GroupBy, ", ")
+	}
+
+	if s.Having != nil {
+		result += " HAVING " + s.Having.String()
+	}
+
+	if len(s.OrderBy) > 0 {
+		items := make([]string, len(s.OrderBy))
+		for i, item := range s.OrderBy {
+			items[i] = item.String()
+		}
+		result += " ORDER BY " + strings.Join(items, ", ")
+	}
+
+	if s.Limit != nil {
+		result += " LIMIT " + strconv.Itoa(*s.Limit)
+	}
+
+	if s.Offset != nil {
+		result += " OFFSET " + strconv.Itoa(*s.Offset)
+	}
+
+	return result
+}
+
+// HasAggregates reports whether the query aggregates rows, either through
+// GROUP BY or an aggregate function in the select list
+func (s *SELECTQueryStatement) HasAggregates() bool {
+	if len(s.GroupBy) > 0 {
+		return true
+	}
+	for _, field := range s.Fields {
+		if _, ok := field.(*AggregateExpression); ok {
+			return true
+		}
+	}
+	return false
 }
 
 // NewSELECTQueryStatement creates a new SELECT query statement
-func NewSELECTQueryStatement(fields []string, table string) *SELECTQueryStatement {
+func NewSELECTQueryStatement(fields []Expression, table string, where Expression) *SELECTQueryStatement {
 	return &SELECTQueryStatement{
 		Fields: fields,
 		Table:  table,
+		Where:  where,
 	}
 }
 
-// INSERTStatement represents an INSERT INTO statement
+// INSERTStatement represents an INSERT INTO statement. Rows come either
+// from a VALUES list or from a SELECT query.
 type INSERTStatement struct {
-	Table   string   // Table name
-	Columns []string // Column names (optional)
-	Values  []string // Values to insert
+	Table   string                // Table name
+	Columns []string              // Column names (optional)
+	Rows    [][]*Literal          // Value tuples to insert
+	Select  *SELECTQueryStatement // Query supplying the rows (optional)
 }
 
 // Statement implements the Statement interface
@@ -65,25 +180,45 @@ func (i *INSERTStatement) String() string {
 	if len(i.Columns) > 0 {
 		result += " (" + strings.Join(i.Columns, ", ") + ")"
 	}
-	result += " VALUES (" + strings.Join(i.Values, ", ") + ")"
-	return result
+
+	if i.Select != nil {
+		return result + " " + i.Select.String()
+	}
+
+	tuples := make([]string, len(i.Rows))
+	for n, row := range i.Rows {
+		values := make([]string, len(row))
+		for k, value := range row {
+			values[k] = value.String()
+		}
+		tuples[n] = "(" + strings.Join(values, ", ") + ")"
+	}
+	return result + " VALUES " + strings.Join(tuples, ", ")
 }
 
-// NewINSERTStatement creates a new INSERT statement
-func NewINSERTStatement(table string, columns []string, values []string) *INSERTStatement {
+// NewINSERTStatement creates a new INSERT ... VALUES statement
+func NewINSERTStatement(table string, columns []string, rows [][]*Literal) *INSERTStatement {
 	return &INSERTStatement{
 		Table:   table,
 		Columns: columns,
-		Values:  values,
+		Rows:    rows,
+	}
+}
+
+// NewINSERTSelectStatement creates a new INSERT ... SELECT statement
+func NewINSERTSelectStatement(table string, columns []string, query *SELECTQueryStatement) *INSERTStatement {
+	return &INSERTStatement{
+		Table:   table,
+		Columns: columns,
+		Select:  query,
 	}
 }
 
 // UPDATEStatement represents an UPDATE statement
 type UPDATEStatement struct {
-	Table       string            // Table name
-	Assignments map[string]string // Column = Value pairs
-	WhereColumn string            // WHERE clause column (optional)
-	WhereValue  string            // WHERE clause value (optional)
+	Table       string              // Table name
+	Assignments map[string]*Literal // Column = Value pairs
+	Where       Expression          // WHERE clause predicate (optional)
 }
 
 // Statement implements the Statement interface
@@ -98,32 +233,30 @@ func (u *UPDATEStatement) String() string {
 
 	assignments := make([]string, 0, len(u.Assignments))
 	for col, val := range u.Assignments {
-		assignments = append(assignments, col+" = "+val)
+		assignments = append(assignments, col+" = "+val.String())
 	}
 	result += strings.Join(assignments, ", ")
 
-	if u.WhereColumn != "" {
-		result += " WHERE " + u.WhereColumn + " = " + u.WhereValue
+	if u.Where != nil {
+		result += " WHERE " + u.Where.String()
 	}
 
 	return result
 }
 
 // NewUPDATEStatement creates a new UPDATE statement
-func NewUPDATEStatement(table string, assignments map[string]string, whereCol string, whereVal string) *UPDATEStatement {
+func NewUPDATEStatement(table string, assignments map[string]*Literal, where Expression) *UPDATEStatement {
 	return &UPDATEStatement{
 		Table:       table,
 		Assignments: assignments,
-		WhereColumn: whereCol,
-		WhereValue:  whereVal,
+		Where:       where,
 	}
 }
 
 // DELETEStatement represents a DELETE FROM statement
 type DELETEStatement struct {
-	Table       string // Table name
-	WhereColumn string // WHERE clause column (optional)
-	WhereValue  string // WHERE clause value (optional)
+	Table string     // Table name
+	Where Expression // WHERE clause predicate (optional)
 }
 
 // Statement implements the Statement interface
@@ -136,22 +269,489 @@ func (d *DELETEStatement) DMLStatement() {}
 func (d *DELETEStatement) String() string {
 	result := "DELETE FROM " + d.Table
 
-	if d.WhereColumn != "" {
-		result += " WHERE " + d.WhereColumn + " = " + d.WhereValue
+	if d.Where != nil {
+		result += " WHERE " + d.Where.String()
 	}
 
 	return result
 }
 
 // NewDELETEStatement creates a new DELETE statement
-func NewDELETEStatement(table string, whereCol string, whereVal string) *DELETEStatement {
+func NewDELETEStatement(table string, where Expression) *DELETEStatement {
 	return &DELETEStatement{
+		Table: table,
+		Where: where,
+	}
+}
+
+// ColumnType is the declared type of a column
+type ColumnType string
+
+const (
+	UntypedColumn   ColumnType = "" // Accepts any value, as before types existed
+	IntColumn       ColumnType = "INT"
+	FloatColumn     ColumnType = "FLOAT"
+	TextColumn      ColumnType = "TEXT"
+	BoolColumn      ColumnType = "BOOL"
+	DateColumn      ColumnType = "DATE"
+	TimestampColumn ColumnType = "TIMESTAMP"
+)
+
+// ParseColumnType maps a type name, including common aliases such as
+// INTEGER or VARCHAR, to its ColumnType
+func ParseColumnType(name string) (ColumnType, bool) {
+	switch strings.ToUpper(name) {
+	case "INT", "INTEGER", "BIGINT":
+		return IntColumn, true
+	case "FLOAT", "REAL", "DOUBLE", "NUMERIC", "DECIMAL":
+		return FloatColumn, true
+	case "TEXT", "VARCHAR", "CHAR", "STRING":
+		return TextColumn, true
+	case "BOOL", "BOOLEAN":
+		return BoolColumn, true
+	case "DATE":
+		return DateColumn, true
+	case "TIMESTAMP", "DATETIME":
+		return TimestampColumn, true
+	default:
+		return UntypedColumn, false
+	}
+}
+
+// ColumnDefinition is a column in a CREATE TABLE statement, with any
+// constraints written inline after its type
+type ColumnDefinition struct {
+	Name       string
+	Type       ColumnType // UntypedColumn when no type was given
+	PrimaryKey bool
+	Unique     bool
+	NotNull    bool
+	Default    *Literal    // nil when the column has no DEFAULT
+	Check      Expression  // nil when the column has no CHECK
+	References *ForeignKey // nil when the column has no REFERENCES
+}
+
+// String returns a string representation of the column definition
+func (c ColumnDefinition) String() string {
+	parts := []string{c.Name}
+	if c.Type != UntypedColumn {
+		parts = append(parts, string(c.Type))
+	}
+	if c.PrimaryKey {
+		parts = append(parts, "PRIMARY KEY")
+	}
+	if c.Unique {
+		parts = append(parts, "UNIQUE")
+	}
+	if c.NotNull {
+		parts = append(parts, "NOT NULL")
+	}
+	if c.Default != nil {
+		parts = append(parts, "DEFAULT "+c.Default.String())
+	}
+	if c.Check != nil {
+		parts = append(parts, "CHECK ("+c.Check.String()+")")
+	}
+	if c.References != nil {
+		parts = append(parts, c.References.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+// ReferentialAction is what happens to referencing rows when the row they
+// reference is deleted
+type ReferentialAction string
+
+const (
+	RestrictAction ReferentialAction = "RESTRICT"
+	CascadeAction  ReferentialAction = "CASCADE"
+	SetNullAction  ReferentialAction = "SET NULL"
+)
+
+// ForeignKey is the REFERENCES part of a foreign key: the referenced table,
+// its columns, and the ON DELETE action
+type ForeignKey struct {
+	Table    string
+	Columns  []string // Empty to reference the primary key
+	OnDelete ReferentialAction
+}
+
+// String returns a string representation of the foreign key reference
+func (f *ForeignKey) String() string {
+	out := "REFERENCES " + f.Table
+	if len(f.Columns) > 0 {
+		out += " (" + strings.Join(f.Columns, ", ") + ")"
+	}
+	return out + " ON DELETE " + string(f.OnDelete)
+}
+
+// ConstraintKind is the kind of a table constraint
+type ConstraintKind int
+
+const (
+	PrimaryKeyConstraint ConstraintKind = iota
+	UniqueConstraint
+	CheckConstraint
+	ForeignKeyConstraint
+)
+
+// TableConstraint is a constraint listed among the columns of a CREATE
+// TABLE statement, such as PRIMARY KEY (a, b) or CONSTRAINT positive
+// CHECK (price > 0)
+type TableConstraint struct {
+	Name       string // Empty unless given with CONSTRAINT name
+	Kind       ConstraintKind
+	Columns    []string    // Key columns for PRIMARY KEY, UNIQUE and FOREIGN KEY
+	Check      Expression  // Condition for CHECK
+	References *ForeignKey // Referenced table for FOREIGN KEY
+}
+
+// String returns a string representation of the table constraint
+func (c TableConstraint) String() string {
+	var out string
+	if c.Name != "" {
+		out = "CONSTRAINT " + c.Name + " "
+	}
+	switch c.Kind {
+	case PrimaryKeyConstraint:
+		out += "PRIMARY KEY (" + strings.Join(c.Columns, ", ") + ")"
+	case UniqueConstraint:
+		out += "UNIQUE (" + strings.Join(c.Columns, ", ") + ")"
+	case CheckConstraint:
+		out += "CHECK (" + c.Check.String() + ")"
+	case ForeignKeyConstraint:
+		out += "FOREIGN KEY (" + strings.Join(c.Columns, ", ") + ") " + c.References.String()
+	}
+	return out
+}
+
+// CREATETableStatement represents a CREATE TABLE statement
+type CREATETableStatement struct {
+	Table       string             // Table name
+	Columns     []ColumnDefinition // Column names, types and inline constraints
+	Constraints []TableConstraint  // Table-level constraints
+}
+
+// Statement implements the Statement interface
+func (c *CREATETableStatement) Statement() {}
+
+// DDLStatement implements the DDLStatement interface
+func (c *CREATETableStatement) DDLStatement() {}
+
+// String returns a string representation of the CREATE TABLE statement
+func (c *CREATETableStatement) String() string {
+	parts := make([]string, 0, len(c.Columns)+len(c.Constraints))
+	for _, col := range c.Columns {
+		parts = append(parts, col.String())
+	}
+	for _, constraint := range c.Constraints {
+		parts = append(parts, constraint.String())
+	}
+	return "CREATE TABLE " + c.Table + " (" + strings.Join(parts, ", ") + ")"
+}
+
+// NewCREATETableStatement creates a new CREATE TABLE statement
+func NewCREATETableStatement(table string, columns []ColumnDefinition, constraints []TableConstraint) *CREATETableStatement {
+	return &CREATETableStatement{
 		Table:       table,
-		WhereColumn: whereCol,
-		WhereValue:  whereVal,
+		Columns:     columns,
+		Constraints: constraints,
 	}
 }
 
+// DROPTableStatement represents a DROP TABLE statement
+type DROPTableStatement struct {
+	Table    string // Table name
+	IfExists bool   // Do not fail when the table is missing
+}
+
+// Statement implements the Statement interface
+func (d *DROPTableStatement) Statement() {}
+
+// DDLStatement implements the DDLStatement interface
+func (d *DROPTableStatement) DDLStatement() {}
+
+// String returns a string representation of the DROP TABLE statement
+func (d *DROPTableStatement) String() string {
+	result := "DROP TABLE "
+	if d.IfExists {
+		result += "IF EXISTS "
+	}
+	return result + d.Table
+}
+
+// NewDROPTableStatement creates a new DROP TABLE statement
+func NewDROPTableStatement(table string, ifExists bool) *DROPTableStatement {
+	return &DROPTableStatement{
+		Table:    table,
+		IfExists: ifExists,
+	}
+}
+
+// TRUNCATETableStatement represents a TRUNCATE TABLE statement
+type TRUNCATETableStatement struct {
+	Table string // Table name
+}
+
+// Statement implements the Statement interface
+func (t *TRUNCATETableStatement) Statement() {}
+
+// DDLStatement implements the DDLStatement interface
+func (t *TRUNCATETableStatement) DDLStatement() {}
+
+// String returns a string representation of the TRUNCATE TABLE statement
+func (t *TRUNCATETableStatement) String() string {
+	return "TRUNCATE TABLE " + t.Table
+}
+
+// NewTRUNCATETableStatement creates a new TRUNCATE TABLE statement
+func NewTRUNCATETableStatement(table string) *TRUNCATETableStatement {
+	return &TRUNCATETableStatement{
+		Table: table,
+	}
+}
+
+// CREATEIndexStatement represents a CREATE [UNIQUE] INDEX statement
+type CREATEIndexStatement struct {
+	Name    string   // Index name
+	Table   string   // Table name
+	Columns []string // Indexed columns, the first one leading
+	Unique  bool     // Reject rows that repeat an existing key
+}
+
+// Statement implements the Statement interface
+func (c *CREATEIndexStatement) Statement() {}
+
+// DDLStatement implements the DDLStatement interface
+func (c *CREATEIndexStatement) DDLStatement() {}
+
+// String returns a string representation of the CREATE INDEX statement
+func (c *CREATEIndexStatement) String() string {
+	result := "CREATE "
+	if c.Unique {
+		result += "UNIQUE "
+	}
+	return result + "INDEX " + c.Name + " ON " + c.Table + " (" + strings.Join(c.Columns, ", ") + ")"
+}
+
+// NewCREATEIndexStatement creates a new CREATE INDEX statement
+func NewCREATEIndexStatement(name, table string, columns []string, unique bool) *CREATEIndexStatement {
+	return &CREATEIndexStatement{
+		Name:    name,
+		Table:   table,
+		Columns: columns,
+		Unique:  unique,
+	}
+}
+
+// DROPIndexStatement represents a DROP INDEX statement
+type DROPIndexStatement struct {
+	Name     string // Index name
+	IfExists bool   // Do not fail when the index is missing
+}
+
+// Statement implements the Statement interface
+func (d *DROPIndexStatement) Statement() {}
+
+// DDLStatement implements the DDLStatement interface
+func (d *DROPIndexStatement) DDLStatement() {}
+
+// String returns a string representation of the DROP INDEX statement
+func (d *DROPIndexStatement) String() string {
+	result := "DROP INDEX "
+	if d.IfExists {
+		result += "IF EXISTS "
+	}
+	return result + d.Name
+}
+
+// NewDROPIndexStatement creates a new DROP INDEX statement
+func NewDROPIndexStatement(name string, ifExists bool) *DROPIndexStatement {
+	return &DROPIndexStatement{
+		Name:     name,
+		IfExists: ifExists,
+	}
+}
+
+// SHOWTablesStatement represents a SHOW TABLES statement
+type SHOWTablesStatement struct{}
+
+// Statement implements the Statement interface
+func (s *SHOWTablesStatement) Statement() {}
+
+// QueryStatement implements the QueryStatement interface
+func (s *SHOWTablesStatement) QueryStatement() {}
+
+// String returns a string representation of the SHOW TABLES statement
+func (s *SHOWTablesStatement) String() string {
+	return "SHOW TABLES"
+}
+
+// NewSHOWTablesStatement creates a new SHOW TABLES statement
+func NewSHOWTablesStatement() *SHOWTablesStatement {
+	return &SHOWTablesStatement{}
+}
+
+// DESCRIBETableStatement represents a DESCRIBE statement
+type DESCRIBETableStatement struct {
+	Table string // Table name
+}
+
+// Statement implements the Statement interface
+func (d *DESCRIBETableStatement) Statement() {}
+
+// QueryStatement implements the QueryStatement interface
+func (d *DESCRIBETableStatement) QueryStatement() {}
+
+// String returns a string representation of the DESCRIBE statement
+func (d *DESCRIBETableStatement) String() string {
+	return "DESCRIBE " + d.Table
+}
+
+// NewDESCRIBETableStatement creates a new DESCRIBE statement
+func NewDESCRIBETableStatement(table string) *DESCRIBETableStatement {
+	return &DESCRIBETableStatement{
+		Table: table,
+	}
+}
+
+// AlterAction is the change an ALTER TABLE statement makes
+type AlterAction interface {
+	alterAction()
+	String() string
+}
+
+// AddColumnAction is ADD [COLUMN] definition
+type AddColumnAction struct {
+	Column ColumnDefinition
+}
+
+func (a *AddColumnAction) alterAction() {}
+
+// String returns a string representation of the action
+func (a *AddColumnAction) String() string {
+	return "ADD COLUMN " + a.Column.String()
+}
+
+// DropColumnAction is DROP [COLUMN] name
+type DropColumnAction struct {
+	Column string
+}
+
+func (a *DropColumnAction) alterAction() {}
+
+// String returns a string representation of the action
+func (a *DropColumnAction) String() string {
+	return "DROP COLUMN " + a.Column
+}
+
+// RenameColumnAction is RENAME [COLUMN] name TO new_name
+type RenameColumnAction struct {
+	Column  string
+	NewName string
+}
+
+func (a *RenameColumnAction) alterAction() {}
+
+// String returns a string representation of the action
+func (a *RenameColumnAction) String() string {
+	return "RENAME COLUMN " + a.Column + " TO " + a.NewName
+}
+
+// RenameTableAction is RENAME TO new_name
+type RenameTableAction struct {
+	NewName string
+}
+
+func (a *RenameTableAction) alterAction() {}
+
+// String returns a string representation of the action
+func (a *RenameTableAction) String() string {
+	return "RENAME TO " + a.NewName
+}
+
+// ALTERTableStatement represents an ALTER TABLE statement
+type ALTERTableStatement struct {
+	Table  string      // Table name
+	Action AlterAction // The change to make
+}
+
+// Statement implements the Statement interface
+func (a *ALTERTableStatement) Statement() {}
+
+// DDLStatement implements the DDLStatement interface
+func (a *ALTERTableStatement) DDLStatement() {}
+
+// String returns a string representation of the ALTER TABLE statement
+func (a *ALTERTableStatement) String() string {
+	return "ALTER TABLE " + a.Table + " " + a.Action.String()
+}
+
+// NewALTERTableStatement creates a new ALTER TABLE statement
+func NewALTERTableStatement(table string, action AlterAction) *ALTERTableStatement {
+	return &ALTERTableStatement{
+		Table:  table,
+		Action: action,
+	}
+}
+
+// BEGINStatement represents a BEGIN [TRANSACTION] statement
+type BEGINStatement struct{}
+
+// Statement implements the Statement interface
+func (b *BEGINStatement) Statement() {}
+
+// TransactionStatement implements the TransactionStatement interface
+func (b *BEGINStatement) TransactionStatement() {}
+
+// String returns a string representation of the BEGIN statement
+func (b *BEGINStatement) String() string {
+	return "BEGIN"
+}
+
+// NewBEGINStatement creates a new BEGIN statement
+func NewBEGINStatement() *BEGINStatement {
+	return &BEGINStatement{}
+}
+
+// COMMITStatement represents a COMMIT [TRANSACTION] statement
+type COMMITStatement struct{}
+
+// Statement implements the Statement interface
+func (c *COMMITStatement) Statement() {}
+
+// TransactionStatement implements the TransactionStatement interface
+func (c *COMMITStatement) TransactionStatement() {}
+
+// String returns a string representation of the COMMIT statement
+func (c *COMMITStatement) String() string {
+	return "COMMIT"
+}
+
+// NewCOMMITStatement creates a new COMMIT statement
+func NewCOMMITStatement() *COMMITStatement {
+	return &COMMITStatement{}
+}
+
+// ROLLBACKStatement represents a ROLLBACK [TRANSACTION] statement
+type ROLLBACKStatement struct{}
+
+// Statement implements the Statement interface
+func (r *ROLLBACKStatement) Statement() {}
+
+// TransactionStatement implements the TransactionStatement interface
+func (r *ROLLBACKStatement) TransactionStatement() {}
+
+// String returns a string representation of the ROLLBACK statement
+func (r *ROLLBACKStatement) String() string {
+	return "ROLLBACK"
+}
+
+// NewROLLBACKStatement creates a new ROLLBACK statement
+func NewROLLBACKStatement() *ROLLBACKStatement {
+	return &ROLLBACKStatement{}
+}
+
 // Program represents the root AST node containing all statements
 type Program struct {
 	Statements []Statement
@@ -0,0 +1,213 @@
+package ast
+
+import (
+	"strconv"
+	"strings"
+	"weird/db/engine/token"
+)
+
+// Expression is the base interface for all expression nodes (WHERE
+// predicates and the values they compare)
+type Expression interface {
+	Expression()
+	String() string
+}
+
+// Precedence levels for expression operators, lowest binding first
+const (
+	LowestPrecedence = iota
+	OrPrecedence
+	AndPrecedence
+	NotPrecedence
+	ComparisonPrecedence
+)
+
+// Precedence returns the binding power of a binary operator, or
+// LowestPrecedence when the token is not a binary operator
+func Precedence(op token.TokenType) int {
+	switch op {
+	case token.OR_TOKEN:
+		return OrPrecedence
+	case token.AND_TOKEN:
+		return AndPrecedence
+	case token.EQUALS_TOKEN, token.NOT_EQUALS_TOKEN,
+		token.LT_TOKEN, token.GT_TOKEN, token.LTE_TOKEN, token.GTE_TOKEN:
+		return ComparisonPrecedence
+	default:
+		return LowestPrecedence
+	}
+}
+
+// Identifier references a column by name
+type Identifier struct {
+	Name string
+}
+
+// Expression implements the Expression interface
+func (i *Identifier) Expression() {}
+
+// String returns the column name
+func (i *Identifier) String() string {
+	return i.Name
+}
+
+// NewIdentifier creates a new column reference
+func NewIdentifier(name string) *Identifier {
+	return &Identifier{Name: name}
+}
+
+// AggregateExpression applies an aggregate function (COUNT, SUM, AVG, MIN,
+// MAX) to a column, or to "*" for COUNT(*)
+type AggregateExpression struct {
+	Function string      // Upper-case function name
+	Argument *Identifier // Aggregated column, or "*"
+}
+
+// Expression implements the Expression interface
+func (a *AggregateExpression) Expression() {}
+
+// String returns the call as written, e.g. "COUNT(*)". It doubles as the
+// name of the result column.
+func (a *AggregateExpression) String() string {
+	return a.Function + "(" + a.Argument.String() + ")"
+}
+
+// NewAggregateExpression creates a new aggregate function call
+func NewAggregateExpression(function string, argument *Identifier) *AggregateExpression {
+	return &AggregateExpression{
+		Function: function,
+		Argument: argument,
+	}
+}
+
+// IsAggregateFunction reports whether name is a supported aggregate function
+func IsAggregateFunction(name string) bool {
+	switch name {
+	case "COUNT", "SUM", "AVG", "MIN", "MAX":
+		return true
+	default:
+		return false
+	}
+}
+
+// LiteralKind is the type of a literal value
+type LiteralKind string
+
+const (
+	StringLiteral  LiteralKind = "STRING"
+	IntegerLiteral LiteralKind = "INTEGER"
+	FloatLiteral   LiteralKind = "FLOAT"
+	BooleanLiteral LiteralKind = "BOOLEAN"
+	NullLiteral    LiteralKind = "NULL"
+)
+
+// Literal is a typed constant. Value holds a string, int64, float64, bool
+// or nil according to Kind, so it marshals to the matching JSON type.
+type Literal struct {
+	Kind  LiteralKind
+	Value interface{}
+}
+
+// Expression implements the Expression interface
+func (l *Literal) Expression() {}
+
+// String returns the literal in SQL syntax
+func (l *Literal) String() string {
+	switch l.Kind {
+	case StringLiteral:
+		return "'" + strings.ReplaceAll(l.Value.(string), "'", "''") + "'"
+	case IntegerLiteral:
+		return strconv.FormatInt(l.Value.(int64), 10)
+	case FloatLiteral:
+		return strconv.FormatFloat(l.Value.(float64), 'f', -1, 64)
+	case BooleanLiteral:
+		if l.Value.(bool) {
+			return "TRUE"
+		}
+		return "FALSE"
+	default:
+		return "NULL"
+	}
+}
+
+// NewStringLiteral creates a new string literal
+func NewStringLiteral(value string) *Literal {
+	return &Literal{Kind: StringLiteral, Value: value}
+}
+
+// NewIntegerLiteral creates a new integer literal
+func NewIntegerLiteral(value int64) *Literal {
+	return &Literal{Kind: IntegerLiteral, Value: value}
+}
+
+// NewFloatLiteral creates a new floating point literal
+func NewFloatLiteral(value float64) *Literal {
+	return &Literal{Kind: FloatLiteral, Value: value}
+}
+
+// NewBooleanLiteral creates a new boolean literal
+func NewBooleanLiteral(value bool) *Literal {
+	return &Literal{Kind: BooleanLiteral, Value: value}
+}
+
+// NewNullLiteral creates a new NULL literal
+func NewNullLiteral() *Literal {
+	return &Literal{Kind: NullLiteral}
+}
+
+// BinaryExpression applies an infix operator (AND, OR or a comparison) to
+// two operands
+type BinaryExpression struct {
+	Left     Expression
+	Operator token.TokenType
+	Right    Expression
+}
+
+// Expression implements the Expression interface
+func (b *BinaryExpression) Expression() {}
+
+// String returns the expression with parentheses only where precedence
+// requires them
+func (b *BinaryExpression) String() string {
+	prec := Precedence(b.Operator)
+	return wrap(b.Left, prec) + " " + string(b.Operator) + " " + wrap(b.Right, prec+1)
+}
+
+// NewBinaryExpression creates a new binary expression
+func NewBinaryExpression(left Expression, operator token.TokenType, right Expression) *BinaryExpression {
+	return &BinaryExpression{
+		Left:     left,
+		Operator: operator,
+		Right:    right,
+	}
+}
+
+// UnaryExpression applies a prefix operator (NOT) to an operand
+type UnaryExpression struct {
+	Operator token.TokenType
+	Operand  Expression
+}
+
+// Expression implements the Expression interface
+func (u *UnaryExpression) Expression() {}
+
+// String returns a string representation of the unary expression
+func (u *UnaryExpression) String() string {
+	return string(u.Operator) + " " + wrap(u.Operand, NotPrecedence)
+}
+
+// NewUnaryExpression creates a new unary expression
+func NewUnaryExpression(operator token.TokenType, operand Expression) *UnaryExpression {
+	return &UnaryExpression{
+		Operator: operator,
+		Operand:  operand,
+	}
+}
+
+// wrap parenthesizes binary operands that bind looser than their parent
+func wrap(expr Expression, minPrecedence int) string {
+	if b, ok := expr.(*BinaryExpression); ok && Precedence(b.Operator) < minPrecedence {
+		return "(" + b.String() + ")"
+	}
+	return expr.String()
+}
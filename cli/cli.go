@@ -21,13 +21,18 @@ func NewCLI(serverURL string) *CLI {
 		serverURL = "http://localhost:8081"
 	}
 
-	dbClient := client.NewClient(serverURL)
+	return NewCLIWithClient(client.NewClient(serverURL))
+}
 
+// NewCLIWithClient builds a shell on top of any DbClient, such as an
+// in-process storage.Engine
+func NewCLIWithClient(dbClient client.DbClient) *CLI {
 	return &CLI{
 		lexer:    lexer.New(),
 		executor: executor.NewExecutor(dbClient),
 	}
 }
+
 func (c *CLI) Run() {
 	scanner := bufio.NewScanner(os.Stdin)
 
@@ -37,7 +42,9 @@ func (c *CLI) Run() {
 	fmt.Println("╚═══════════════════════════════════════╝")
 	fmt.Println()
 	fmt.Println("Commands:")
-	fmt.Println("  - Type SQL statements (SELECT, INSERT, UPDATE, DELETE)")
+	fmt.Println("  - Type SQL statements (CREATE TABLE, SELECT, INSERT, UPDATE, DELETE)")
+	fmt.Println("  - SHOW TABLES and DESCRIBE <table> to explore the schema")
+	fmt.Println("  - BEGIN, COMMIT and ROLLBACK to group statements in a transaction")
 	fmt.Println("  - 'exit' or 'quit' to exit")
 	fmt.Println("  - 'help' for examples")
 	fmt.Println()
@@ -127,13 +134,39 @@ func (c *CLI) printHelp() {
 	fmt.Println("║                        SQL Examples                           ║")
 	fmt.Println("╚═══════════════════════════════════════════════════════════════╝")
 	fmt.Println()
+	fmt.Println("Table Examples:")
+	fmt.Println("  SHOW TABLES")
+	fmt.Println("  DESCRIBE users")
+	fmt.Println("  CREATE TABLE users (name, email, age)")
+	fmt.Println("  CREATE TABLE events (title TEXT, guests INT, price FLOAT, public BOOL, day DATE, starts TIMESTAMP)")
+	fmt.Println("  CREATE TABLE accounts (id INT PRIMARY KEY, email TEXT UNIQUE NOT NULL, age INT DEFAULT 18 CHECK (age >= 0))")
+	fmt.Println("  CREATE TABLE members (team TEXT, name TEXT, CONSTRAINT one_name UNIQUE (team, name))")
+	fmt.Println("  CREATE TABLE orders (id INT PRIMARY KEY, user_id INT REFERENCES accounts (id) ON DELETE CASCADE)")
+	fmt.Println("  CREATE TABLE notes (body TEXT, order_id INT, FOREIGN KEY (order_id) REFERENCES orders ON DELETE SET NULL)")
+	fmt.Println("  ALTER TABLE users ADD COLUMN active BOOL DEFAULT TRUE")
+	fmt.Println("  ALTER TABLE users DROP COLUMN age")
+	fmt.Println("  ALTER TABLE users RENAME COLUMN email TO contact")
+	fmt.Println("  ALTER TABLE users RENAME TO members")
+	fmt.Println("  DROP TABLE IF EXISTS users")
+	fmt.Println("  TRUNCATE TABLE users")
+	fmt.Println("  CREATE INDEX users_age ON users (age)")
+	fmt.Println("  CREATE UNIQUE INDEX users_email ON users (email)")
+	fmt.Println("  DROP INDEX IF EXISTS users_age")
+	fmt.Println()
 	fmt.Println("SELECT Examples:")
 	fmt.Println("  SELECT * FROM users")
 	fmt.Println("  SELECT id, name, email FROM users")
+	fmt.Println("  SELECT name, email FROM users WHERE name = 'John'")
+	fmt.Println("  SELECT name FROM users WHERE age >= 18 AND name <> 'admin'")
+	fmt.Println("  SELECT name, age FROM users ORDER BY age DESC, name LIMIT 10 OFFSET 20")
+	fmt.Println("  SELECT age, COUNT(*), AVG(score) FROM users GROUP BY age HAVING COUNT(*) > 1")
+	fmt.Println("  SELECT users.name, orders.item FROM users LEFT JOIN orders ON users.id = orders.user_id")
 	fmt.Println()
 	fmt.Println("INSERT Examples:")
 	fmt.Println("  INSERT INTO users (name, email, age) VALUES ('John', 'john@example.com', 30)")
 	fmt.Println("  INSERT INTO products VALUES (1, 'Laptop', 999.99)")
+	fmt.Println("  INSERT INTO products VALUES (2, 'Mouse', 19.99), (3, 'Pad', 4.99)")
+	fmt.Println("  INSERT INTO archive (name, email) SELECT name, email FROM users WHERE age > 60")
 	fmt.Println()
 	fmt.Println("UPDATE Examples:")
 	fmt.Println("  UPDATE users SET age = 31 WHERE name = 'John'")
@@ -142,5 +175,12 @@ func (c *CLI) printHelp() {
 	fmt.Println("DELETE Examples:")
 	fmt.Println("  DELETE FROM users WHERE name = 'John'")
 	fmt.Println("  DELETE FROM products WHERE id = 1")
+	fmt.Println("  DELETE FROM users WHERE name = 'John' OR (age = 30 AND NOT email = 'j@x.com')")
+	fmt.Println()
+	fmt.Println("Transaction Examples:")
+	fmt.Println("  BEGIN")
+	fmt.Println("  UPDATE accounts SET balance = 50 WHERE id = 1")
+	fmt.Println("  COMMIT")
+	fmt.Println("  ROLLBACK")
 	fmt.Println()
 }
@@ -3,74 +3,377 @@ package client
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 	"time"
 )
 
 type DbClient interface {
-	CreateTable(table string, columns []string) (*Response, error)
-	Insert(table string, values []interface{}) (*Response, error)
+	CreateTable(table string, columns []Column, constraints []Constraint) (*Response, error)
+	DropTable(table string, ifExists bool) (*Response, error)
+	Truncate(table string) (*Response, error)
+	AddColumn(table string, column Column, constraints []Constraint) (*Response, error)
+	DropColumn(table string, column string, constraints []Constraint) (*Response, error)
+	RenameColumn(table string, column string, newName string, constraints []Constraint) (*Response, error)
+	RenameTable(table string, newName string) (*Response, error)
+	CreateIndex(table string, index Index) (*Response, error)
+	DropIndex(name string, ifExists bool) (*Response, error)
+	Schema(table string) (*Response, error)
+	ListTables() (*Response, error)
+	DescribeTable(table string) (*Response, error)
+	References(table string) (*Response, error)
+	Insert(table string, values map[string]interface{}) (*Response, error)
+	InsertMany(table string, rows []map[string]interface{}) (*Response, error)
+	InsertRows(table string, ids []int, rows []map[string]interface{}) (*Response, error)
 	Select(table string, where map[string]interface{}) (*Response, error)
+	SelectRows(table string, ids []int) (*Response, error)
 	SelectAll(table string) (*Response, error)
 	Update(table string, set map[string]interface{}, where map[string]interface{}) (*Response, error)
+	UpdateRows(table string, ids []int, set map[string]interface{}) (*Response, error)
 	Delete(table string, where map[string]interface{}) (*Response, error)
+	DeleteRows(table string, ids []int) (*Response, error)
 	DeleteAll(table string) (*Response, error)
+	Begin() (Tx, error)
 	SetTimeout(timeout time.Duration)
 	Close() error
 }
 
+// Tx is a transaction started by DbClient.Begin. Requests made through it
+// are staged by the server: they see each other's changes, but nothing
+// becomes durable before Commit. Rollback discards them. Either ends the
+// transaction, and so does the server after a while without requests.
+// The Go server keeps the changes from anyone else until Commit; engine.pl
+// runs one transaction at a time, refuses changes made outside it, and
+// lets reads from outside see its changes early.
+type Tx interface {
+	DbClient
+	Commit() (*Response, error)
+	Rollback() (*Response, error)
+}
+
+// ErrTxUnsupported is returned by Begin when the server has no
+// transactions
+var ErrTxUnsupported = errors.New("transactions are not supported by the server")
+
+// TransactionsCapability is listed by servers that support Begin
+const TransactionsCapability = "transactions"
+
 type Client struct {
 	baseURL    string
 	httpClient *http.Client
+	// tx is the server's ID of the transaction requests belong to, if any
+	tx string
+
+	mu sync.Mutex
+	// capabilities are those the server listed, nil until asked
+	capabilities map[string]bool
 }
 
-type CreateTableRequest struct {
-	Type    string   `json:"type"`
-	Table   string   `json:"table"`
+// Column describes a column of a new table. Type is one of INT, FLOAT,
+// TEXT, BOOL, DATE or TIMESTAMP, or empty for an untyped column.
+type Column struct {
+	Name string `json:"name"`
+	Type string `json:"type,omitempty"`
+}
+
+// Index is a secondary index of a table over Columns, the first of which
+// leads. Only the Go engine, storage.Engine and the server built on it,
+// uses indexes for lookups: there a lookup by the leading column, for
+// equality or a range, goes through the index. engine.pl only records
+// them and scans every row. A Unique index also rejects rows repeating the
+// key of another, unless the key has a NULL in it. Index names are unique
+// across tables.
+type Index struct {
+	Name    string   `json:"name"`
 	Columns []string `json:"columns"`
+	Unique  bool     `json:"unique,omitempty"`
+}
+
+// Constraint kinds stored with a table schema
+const (
+	PrimaryKeyConstraint = "primary_key"
+	UniqueConstraint     = "unique"
+	NotNullConstraint    = "not_null"
+	DefaultConstraint    = "default"
+	CheckConstraint      = "check"
+	ForeignKeyConstraint = "foreign_key"
+)
+
+// ON DELETE actions of a foreign key
+const (
+	RestrictAction = "RESTRICT"
+	CascadeAction  = "CASCADE"
+	SetNullAction  = "SET NULL"
+)
+
+// Reference is the referenced side of a FOREIGN KEY constraint
+type Reference struct {
+	Table    string   `json:"table"`
+	Columns  []string `json:"columns"`
+	OnDelete string   `json:"on_delete"`
+}
+
+// Constraint is a named rule stored alongside a table schema. Columns
+// lists the key columns of a PRIMARY KEY or UNIQUE constraint, the single
+// column of a NOT NULL or DEFAULT one, or the columns a CHECK refers to.
+// Check holds the SQL condition of a CHECK constraint and Default the
+// value of a DEFAULT one. A FOREIGN KEY constraint names the referencing
+// Columns and what they point to in References. Table is only set in
+// References responses, where it names the table the constraint belongs
+// to.
+type Constraint struct {
+	Name       string      `json:"name"`
+	Type       string      `json:"type"`
+	Table      string      `json:"table,omitempty"`
+	Columns    []string    `json:"columns,omitempty"`
+	Check      string      `json:"check,omitempty"`
+	Default    interface{} `json:"default,omitempty"`
+	References *Reference  `json:"references,omitempty"`
+}
+
+// CreateTableRequest and the other requests of a table name the
+// transaction they belong to in Tx, empty outside of one
+type CreateTableRequest struct {
+	Type        string       `json:"type"`
+	Tx          string       `json:"tx,omitempty"`
+	Table       string       `json:"table"`
+	Columns     []Column     `json:"columns"`
+	Constraints []Constraint `json:"constraints,omitempty"`
+}
+
+type DropTableRequest struct {
+	Type     string `json:"type"`
+	Tx       string `json:"tx,omitempty"`
+	Table    string `json:"table"`
+	IfExists bool   `json:"if_exists,omitempty"`
+}
+
+type CreateIndexRequest struct {
+	Type  string `json:"type"`
+	Tx    string `json:"tx,omitempty"`
+	Table string `json:"table"`
+	Index Index  `json:"index"`
+}
+
+// DropIndexRequest names the index to drop; the server finds its table
+type DropIndexRequest struct {
+	Type     string `json:"type"`
+	Tx       string `json:"tx,omitempty"`
+	Name     string `json:"name"`
+	IfExists bool   `json:"if_exists,omitempty"`
+}
+
+type TruncateRequest struct {
+	Type  string `json:"type"`
+	Tx    string `json:"tx,omitempty"`
+	Table string `json:"table"`
+}
+
+// AddColumnRequest appends a column to a table, filling existing rows with
+// the column's DEFAULT or NULL. Constraints replaces the table's
+// constraints, as do those of DropColumnRequest and RenameColumnRequest.
+type AddColumnRequest struct {
+	Type        string       `json:"type"`
+	Tx          string       `json:"tx,omitempty"`
+	Table       string       `json:"table"`
+	Column      Column       `json:"column"`
+	Constraints []Constraint `json:"constraints"`
+}
+
+type DropColumnRequest struct {
+	Type        string       `json:"type"`
+	Tx          string       `json:"tx,omitempty"`
+	Table       string       `json:"table"`
+	Column      string       `json:"column"`
+	Constraints []Constraint `json:"constraints"`
+}
+
+// RenameColumnRequest renames a column; foreign keys of other tables that
+// reference it are updated by the server
+type RenameColumnRequest struct {
+	Type        string       `json:"type"`
+	Tx          string       `json:"tx,omitempty"`
+	Table       string       `json:"table"`
+	Column      string       `json:"column"`
+	NewName     string       `json:"new_name"`
+	Constraints []Constraint `json:"constraints"`
+}
+
+type RenameTableRequest struct {
+	Type    string `json:"type"`
+	Tx      string `json:"tx,omitempty"`
+	Table   string `json:"table"`
+	NewName string `json:"new_name"`
+}
+
+type SchemaRequest struct {
+	Type  string `json:"type"`
+	Tx    string `json:"tx,omitempty"`
+	Table string `json:"table"`
+}
+
+type ListTablesRequest struct {
+	Type string `json:"type"`
+	Tx   string `json:"tx,omitempty"`
+}
+
+type DescribeTableRequest struct {
+	Type  string `json:"type"`
+	Tx    string `json:"tx,omitempty"`
+	Table string `json:"table"`
+}
+
+// ReferencesRequest asks for the foreign keys of every table that point at
+// Table
+type ReferencesRequest struct {
+	Type  string `json:"type"`
+	Tx    string `json:"tx,omitempty"`
+	Table string `json:"table"`
+}
+
+// CapabilitiesRequest asks which optional parts of the protocol the
+// server supports
+type CapabilitiesRequest struct {
+	Type string `json:"type"`
+}
+
+type BeginRequest struct {
+	Type string `json:"type"`
 }
 
+// EndTxRequest commits or rolls back the transaction Tx
+type EndTxRequest struct {
+	Type string `json:"type"`
+	Tx   string `json:"tx"`
+}
+
+// InsertRequest carries the new row as a column name -> value object;
+// columns left out are stored as null
 type InsertRequest struct {
-	Type   string        `json:"type"`
-	Table  string        `json:"table"`
-	Values []interface{} `json:"values"`
+	Type   string                 `json:"type"`
+	Tx     string                 `json:"tx,omitempty"`
+	Table  string                 `json:"table"`
+	Values map[string]interface{} `json:"values"`
+}
+
+// Condition is a WHERE map entry comparing a column with an operator other
+// than equality, e.g. {"age": Condition{Op: ">", Value: 30}}. Plain values
+// in a WHERE map still mean equality.
+type Condition struct {
+	Op    string      `json:"op"`
+	Value interface{} `json:"value"`
+}
+
+// InsertManyRequest inserts a batch of rows, each shaped like
+// InsertRequest.Values, in a single round-trip. IDs, if given, holds the
+// ID of each row; otherwise the server assigns them.
+type InsertManyRequest struct {
+	Type  string                   `json:"type"`
+	Tx    string                   `json:"tx,omitempty"`
+	Table string                   `json:"table"`
+	Rows  []map[string]interface{} `json:"rows"`
+	IDs   []int                    `json:"ids,omitempty"`
 }
 
 type SelectRequest struct {
 	Type  string                 `json:"type"`
+	Tx    string                 `json:"tx,omitempty"`
 	Table string                 `json:"table"`
 	Where map[string]interface{} `json:"where,omitempty"`
+	IDs   []int                  `json:"ids,omitempty"`
 }
 
 type UpdateRequest struct {
 	Type  string                 `json:"type"`
+	Tx    string                 `json:"tx,omitempty"`
 	Table string                 `json:"table"`
 	Set   map[string]interface{} `json:"set"`
 	Where map[string]interface{} `json:"where,omitempty"`
+	IDs   []int                  `json:"ids,omitempty"`
 }
 
 type DeleteRequest struct {
 	Type  string                 `json:"type"`
+	Tx    string                 `json:"tx,omitempty"`
 	Table string                 `json:"table"`
 	Where map[string]interface{} `json:"where,omitempty"`
+	IDs   []int                  `json:"ids,omitempty"`
 }
 
-// Prolog DB Response format
+// Prolog DB Response format. Types, when present, holds the declared type
+// of each entry in Columns; schema responses also carry the table's
+// Constraints and Indexes. A begin response carries the new transaction's
+// ID in Tx, an insert_many response the IDs of the new rows in IDs, and a
+// capabilities response what the server supports in Capabilities.
 type Response struct {
-	Status  string   `json:"status"`
-	Message string   `json:"message,omitempty"`
-	Table   string   `json:"table,omitempty"`
-	Columns []string `json:"columns,omitempty"`
-	Rows    []Row    `json:"rows,omitempty"`
-	ID      int      `json:"id,omitempty"`
-	Count   int      `json:"count,omitempty"`
+	Status       string       `json:"status"`
+	Message      string       `json:"message,omitempty"`
+	Tx           string       `json:"tx,omitempty"`
+	Table        string       `json:"table,omitempty"`
+	Columns      []string     `json:"columns,omitempty"`
+	Types        []string     `json:"types,omitempty"`
+	Constraints  []Constraint `json:"constraints,omitempty"`
+	Indexes      []Index      `json:"indexes,omitempty"`
+	Tables       []TableInfo  `json:"tables,omitempty"`
+	Rows         []Row        `json:"rows,omitempty"`
+	ID           int          `json:"id,omitempty"`
+	IDs          []int        `json:"ids,omitempty"`
+	Count        int          `json:"count,omitempty"`
+	RowCount     int          `json:"row_count,omitempty"`
+	Capabilities []string     `json:"capabilities,omitempty"`
 }
 
+// TableInfo describes one table of a ListTables response
+type TableInfo struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+	Types   []string `json:"types,omitempty"`
+	Count   int      `json:"count"`
+}
+
+// Row holds one record. Data values are typed according to the column
+// types: int64, float64, string, bool, or nil for NULL.
 type Row struct {
-	ID   int      `json:"id"`
-	Data []string `json:"data"`
+	ID   int           `json:"id"`
+	Data []interface{} `json:"data"`
+}
+
+// UnmarshalJSON decodes a row keeping integers exact: JSON numbers are read
+// as int64 when whole and float64 otherwise, rather than all as float64.
+func (r *Row) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		ID   int           `json:"id"`
+		Data []interface{} `json:"data"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return err
+	}
+
+	r.ID = raw.ID
+	r.Data = raw.Data
+	for i, v := range r.Data {
+		r.Data[i] = NormalizeValue(v, "")
+	}
+	return nil
+}
+
+// AsMap returns the row's cells keyed by column name.
+//
+// Deprecated: use Get, which matches column names case-insensitively and
+// returns a typed Value.
+func (r *Row) AsMap(columns []string) map[string]interface{} {
+	result := make(map[string]interface{})
+	for i, col := range columns {
+		if i < len(r.Data) {
+			result[col] = r.Data[i]
+		}
+	}
+	return result
 }
 
 func NewClient(baseURL string) DbClient {
@@ -120,36 +423,208 @@ func (c *Client) sendRequest(payload interface{}) (*Response, error) {
 		return &response, fmt.Errorf("query failed: %s", response.Message)
 	}
 
+	response.applyTypes()
+
 	return &response, nil
 }
 
-func (c *Client) CreateTable(table string, columns []string) (*Response, error) {
+func (c *Client) CreateTable(table string, columns []Column, constraints []Constraint) (*Response, error) {
 	req := CreateTableRequest{
-		Type:    "create_table",
+		Type:        "create_table",
+		Tx:          c.tx,
+		Table:       table,
+		Columns:     columns,
+		Constraints: constraints,
+	}
+	return c.sendRequest(req)
+}
+
+func (c *Client) DropTable(table string, ifExists bool) (*Response, error) {
+	req := DropTableRequest{
+		Type:     "drop_table",
+		Tx:       c.tx,
+		Table:    table,
+		IfExists: ifExists,
+	}
+	return c.sendRequest(req)
+}
+
+func (c *Client) CreateIndex(table string, index Index) (*Response, error) {
+	req := CreateIndexRequest{
+		Type:  "create_index",
+		Tx:    c.tx,
+		Table: table,
+		Index: index,
+	}
+	return c.sendRequest(req)
+}
+
+func (c *Client) DropIndex(name string, ifExists bool) (*Response, error) {
+	req := DropIndexRequest{
+		Type:     "drop_index",
+		Tx:       c.tx,
+		Name:     name,
+		IfExists: ifExists,
+	}
+	return c.sendRequest(req)
+}
+
+func (c *Client) Truncate(table string) (*Response, error) {
+	req := TruncateRequest{
+		Type:  "truncate",
+		Tx:    c.tx,
+		Table: table,
+	}
+	return c.sendRequest(req)
+}
+
+// AddColumn adds a column to a table. constraints is the table's complete
+// constraint list after the change.
+func (c *Client) AddColumn(table string, column Column, constraints []Constraint) (*Response, error) {
+	req := AddColumnRequest{
+		Type:        "add_column",
+		Tx:          c.tx,
+		Table:       table,
+		Column:      column,
+		Constraints: constraints,
+	}
+	return c.sendRequest(req)
+}
+
+// DropColumn removes a column and its values from a table. constraints is
+// the table's complete constraint list after the change.
+func (c *Client) DropColumn(table string, column string, constraints []Constraint) (*Response, error) {
+	req := DropColumnRequest{
+		Type:        "drop_column",
+		Tx:          c.tx,
+		Table:       table,
+		Column:      column,
+		Constraints: constraints,
+	}
+	return c.sendRequest(req)
+}
+
+// RenameColumn renames a column of a table. constraints is the table's
+// complete constraint list after the change.
+func (c *Client) RenameColumn(table string, column string, newName string, constraints []Constraint) (*Response, error) {
+	req := RenameColumnRequest{
+		Type:        "rename_column",
+		Tx:          c.tx,
+		Table:       table,
+		Column:      column,
+		NewName:     newName,
+		Constraints: constraints,
+	}
+	return c.sendRequest(req)
+}
+
+// RenameTable renames a table, along with the foreign keys that reference it
+func (c *Client) RenameTable(table string, newName string) (*Response, error) {
+	req := RenameTableRequest{
+		Type:    "rename_table",
+		Tx:      c.tx,
 		Table:   table,
-		Columns: columns,
+		NewName: newName,
+	}
+	return c.sendRequest(req)
+}
+
+// Schema returns the columns of a table without any rows
+func (c *Client) Schema(table string) (*Response, error) {
+	req := SchemaRequest{
+		Type:  "schema",
+		Tx:    c.tx,
+		Table: table,
 	}
 	return c.sendRequest(req)
 }
 
-func (c *Client) Insert(table string, values []interface{}) (*Response, error) {
+// ListTables returns every table in Tables, with its columns, their types
+// and its row count
+func (c *Client) ListTables() (*Response, error) {
+	return c.sendRequest(ListTablesRequest{Type: "list_tables", Tx: c.tx})
+}
+
+// DescribeTable returns the columns, types and constraints of a table like
+// Schema does, plus its row count in RowCount
+func (c *Client) DescribeTable(table string) (*Response, error) {
+	req := DescribeTableRequest{
+		Type:  "describe_table",
+		Tx:    c.tx,
+		Table: table,
+	}
+	return c.sendRequest(req)
+}
+
+// References returns, in Constraints, the FOREIGN KEY constraints of all
+// tables that reference the given one, each with its own Table set
+func (c *Client) References(table string) (*Response, error) {
+	req := ReferencesRequest{
+		Type:  "references",
+		Tx:    c.tx,
+		Table: table,
+	}
+	return c.sendRequest(req)
+}
+
+func (c *Client) Insert(table string, values map[string]interface{}) (*Response, error) {
 	req := InsertRequest{
 		Type:   "insert",
+		Tx:     c.tx,
 		Table:  table,
 		Values: values,
 	}
 	return c.sendRequest(req)
 }
 
+// InsertMany inserts several rows at once. The server assigns IDs in order
+// and reports how many rows were inserted in Count, and their IDs in IDs.
+func (c *Client) InsertMany(table string, rows []map[string]interface{}) (*Response, error) {
+	return c.InsertRows(table, nil, rows)
+}
+
+// InsertRows inserts several rows at once under the given IDs, one for
+// each row, e.g. to put deleted rows back as they were. A nil ID list lets
+// the server assign them, as InsertMany does.
+func (c *Client) InsertRows(table string, ids []int, rows []map[string]interface{}) (*Response, error) {
+	if len(rows) == 0 && len(ids) == 0 {
+		return &Response{Status: "success", Message: "Records inserted", Table: table}, nil
+	}
+	req := InsertManyRequest{
+		Type:  "insert_many",
+		Tx:    c.tx,
+		Table: table,
+		Rows:  rows,
+		IDs:   ids,
+	}
+	return c.sendRequest(req)
+}
+
 func (c *Client) Select(table string, where map[string]interface{}) (*Response, error) {
 	req := SelectRequest{
 		Type:  "select",
+		Tx:    c.tx,
 		Table: table,
 		Where: where,
 	}
 	return c.sendRequest(req)
 }
 
+// SelectRows returns the rows with the given IDs. An empty ID list selects
+// nothing.
+func (c *Client) SelectRows(table string, ids []int) (*Response, error) {
+	if len(ids) == 0 {
+		return &Response{Status: "success", Table: table}, nil
+	}
+	req := SelectRequest{
+		Type:  "select",
+		Tx:    c.tx,
+		Table: table,
+		IDs:   ids,
+	}
+	return c.sendRequest(req)
+}
+
 func (c *Client) SelectAll(table string) (*Response, error) {
 	return c.Select(table, nil)
 }
@@ -157,6 +632,7 @@ func (c *Client) SelectAll(table string) (*Response, error) {
 func (c *Client) Update(table string, set map[string]interface{}, where map[string]interface{}) (*Response, error) {
 	req := UpdateRequest{
 		Type:  "update",
+		Tx:    c.tx,
 		Table: table,
 		Set:   set,
 		Where: where,
@@ -164,19 +640,110 @@ func (c *Client) Update(table string, set map[string]interface{}, where map[stri
 	return c.sendRequest(req)
 }
 
+// UpdateRows updates the rows with the given IDs. An empty ID list updates
+// nothing (rather than every row, as a missing WHERE would).
+func (c *Client) UpdateRows(table string, ids []int, set map[string]interface{}) (*Response, error) {
+	if len(ids) == 0 {
+		return &Response{Status: "success", Message: "Records updated", Table: table}, nil
+	}
+	req := UpdateRequest{
+		Type:  "update",
+		Tx:    c.tx,
+		Table: table,
+		Set:   set,
+		IDs:   ids,
+	}
+	return c.sendRequest(req)
+}
+
 func (c *Client) Delete(table string, where map[string]interface{}) (*Response, error) {
 	req := DeleteRequest{
 		Type:  "delete",
+		Tx:    c.tx,
 		Table: table,
 		Where: where,
 	}
 	return c.sendRequest(req)
 }
 
+// DeleteRows deletes the rows with the given IDs. An empty ID list deletes
+// nothing (rather than every row, as a missing WHERE would).
+func (c *Client) DeleteRows(table string, ids []int) (*Response, error) {
+	if len(ids) == 0 {
+		return &Response{Status: "success", Message: "Records deleted", Table: table}, nil
+	}
+	req := DeleteRequest{
+		Type:  "delete",
+		Tx:    c.tx,
+		Table: table,
+		IDs:   ids,
+	}
+	return c.sendRequest(req)
+}
+
 func (c *Client) DeleteAll(table string) (*Response, error) {
 	return c.Delete(table, nil)
 }
 
+// Begin starts a transaction on the server. The returned Tx shares this
+// client's connection and tags every request with the transaction's ID.
+// Begin returns ErrTxUnsupported unless the server lists
+// TransactionsCapability.
+func (c *Client) Begin() (Tx, error) {
+	if c.tx != "" {
+		return nil, fmt.Errorf("transaction already in progress")
+	}
+	supported, err := c.supports(TransactionsCapability)
+	if err != nil {
+		return nil, err
+	}
+	if !supported {
+		return nil, ErrTxUnsupported
+	}
+	resp, err := c.sendRequest(BeginRequest{Type: "begin"})
+	if err != nil {
+		return nil, err
+	}
+	return &clientTx{Client: &Client{
+		baseURL:    c.baseURL,
+		httpClient: c.httpClient,
+		tx:         resp.Tx,
+	}}, nil
+}
+
+// supports tells whether the server lists capability, asking it the
+// first time. A server that refuses the capabilities request predates it
+// and has none.
+func (c *Client) supports(capability string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.capabilities == nil {
+		resp, err := c.sendRequest(CapabilitiesRequest{Type: "capabilities"})
+		if err != nil && (resp == nil || resp.Status != "error") {
+			return false, err
+		}
+		c.capabilities = make(map[string]bool)
+		if err == nil {
+			for _, name := range resp.Capabilities {
+				c.capabilities[name] = true
+			}
+		}
+	}
+	return c.capabilities[capability], nil
+}
+
+type clientTx struct {
+	*Client
+}
+
+func (t *clientTx) Commit() (*Response, error) {
+	return t.sendRequest(EndTxRequest{Type: "commit", Tx: t.tx})
+}
+
+func (t *clientTx) Rollback() (*Response, error) {
+	return t.sendRequest(EndTxRequest{Type: "rollback", Tx: t.tx})
+}
+
 func (c *Client) SetTimeout(timeout time.Duration) {
 	c.httpClient.Timeout = timeout
 }
@@ -186,17 +753,6 @@ func (c *Client) Close() error {
 	return nil
 }
 
-// Helper method to get row data as a map
-func (r *Row) AsMap(columns []string) map[string]interface{} {
-	result := make(map[string]interface{})
-	for i, col := range columns {
-		if i < len(r.Data) {
-			result[col] = r.Data[i]
-		}
-	}
-	return result
-}
-
 func (r *Response) Print() {
 	if r.Status == "success" {
 		fmt.Printf("✓ Success: %s\n", r.Message)
@@ -214,7 +770,11 @@ func (r *Response) Print() {
 			fmt.Printf("  Columns: %v\n", r.Columns)
 			fmt.Printf("  Rows (%d):\n", len(r.Rows))
 			for _, row := range r.Rows {
-				fmt.Printf("    ID %d: %v\n", row.ID, row.Data)
+				cells := make([]string, len(row.Data))
+				for i := range row.Data {
+					cells[i] = row.Cell(i).String()
+				}
+				fmt.Printf("    ID %d: %v\n", row.ID, cells)
 			}
 		}
 	} else {
@@ -0,0 +1,81 @@
+package client
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// newRecorder returns a Client for a server that answers every request
+// with reply, and the decoded bodies of the requests it was sent
+func newRecorder(t *testing.T, reply string) (*Client, *[]map[string]interface{}) {
+	t.Helper()
+	var requests []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		var request map[string]interface{}
+		if err := json.Unmarshal(body, &request); err != nil {
+			t.Errorf("decoding %s: %v", body, err)
+		}
+		requests = append(requests, request)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, reply)
+	}))
+	t.Cleanup(srv.Close)
+	return NewClient(srv.URL).(*Client), &requests
+}
+
+func TestTypedRequestValues(t *testing.T) {
+	c, requests := newRecorder(t, `{"status":"success"}`)
+	values := map[string]interface{}{"n": int64(3), "f": 2.5, "s": "3", "b": true, "z": nil}
+	if _, err := c.Insert("t", values); err != nil {
+		t.Fatal(err)
+	}
+	where := map[string]interface{}{"n": Condition{Op: ">=", Value: int64(1)}, "s": "x"}
+	if _, err := c.Select("t", where); err != nil {
+		t.Fatal(err)
+	}
+
+	// Values keep their JSON type rather than all turning into strings
+	want := map[string]interface{}{"n": 3.0, "f": 2.5, "s": "3", "b": true, "z": nil}
+	if got := (*requests)[0]["values"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("insert: got values %#v, want %#v", got, want)
+	}
+	wantWhere := map[string]interface{}{"n": map[string]interface{}{"op": ">=", "value": 1.0}, "s": "x"}
+	if got := (*requests)[1]["where"]; !reflect.DeepEqual(got, wantWhere) {
+		t.Errorf("select: got where %#v, want %#v", got, wantWhere)
+	}
+}
+
+func TestRowUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		data string
+		want []interface{}
+	}{
+		{`[1, -2, 2.5, 1e3, 1.5e-1]`, []interface{}{int64(1), int64(-2), 2.5, int64(1000), 0.15}},
+		{`[9007199254740993]`, []interface{}{int64(9007199254740993)}},
+		{`["x", "", "12", true, false, null]`, []interface{}{"x", "", "12", true, false, nil}},
+		{`[]`, []interface{}{}},
+	}
+	for _, test := range tests {
+		var row Row
+		if err := json.Unmarshal([]byte(`{"id":7,"data":`+test.data+`}`), &row); err != nil {
+			t.Fatalf("%s: %v", test.data, err)
+		}
+		if row.ID != 7 || !reflect.DeepEqual(row.Data, test.want) {
+			t.Errorf("%s: got %d %#v, want %#v", test.data, row.ID, row.Data, test.want)
+		}
+	}
+
+	var row Row
+	if err := json.Unmarshal([]byte(`{"id":1,"data":[1,}`), &row); err == nil {
+		t.Error("expected an error for malformed data")
+	}
+}
@@ -0,0 +1,353 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Value wraps one cell of a row. The underlying value is an int64, float64,
+// string, bool, or nil for NULL.
+type Value struct {
+	v interface{}
+}
+
+// NewValue wraps a raw cell value
+func NewValue(v interface{}) Value {
+	return Value{v: v}
+}
+
+// IsNull reports whether the cell is NULL
+func (v Value) IsNull() bool {
+	return v.v == nil
+}
+
+// Interface returns the underlying value
+func (v Value) Interface() interface{} {
+	return v.v
+}
+
+// Int returns the cell as an integer. Floats are truncated and numeric
+// strings are parsed; anything else is an error.
+func (v Value) Int() (int64, error) {
+	switch x := v.v.(type) {
+	case int64:
+		return x, nil
+	case float64:
+		return int64(x), nil
+	case string:
+		return strconv.ParseInt(x, 10, 64)
+	case nil:
+		return 0, fmt.Errorf("value is NULL")
+	default:
+		return 0, fmt.Errorf("cannot convert %v to integer", x)
+	}
+}
+
+// Float returns the cell as a floating point number
+func (v Value) Float() (float64, error) {
+	switch x := v.v.(type) {
+	case int64:
+		return float64(x), nil
+	case float64:
+		return x, nil
+	case string:
+		return strconv.ParseFloat(x, 64)
+	case nil:
+		return 0, fmt.Errorf("value is NULL")
+	default:
+		return 0, fmt.Errorf("cannot convert %v to float", x)
+	}
+}
+
+// Bool returns the cell as a boolean
+func (v Value) Bool() (bool, error) {
+	switch x := v.v.(type) {
+	case bool:
+		return x, nil
+	case string:
+		return strconv.ParseBool(x)
+	case nil:
+		return false, fmt.Errorf("value is NULL")
+	default:
+		return false, fmt.Errorf("cannot convert %v to boolean", x)
+	}
+}
+
+// String formats the cell for display. NULL is the empty string.
+func (v Value) String() string {
+	switch x := v.v.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+// Cell returns the value in column i, or NULL when the row is shorter
+func (r Row) Cell(i int) Value {
+	if i < 0 || i >= len(r.Data) {
+		return Value{}
+	}
+	return Value{v: r.Data[i]}
+}
+
+// Get returns the value of the named column, or NULL when the column does
+// not exist
+func (r Row) Get(columns []string, name string) Value {
+	for i, col := range columns {
+		if strings.EqualFold(col, name) {
+			return r.Cell(i)
+		}
+	}
+	return Value{}
+}
+
+// applyTypes converts the JSON-decoded cells of every row to the Go type of
+// their column. JSON numbers arrive as float64, so INT columns are turned
+// back into int64; untyped columns keep whatever the server sent, with
+// whole numbers as int64.
+func (r *Response) applyTypes() {
+	for i := range r.Rows {
+		for j, value := range r.Rows[i].Data {
+			typ := ""
+			if j < len(r.Types) {
+				typ = r.Types[j]
+			}
+			r.Rows[i].Data[j] = typedValue(value, typ)
+		}
+	}
+}
+
+// NormalizeValue converts a Go value to the representation rows use for a
+// column of type typ, the same way a value sent to the server comes back:
+// integers of any width become int64, other numbers float64, and values of
+// INT, FLOAT and BOOL columns are parsed from strings when possible.
+func NormalizeValue(value interface{}, typ string) interface{} {
+	switch x := value.(type) {
+	case json.Number:
+		if n, err := x.Int64(); err == nil {
+			value = n
+		} else if f, err := x.Float64(); err == nil {
+			value = f
+		} else {
+			value = x.String()
+		}
+	case int:
+		value = int64(x)
+	case int8:
+		value = int64(x)
+	case int16:
+		value = int64(x)
+	case int32:
+		value = int64(x)
+	case uint:
+		value = int64(x)
+	case uint8:
+		value = int64(x)
+	case uint16:
+		value = int64(x)
+	case uint32:
+		value = int64(x)
+	case uint64:
+		value = int64(x)
+	case float32:
+		value = float64(x)
+	}
+	return typedValue(value, typ)
+}
+
+func typedValue(value interface{}, typ string) interface{} {
+	switch x := value.(type) {
+	case nil:
+		return nil
+	case int64:
+		switch typ {
+		case "FLOAT":
+			return float64(x)
+		case "TEXT", "DATE", "TIMESTAMP":
+			return strconv.FormatInt(x, 10)
+		}
+		return x
+	case float64:
+		switch typ {
+		case "FLOAT":
+			return x
+		case "TEXT", "DATE", "TIMESTAMP":
+			return strconv.FormatFloat(x, 'f', -1, 64)
+		default:
+			if x == float64(int64(x)) {
+				return int64(x)
+			}
+			return x
+		}
+	case string:
+		switch typ {
+		case "INT":
+			if n, err := strconv.ParseInt(x, 10, 64); err == nil {
+				return n
+			}
+		case "FLOAT":
+			if f, err := strconv.ParseFloat(x, 64); err == nil {
+				return f
+			}
+		case "BOOL":
+			if b, err := strconv.ParseBool(x); err == nil {
+				return b
+			}
+		}
+		return x
+	default:
+		return x
+	}
+}
+
+// CompareValues orders two cell values, returning -1, 0 or 1. Numbers
+// compare as numbers and strings as strings, without reading numbers out of
+// them, so '01' and '1' differ; false sorts before true. Values of different
+// kinds compare by their text, which keeps 30 and an untyped "30" equal.
+// NaN equals itself and sorts after every other number. NULL reads as the
+// empty string; callers that give it SQL meaning check for it first.
+func CompareValues(a, b interface{}) int {
+	a, b = NormalizeValue(a, ""), NormalizeValue(b, "")
+	switch x := a.(type) {
+	case int64:
+		switch y := b.(type) {
+		case int64:
+			return compareInts(x, y)
+		case float64:
+			return -compareFloatInt(y, x)
+		}
+	case float64:
+		switch y := b.(type) {
+		case int64:
+			return compareFloatInt(x, y)
+		case float64:
+			return compareFloats(x, y)
+		}
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y)
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			switch {
+			case x == y:
+				return 0
+			case y:
+				return -1
+			default:
+				return 1
+			}
+		}
+	}
+	return strings.Compare(valueText(a), valueText(b))
+}
+
+// ValueKey returns a key for a cell value that is the same for exactly
+// the values CompareValues finds equal, for use in hash maps. Keys of
+// non-NULL values order as the text they are compared by; NULL's key is
+// empty.
+func ValueKey(value interface{}) string {
+	value = NormalizeValue(value, "")
+	if value == nil {
+		return ""
+	}
+	return "=" + valueText(value)
+}
+
+// TypedKey is ValueKey for keys that must also tell values of different
+// kinds apart, such as those of UNIQUE constraints: 1 and '1' get different
+// keys, while 1 and 1.0 are the same number and share one.
+func TypedKey(value interface{}) string {
+	value = NormalizeValue(value, "")
+	switch value.(type) {
+	case nil:
+		return ""
+	case int64, float64:
+		return "n" + valueText(value)
+	case string:
+		return "s" + valueText(value)
+	default:
+		return "b" + valueText(value)
+	}
+}
+
+// valueText is the text a value compares by against a value of another
+// kind. Whole floats print as integers, so that 1.0 and 1 share it.
+func valueText(value interface{}) string {
+	switch x := value.(type) {
+	case nil:
+		return ""
+	case int64:
+		return strconv.FormatInt(x, 10)
+	case float64:
+		if n, ok := wholeFloat(x); ok {
+			return strconv.FormatInt(n, 10)
+		}
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case string:
+		return x
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+// wholeFloat returns f as an int64 when it is a whole number in range
+func wholeFloat(f float64) (int64, bool) {
+	if f != math.Trunc(f) || f < -(1<<63) || f >= 1<<63 {
+		return 0, false
+	}
+	return int64(f), true
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// compareFloats orders floats with NaN after every other number
+func compareFloats(a, b float64) int {
+	switch {
+	case math.IsNaN(a) || math.IsNaN(b):
+		return compareInts(nanRank(a), nanRank(b))
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func nanRank(f float64) int64 {
+	if math.IsNaN(f) {
+		return 1
+	}
+	return 0
+}
+
+// compareFloatInt orders a float and an integer without rounding the
+// integer to a float
+func compareFloatInt(f float64, n int64) int {
+	switch w, ok := wholeFloat(f); {
+	case ok:
+		return compareInts(w, n)
+	case f >= 1<<63:
+		return 1
+	case f < -(1 << 63):
+		return -1
+	}
+	// A fraction, whose magnitude is below 2^52, or NaN
+	return compareFloats(f, float64(n))
+}
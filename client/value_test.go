@@ -0,0 +1,132 @@
+package client
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeValue(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		typ   string
+		want  interface{}
+	}{
+		{7, "", int64(7)},
+		{uint8(7), "INT", int64(7)},
+		{float32(0.5), "", 0.5},
+		{2.0, "", int64(2)},
+		{2.0, "FLOAT", 2.0},
+		{int64(2), "FLOAT", 2.0},
+		{int64(2), "TEXT", "2"},
+		{2.5, "DATE", "2.5"},
+		{"12", "INT", int64(12)},
+		{"12", "", "12"},
+		{"1.5", "FLOAT", 1.5},
+		{"true", "BOOL", true},
+		{"maybe", "BOOL", "maybe"},
+		{json.Number("9007199254740993"), "", int64(9007199254740993)},
+		{json.Number("1e400"), "", "1e400"},
+		{nil, "INT", nil},
+	}
+	for _, test := range tests {
+		if got := NormalizeValue(test.value, test.typ); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%#v as %q: got %#v, want %#v", test.value, test.typ, got, test.want)
+		}
+	}
+}
+
+func TestCompareValues(t *testing.T) {
+	nan := math.NaN()
+	tests := []struct {
+		a, b interface{}
+		want int
+	}{
+		{int64(1), int64(2), -1},
+		{int64(2), 1.5, 1},
+		{1, 1.0, 0},
+		{int64(math.MaxInt64), float64(math.MaxInt64), -1},
+		{"b", "a", 1},
+		{"01", "1", -1},
+		{false, true, -1},
+		{true, true, 0},
+		{int64(30), "30", 0},
+		{"abc", int64(3), 1},
+		{nan, nan, 0},
+		{nan, math.Inf(1), 1},
+		{nil, "", 0},
+	}
+	for _, test := range tests {
+		if got := CompareValues(test.a, test.b); got != test.want {
+			t.Errorf("CompareValues(%#v, %#v) = %d, want %d", test.a, test.b, got, test.want)
+		}
+		if got := CompareValues(test.b, test.a); got != -test.want {
+			t.Errorf("CompareValues(%#v, %#v) = %d, want %d", test.b, test.a, got, -test.want)
+		}
+	}
+}
+
+func TestValueKeys(t *testing.T) {
+	tests := []struct {
+		a, b        interface{}
+		same, typed bool
+	}{
+		{int64(1), 1.0, true, true},
+		{int64(1), "1", true, false},
+		{"1", "01", false, false},
+		{true, "true", true, false},
+		{nil, "", false, false},
+		{nil, nil, true, true},
+	}
+	for _, test := range tests {
+		if same := ValueKey(test.a) == ValueKey(test.b); same != test.same {
+			t.Errorf("ValueKey(%#v) == ValueKey(%#v) is %v", test.a, test.b, same)
+		}
+		if same := TypedKey(test.a) == TypedKey(test.b); same != test.typed {
+			t.Errorf("TypedKey(%#v) == TypedKey(%#v) is %v", test.a, test.b, same)
+		}
+	}
+}
+
+func TestApplyTypes(t *testing.T) {
+	var resp Response
+	data := `{"columns":["n","f","s","b","u"],"types":["INT","FLOAT","TEXT","BOOL",""],
+		"rows":[{"id":1,"data":[3,2,5,true,4]},{"id":2,"data":[null,0.5,"x",false,4.5]}]}`
+	if err := json.Unmarshal([]byte(data), &resp); err != nil {
+		t.Fatal(err)
+	}
+	resp.applyTypes()
+	want := [][]interface{}{
+		{int64(3), 2.0, "5", true, int64(4)},
+		{nil, 0.5, "x", false, 4.5},
+	}
+	for i, row := range resp.Rows {
+		if !reflect.DeepEqual(row.Data, want[i]) {
+			t.Errorf("row %d: got %#v, want %#v", i, row.Data, want[i])
+		}
+	}
+
+	row := resp.Rows[0]
+	if n, err := row.Get(resp.Columns, "N").Int(); err != nil || n != 3 {
+		t.Errorf("Get(N).Int() = %d, %v", n, err)
+	}
+	if f, err := row.Cell(1).Float(); err != nil || f != 2 {
+		t.Errorf("Cell(1).Float() = %v, %v", f, err)
+	}
+	if b, err := row.Get(resp.Columns, "b").Bool(); err != nil || !b {
+		t.Errorf("Get(b).Bool() = %v, %v", b, err)
+	}
+	if s := row.Get(resp.Columns, "s").String(); s != "5" {
+		t.Errorf("Get(s).String() = %q", s)
+	}
+	if v := row.Get(resp.Columns, "missing"); !v.IsNull() {
+		t.Errorf("Get(missing) = %v, want NULL", v)
+	}
+	if v := row.Cell(9); !v.IsNull() {
+		t.Errorf("Cell(9) = %v, want NULL", v)
+	}
+	if _, err := resp.Rows[1].Cell(0).Int(); err == nil {
+		t.Error("Int() of NULL: expected an error")
+	}
+}
@@ -4,10 +4,19 @@
 :- use_module(library(http/http_json)).
 :- dynamic table_schema/2.
 :- dynamic table_data/3.
+:- dynamic table_types/2.
+:- dynamic table_constraints/2.
+:- dynamic table_indexes/2.
+:- dynamic active_tx/2.
+:- dynamic tx_used/2.
 
 db_directory('db_files/').
 server_port(8081).
 
+% A transaction that goes this many seconds without a request is rolled
+% back, so a client that went away does not hold the tables forever
+tx_timeout(300).
+
 init_db :-
     db_directory(Dir),
     (exists_directory(Dir) -> true ; make_directory(Dir)).
@@ -16,6 +25,8 @@ init_db :-
 
 start_server :-
     init_db,
+    finish_pending_writes,
+    load_all_tables,
     server_port(Port),
     http_server(http_dispatch, [port(Port)]),
     format('Database server running on http://localhost:~w~n', [Port]),
@@ -30,38 +41,422 @@ handle_query(Request) :-
     process_query(QueryDict, Response),
     reply_json_dict(Response).
 
+% While a transaction is open, only requests naming it and requests that
+% only read are served. They change the facts in memory as usual but write
+% no files; commit saves every table, rollback puts back the facts saved by
+% begin. Reads from outside the transaction see its changes before it
+% commits, as there is only one copy of the tables. Beginning and ending a
+% transaction hold the db_tx mutex.
 process_query(Dict, Response) :-
+    expire_idle_tx,
+    Type = Dict.get(type),
+    (   Type = "capabilities"
+    ->  Response = _{status: "success", capabilities: ["transactions"]}
+    ;   Type = "begin"
+    ->  begin_handler(Response)
+    ;   Type = "commit"
+    ->  commit_handler(Dict, Response)
+    ;   Type = "rollback"
+    ->  rollback_handler(Dict, Response)
+    ;   tx_refused(Dict, Type, Message)
+    ->  Response = _{status: "error", message: Message}
+    ;   run_query(Dict, Response)
+    ).
+
+tx_refused(Dict, Type, Message) :-
+    (   Id = Dict.get(tx)
+    ->  \+ use_tx(Id),
+        Message = "Unknown transaction"
+    ;   active_tx(_, _),
+        \+ read_query(Type),
+        Message = "A transaction is in progress"
+    ).
+
+read_query("schema").
+read_query("references").
+read_query("list_tables").
+read_query("describe_table").
+read_query("select").
+
+% use_tx(+Id) succeeds if Id is the open transaction, noting that it was
+% used now
+use_tx(Id) :-
+    tx_snapshot(Id, Active, _),
+    get_time(Now),
+    retractall(tx_used(Active, _)),
+    assertz(tx_used(Active, Now)).
+
+tx_snapshot(Id, Active, Facts) :-
+    active_tx(Active, Facts),
+    atom_string(Active, Id).
+
+begin_handler(Response) :-
+    with_mutex(db_tx, begin_tx(Response)).
+
+begin_tx(Response) :-
+    (   active_tx(_, _)
+    ->  Response = _{status: "error", message: "A transaction is in progress"}
+    ;   gensym(tx, Id),
+        findall(Fact, table_fact(Fact), Facts),
+        get_time(Now),
+        assertz(active_tx(Id, Facts)),
+        assertz(tx_used(Id, Now)),
+        Response = _{status: "success", message: "Transaction started", tx: Id}
+    ).
+
+commit_handler(Dict, Response) :-
+    with_mutex(db_tx, commit_tx(Dict, Response)).
+
+commit_tx(Dict, Response) :-
+    (   Id = Dict.get(tx),
+        tx_snapshot(Id, Active, Facts)
+    ->  retract(active_tx(Active, _)),
+        retractall(tx_used(Active, _)),
+        findall(File,
+                ( table_schema(Table, _),
+                  table_file_kind(Table, File)
+                ),
+                Saves),
+        findall(File,
+                ( member(table_schema(Table, _), Facts),
+                  \+ table_schema(Table, _),
+                  table_file_kind(Table, File)
+                ),
+                Deletes),
+        save_table_files(Saves, Deletes),
+        Response = _{status: "success", message: "Transaction committed"}
+    ;   Response = _{status: "error", message: "Unknown transaction"}
+    ).
+
+rollback_handler(Dict, Response) :-
+    with_mutex(db_tx, rollback_tx(Dict, Response)).
+
+rollback_tx(Dict, Response) :-
+    (   Id = Dict.get(tx),
+        tx_snapshot(Id, Active, Facts)
+    ->  undo_tx(Active, Facts),
+        Response = _{status: "success", message: "Transaction rolled back"}
+    ;   Response = _{status: "error", message: "Unknown transaction"}
+    ).
+
+% Rolls back the open transaction if it went unused for longer than
+% tx_timeout
+expire_idle_tx :-
+    with_mutex(db_tx,
+               (   active_tx(Active, Facts),
+                   tx_used(Active, Used),
+                   tx_timeout(Timeout),
+                   get_time(Now),
+                   Now - Used > Timeout
+               ->  undo_tx(Active, Facts)
+               ;   true
+               )).
+
+undo_tx(Active, Facts) :-
+    retract(active_tx(Active, _)),
+    retractall(tx_used(Active, _)),
+    retractall(table_schema(_, _)),
+    retractall(table_types(_, _)),
+    retractall(table_constraints(_, _)),
+    retractall(table_indexes(_, _)),
+    retractall(table_data(_, _, _)),
+    forall(member(Fact, Facts), assertz(Fact)).
+
+table_fact(table_schema(Table, Columns)) :- table_schema(Table, Columns).
+table_fact(table_types(Table, Types)) :- table_types(Table, Types).
+table_fact(table_constraints(Table, Constraints)) :- table_constraints(Table, Constraints).
+table_fact(table_indexes(Table, Indexes)) :- table_indexes(Table, Indexes).
+table_fact(table_data(Table, Id, Data)) :- table_data(Table, Id, Data).
+
+run_query(Dict, Response) :-
     Type = Dict.get(type),
     (   Type = "create_table"
     ->  create_table_handler(Dict, Response)
+    ;   Type = "schema"
+    ->  schema_handler(Dict, Response)
+    ;   Type = "references"
+    ->  references_handler(Dict, Response)
+    ;   Type = "list_tables"
+    ->  list_tables_handler(Dict, Response)
+    ;   Type = "describe_table"
+    ->  describe_table_handler(Dict, Response)
     ;   Type = "insert"
     ->  insert_handler(Dict, Response)
+    ;   Type = "insert_many"
+    ->  insert_many_handler(Dict, Response)
     ;   Type = "select"
     ->  select_handler(Dict, Response)
     ;   Type = "update"
     ->  update_handler(Dict, Response)
     ;   Type = "delete"
     ->  delete_handler(Dict, Response)
+    ;   Type = "drop_table"
+    ->  drop_table_handler(Dict, Response)
+    ;   Type = "truncate"
+    ->  truncate_handler(Dict, Response)
+    ;   Type = "add_column"
+    ->  add_column_handler(Dict, Response)
+    ;   Type = "drop_column"
+    ->  drop_column_handler(Dict, Response)
+    ;   Type = "rename_column"
+    ->  rename_column_handler(Dict, Response)
+    ;   Type = "rename_table"
+    ->  rename_table_handler(Dict, Response)
+    ;   Type = "create_index"
+    ->  create_index_handler(Dict, Response)
+    ;   Type = "drop_index"
+    ->  drop_index_handler(Dict, Response)
     ;   Response = _{status: "error", message: "Unknown query type"}
     ).
 
+% Columns are {name, type} objects; a plain string is an untyped column,
+% as older clients send. Constraints are stored as given and enforced by
+% the client.
 create_table_handler(Dict, Response) :-
     Table = Dict.get(table),
-    Columns = Dict.get(columns),
+    Specs = Dict.get(columns),
+    Constraints = Dict.get(constraints, []),
+    maplist(column_spec, Specs, Columns, Types),
     (   table_schema(Table, _)
     ->  Response = _{status: "error", message: "Table already exists"}
     ;   assert(table_schema(Table, Columns)),
+        assert(table_types(Table, Types)),
+        assert(table_constraints(Table, Constraints)),
         save_schema(Table),
         Response = _{status: "success", message: "Table created", table: Table}
     ).
 
+drop_table_handler(Dict, Response) :-
+    Table = Dict.get(table),
+    IfExists = Dict.get(if_exists, false),
+    (   table_schema(Table, _)
+    ->  retractall(table_schema(Table, _)),
+        retractall(table_types(Table, _)),
+        retractall(table_constraints(Table, _)),
+        retractall(table_indexes(Table, _)),
+        retractall(table_data(Table, _, _)),
+        save_table_files([], [Table-'_schema.pl', Table-'_data.pl']),
+        Response = _{status: "success", message: "Table dropped", table: Table}
+    ;   IfExists == true
+    ->  Response = _{status: "success", message: "Table does not exist, skipped", table: Table}
+    ;   Response = _{status: "error", message: "Table does not exist"}
+    ).
+
+truncate_handler(Dict, Response) :-
+    Table = Dict.get(table),
+    (   table_schema(Table, _)
+    ->  aggregate_all(count, table_data(Table, _, _), Count),
+        retractall(table_data(Table, _, _)),
+        delete_table_file(Table, '_data.pl'),
+        Response = _{status: "success", message: "Table truncated", table: Table, count: Count}
+    ;   Response = _{status: "error", message: "Table does not exist"}
+    ).
+
+% Appends a column, filling existing rows with its DEFAULT value or null
+add_column_handler(Dict, Response) :-
+    Table = Dict.get(table),
+    column_spec(Dict.get(column), Column, Type),
+    Constraints = Dict.get(constraints, []),
+    (   \+ table_schema(Table, _)
+    ->  Response = _{status: "error", message: "Table does not exist"}
+    ;   table_schema(Table, Columns),
+        column_index(Columns, Column, _)
+    ->  Response = _{status: "error", message: "Column already exists"}
+    ;   table_schema(Table, Columns),
+        column_types(Table, Types),
+        column_default(Constraints, Column, Default),
+        append(Columns, [Column], NewColumns),
+        append(Types, [Type], NewTypes),
+        rewrite_rows(Table, [Data, NewData]>>append(Data, [Default], NewData), Count),
+        replace_schema(Table, NewColumns, NewTypes, Constraints),
+        save_table_files([Table-'_data.pl', Table-'_schema.pl'], []),
+        Response = _{status: "success", message: "Column added", table: Table, count: Count}
+    ).
+
+drop_column_handler(Dict, Response) :-
+    Table = Dict.get(table),
+    Column = Dict.get(column),
+    Constraints = Dict.get(constraints, []),
+    (   table_schema(Table, Columns),
+        column_index(Columns, Column, Idx)
+    ->  column_types(Table, Types),
+        nth0(Idx, Columns, _, NewColumns),
+        nth0(Idx, Types, _, NewTypes),
+        rewrite_rows(Table, [Data, NewData]>>nth0(Idx, Data, _, NewData), Count),
+        replace_schema(Table, NewColumns, NewTypes, Constraints),
+        atom_string(Column, Name),
+        rewrite_indexes(Table, index_without(Name), =),
+        save_table_files([Table-'_data.pl', Table-'_schema.pl'], []),
+        Response = _{status: "success", message: "Column dropped", table: Table, count: Count}
+    ;   Response = _{status: "error", message: "Table or column does not exist"}
+    ).
+
+% Renames a column; rows are positional and need no rewrite, but foreign
+% keys of other tables that reference the column are updated
+rename_column_handler(Dict, Response) :-
+    Table = Dict.get(table),
+    Column = Dict.get(column),
+    NewName = Dict.get(new_name),
+    Constraints = Dict.get(constraints, []),
+    (   table_schema(Table, Columns),
+        column_index(Columns, Column, Idx)
+    ->  (   column_index(Columns, NewName, Other),
+            Other =\= Idx
+        ->  Response = _{status: "error", message: "Column already exists"}
+        ;   column_types(Table, Types),
+            nth0(Idx, Columns, _, Rest),
+            nth0(Idx, NewColumns, NewName, Rest),
+            replace_schema(Table, NewColumns, Types, Constraints),
+            atom_string(Column, Name),
+            rewrite_indexes(Table, [_]>>true, rename_index_column(Name, NewName)),
+            rename_references(Table, Table, Column, NewName, Children),
+            schema_files([Table|Children], Saves),
+            save_table_files(Saves, []),
+            Response = _{status: "success", message: "Column renamed", table: Table}
+        )
+    ;   Response = _{status: "error", message: "Table or column does not exist"}
+    ).
+
+% Renames a table: its facts move to the new name and foreign keys that
+% reference it are updated. Its files under the new name, the old ones and
+% the schemas of the referencing tables change together.
+rename_table_handler(Dict, Response) :-
+    Table = Dict.get(table),
+    NewName = Dict.get(new_name),
+    (   \+ table_schema(Table, _)
+    ->  Response = _{status: "error", message: "Table does not exist"}
+    ;   table_schema(NewName, _)
+    ->  Response = _{status: "error", message: "Table already exists"}
+    ;   table_schema(Table, Columns),
+        column_types(Table, Types),
+        column_constraints(Table, Constraints),
+        replace_schema(NewName, Columns, Types, Constraints),
+        forall(retract(table_indexes(Table, Indexes)),
+               assert(table_indexes(NewName, Indexes))),
+        forall(retract(table_data(Table, Id, Data)),
+               assert(table_data(NewName, Id, Data))),
+        retractall(table_schema(Table, _)),
+        retractall(table_types(Table, _)),
+        retractall(table_constraints(Table, _)),
+        rename_references(Table, NewName, none, none, Children),
+        schema_files(Children, ChildSaves),
+        save_table_files([NewName-'_data.pl', NewName-'_schema.pl'|ChildSaves],
+                         [Table-'_schema.pl', Table-'_data.pl']),
+        Response = _{status: "success", message: "Table renamed", table: NewName}
+    ).
+
+% Indexes are stored with the schema for clients to see, and nothing else:
+% this server never uses them for lookups, match_where scans every row of
+% the table. Only the Go engine (storage.Engine) looks rows up through its
+% indexes. Index names are unique across tables, and a unique index is
+% refused while two rows repeat its key.
+create_index_handler(Dict, Response) :-
+    Table = Dict.get(table),
+    Spec = Dict.get(index),
+    Name = Spec.get(name),
+    IndexColumns = Spec.get(columns, []),
+    Unique = Spec.get(unique, false),
+    (   \+ table_schema(Table, _)
+    ->  Response = _{status: "error", message: "Table does not exist"}
+    ;   index_owner(Name, _)
+    ->  Response = _{status: "error", message: "Index already exists"}
+    ;   table_schema(Table, Columns),
+        (   IndexColumns == []
+        ;   member(Column, IndexColumns),
+            \+ column_index(Columns, Column, _)
+        )
+    ->  Response = _{status: "error", message: "Table or column does not exist"}
+    ;   Unique == true,
+        table_schema(Table, Columns),
+        maplist([C, I]>>column_index(Columns, C, I), IndexColumns, Idxs),
+        findall(Key,
+                (   table_data(Table, _, Data),
+                    maplist([I, V]>>nth0(I, Data, V), Idxs, Key),
+                    \+ memberchk(null, Key)
+                ),
+                Keys),
+        sort(Keys, Distinct),
+        length(Keys, N),
+        \+ length(Distinct, N)
+    ->  Response = _{status: "error", message: "Duplicate key for unique index"}
+    ;   column_indexes(Table, Indexes),
+        append(Indexes, [_{name: Name, columns: IndexColumns, unique: Unique}], NewIndexes),
+        retractall(table_indexes(Table, _)),
+        assert(table_indexes(Table, NewIndexes)),
+        save_schema(Table),
+        Response = _{status: "success", message: "Index created", table: Table}
+    ).
+
+drop_index_handler(Dict, Response) :-
+    Name = Dict.get(name),
+    IfExists = Dict.get(if_exists, false),
+    (   index_owner(Name, Table)
+    ->  rewrite_indexes(Table, [I]>>(\+ index_named(Name, I)), =),
+        save_schema(Table),
+        Response = _{status: "success", message: "Index dropped", table: Table}
+    ;   IfExists == true
+    ->  Response = _{status: "success", message: "Index does not exist, skipped"}
+    ;   Response = _{status: "error", message: "Index does not exist"}
+    ).
+
+schema_handler(Dict, Response) :-
+    Table = Dict.get(table),
+    (   table_schema(Table, Columns)
+    ->  column_types(Table, Types),
+        column_constraints(Table, Constraints),
+        column_indexes(Table, Indexes),
+        Response = _{status: "success", table: Table, columns: Columns, types: Types,
+                     constraints: Constraints, indexes: Indexes}
+    ;   Response = _{status: "error", message: "Table does not exist"}
+    ).
+
+list_tables_handler(_Dict, Response) :-
+    findall(Table, table_schema(Table, _), Unsorted),
+    sort(Unsorted, Tables),
+    findall(_{name: Table, columns: Columns, types: Types, count: Count},
+            (   member(Table, Tables),
+                table_schema(Table, Columns),
+                column_types(Table, Types),
+                aggregate_all(count, table_data(Table, _, _), Count)
+            ),
+            Infos),
+    Response = _{status: "success", tables: Infos}.
+
+describe_table_handler(Dict, Response) :-
+    Table = Dict.get(table),
+    (   table_schema(Table, Columns)
+    ->  column_types(Table, Types),
+        column_constraints(Table, Constraints),
+        column_indexes(Table, Indexes),
+        aggregate_all(count, table_data(Table, _, _), Count),
+        Response = _{status: "success", table: Table, columns: Columns, types: Types,
+                     constraints: Constraints, indexes: Indexes, row_count: Count}
+    ;   Response = _{status: "error", message: "Table does not exist"}
+    ).
+
+% Lists the foreign keys of every table that reference Table, each tagged
+% with the table it belongs to
+references_handler(Dict, Response) :-
+    atom_string(Table, Dict.get(table)),
+    findall(Constraint,
+            (   table_constraints(Child, Constraints),
+                member(C, Constraints),
+                C.get(type) == "foreign_key",
+                atom_string(Table, C.get(references).get(table)),
+                atom_string(Child, ChildName),
+                Constraint = C.put(table, ChildName)
+            ),
+            References),
+    Response = _{status: "success", table: Table, constraints: References}.
+
 insert_handler(Dict, Response) :-
     Table = Dict.get(table),
     Values = Dict.get(values),
     (   table_schema(Table, Columns)
     ->  (   validate_values(Columns, Values)
         ->  get_next_id(Table, Id),
-            assert(table_data(Table, Id, Values)),
+            row_data(Columns, Values, Data),
+            assert(table_data(Table, Id, Data)),
             save_table_data(Table),
             Response = _{status: "success", message: "Record inserted", id: Id}
         ;   Response = _{status: "error", message: "Invalid values for table schema"}
@@ -69,14 +464,70 @@ insert_handler(Dict, Response) :-
     ;   Response = _{status: "error", message: "Table does not exist"}
     ).
 
+% Inserts a batch of rows and saves the table file once for the whole
+% batch. Nothing is inserted unless every row is valid. The rows take the
+% IDs listed in ids, one each, if there is such a list, else the next free
+% ones; the response lists them.
+insert_many_handler(Dict, Response) :-
+    Table = Dict.get(table),
+    Rows = Dict.get(rows),
+    Given = Dict.get(ids, none),
+    (   \+ table_schema(Table, _)
+    ->  Response = _{status: "error", message: "Table does not exist"}
+    ;   table_schema(Table, Columns),
+        \+ forall(member(Values, Rows), validate_values(Columns, Values))
+    ->  Response = _{status: "error", message: "Invalid values for table schema"}
+    ;   given_ids_error(Table, Given, Rows, Message)
+    ->  Response = _{status: "error", message: Message}
+    ;   table_schema(Table, Columns),
+        (   Given == none
+        ->  maplist(insert_next_row(Table, Columns), Rows, Ids)
+        ;   Ids = Given,
+            maplist(insert_row(Table, Columns), Ids, Rows)
+        ),
+        save_table_data(Table),
+        length(Rows, Count),
+        Response = _{status: "success", message: "Records inserted", count: Count, ids: Ids}
+    ).
+
+insert_next_row(Table, Columns, Values, Id) :-
+    get_next_id(Table, Id),
+    insert_row(Table, Columns, Id, Values).
+
+insert_row(Table, Columns, Id, Values) :-
+    row_data(Columns, Values, Data),
+    assert(table_data(Table, Id, Data)).
+
+% given_ids_error(+Table, +Ids, +Rows, -Message) succeeds if the IDs given
+% for a batch of rows can't be used: there must be one positive ID per row,
+% none of them taken.
+given_ids_error(_, none, _, _) :- !, fail.
+given_ids_error(_, Ids, Rows, "Invalid ids") :-
+    (   \+ is_list(Ids)
+    ;   \+ same_length(Ids, Rows)
+    ;   member(Id, Ids),
+        \+ ( integer(Id), Id > 0 )
+    ), !.
+given_ids_error(Table, Ids, _, "Record already exists") :-
+    (   \+ is_set(Ids)
+    ;   member(Id, Ids),
+        table_data(Table, Id, _)
+    ), !.
+
+% Selects the rows matching where; an ids list keeps to the rows it names
 select_handler(Dict, Response) :-
     Table = Dict.get(table),
     Where = Dict.get(where, _{}),
+    Selected = Dict.get(ids, all),
     (   table_schema(Table, Columns)
     ->  findall(_{id: Id, data: Data}, 
-                (table_data(Table, Id, Data), match_where(Data, Columns, Where)),
+                (   table_data(Table, Id, Data),
+                    id_selected(Id, Selected),
+                    match_where(Data, Columns, Where)
+                ),
                 Results),
-        Response = _{status: "success", table: Table, columns: Columns, rows: Results}
+        column_types(Table, Types),
+        Response = _{status: "success", table: Table, columns: Columns, types: Types, rows: Results}
     ;   Response = _{status: "error", message: "Table does not exist"}
     ).
 
@@ -84,9 +535,13 @@ update_handler(Dict, Response) :-
     Table = Dict.get(table),
     Set = Dict.get(set),
     Where = Dict.get(where, _{}),
+    Selected = Dict.get(ids, all),
     (   table_schema(Table, Columns)
     ->  findall(Id, 
-                (table_data(Table, Id, Data), match_where(Data, Columns, Where)),
+                (   table_data(Table, Id, Data),
+                    id_selected(Id, Selected),
+                    match_where(Data, Columns, Where)
+                ),
                 Ids),
         length(Ids, Count),
         update_records(Table, Ids, Set, Columns),
@@ -98,9 +553,13 @@ update_handler(Dict, Response) :-
 delete_handler(Dict, Response) :-
     Table = Dict.get(table),
     Where = Dict.get(where, _{}),
+    Selected = Dict.get(ids, all),
     (   table_schema(Table, Columns)
     ->  findall(Id, 
-                (table_data(Table, Id, Data), match_where(Data, Columns, Where)),
+                (   table_data(Table, Id, Data),
+                    id_selected(Id, Selected),
+                    match_where(Data, Columns, Where)
+                ),
                 Ids),
         length(Ids, Count),
         delete_records(Table, Ids),
@@ -109,11 +568,22 @@ delete_handler(Dict, Response) :-
     ;   Response = _{status: "error", message: "Table does not exist"}
     ).
 
+% Values is a column name -> value object naming only schema columns.
 validate_values(Columns, Values) :-
+    is_dict(Values),
     dict_keys(Values, ValueKeys),
-    sort(ValueKeys, SortedKeys),
-    sort(Columns, SortedCols),
-    subset(SortedKeys, SortedCols).
+    forall(member(Key, ValueKeys), column_index(Columns, Key, _)).
+
+% Orders the values of a row object by schema column; missing ones are null.
+row_data(Columns, Values, Data) :-
+    maplist(column_value(Values), Columns, Data).
+
+column_value(Values, Column, Value) :-
+    atom_string(Key, Column),
+    (   get_dict(Key, Values, Value0)
+    ->  Value = Value0
+    ;   Value = null
+    ).
 
 get_next_id(Table, Id) :-
     findall(ExistingId, table_data(Table, ExistingId, _), Ids),
@@ -123,15 +593,76 @@ get_next_id(Table, Id) :-
         Id is MaxId + 1
     ).
 
+% Checks a row against a where object. Selects, updates and deletes call
+% it on every row of the table; indexes are not consulted.
 match_where(_, _, Where) :-
     dict_keys(Where, []), !.
 match_where(Data, Columns, Where) :-
     dict_pairs(Where, _, Pairs),
-    forall(member(Key-Value, Pairs),
-           (   nth0(Idx, Columns, Key),
-               nth0(Idx, Data, Value)
+    forall(member(Key-Condition, Pairs),
+           (   column_index(Columns, Key, Idx),
+               nth0(Idx, Data, Value),
+               match_condition(Condition, Value)
            )).
 
+% A condition is either a plain value (equality) or an object
+% {"op": Op, "value": Expected} with Op one of = != < > <= >=.
+match_condition(Condition, Value) :-
+    is_dict(Condition), !,
+    Op = Condition.get(op),
+    Expected = Condition.get(value),
+    compare_values(Op, Value, Expected).
+match_condition(Expected, Value) :-
+    compare_values("=", Value, Expected).
+
+% Values that both read as numbers compare numerically, so 30 and "30"
+% are equal; anything else compares as text. Nothing compares to null.
+compare_values(Op, A, B) :-
+    A \== null,
+    B \== null,
+    (   numeric_value(A, NA),
+        numeric_value(B, NB)
+    ->  (   NA < NB -> Order = (<)
+        ;   NA > NB -> Order = (>)
+        ;   Order = (=)
+        )
+    ;   text_value(A, TA),
+        text_value(B, TB),
+        compare(Order, TA, TB)
+    ),
+    order_satisfies(Op, Order).
+
+numeric_value(X, X) :-
+    number(X), !.
+numeric_value(X, N) :-
+    string(X),
+    catch(number_string(N, X), _, fail).
+
+text_value(X, X) :-
+    string(X), !.
+text_value(X, S) :-
+    term_string(X, S).
+
+order_satisfies("=", =).
+order_satisfies("!=", <).
+order_satisfies("!=", >).
+order_satisfies("<", <).
+order_satisfies(">", >).
+order_satisfies("<=", <).
+order_satisfies("<=", =).
+order_satisfies(">=", >).
+order_satisfies(">=", =).
+
+% Requests may name the rows to touch explicitly with an "ids" list.
+id_selected(_, all) :- !.
+id_selected(Id, Ids) :-
+    memberchk(Id, Ids).
+
+% Dict keys arrive as atoms while schema columns are stored as strings.
+column_index(Columns, Key, Idx) :-
+    atom_string(Key, Name),
+    nth0(Idx, Columns, Name).
+
 update_records(_, [], _, _).
 update_records(Table, [Id|Ids], Set, Columns) :-
     retract(table_data(Table, Id, OldData)),
@@ -144,7 +675,7 @@ update_data(OldData, Set, Columns, NewData) :-
     foldl(update_field(Columns), Pairs, OldData, NewData).
 
 update_field(Columns, Key-Value, Data, UpdatedData) :-
-    nth0(Idx, Columns, Key),
+    column_index(Columns, Key, Idx),
     replace_nth(Idx, Data, Value, UpdatedData).
 
 replace_nth(0, [_|T], X, [X|T]) :- !.
@@ -158,31 +689,288 @@ delete_records(Table, [Id|Ids]) :-
     retractall(table_data(Table, Id, _)),
     delete_records(Table, Ids).
 
-save_schema(Table) :-
+table_file(Table, Suffix, FilePath) :-
     db_directory(Dir),
     atom_concat(Dir, Table, BasePath),
-    atom_concat(BasePath, '_schema.pl', FilePath),
+    atom_concat(BasePath, Suffix, FilePath).
+
+% Replaces the schema facts of a table
+replace_schema(Table, Columns, Types, Constraints) :-
+    retractall(table_schema(Table, _)),
+    retractall(table_types(Table, _)),
+    retractall(table_constraints(Table, _)),
+    assert(table_schema(Table, Columns)),
+    assert(table_types(Table, Types)),
+    assert(table_constraints(Table, Constraints)).
+
+% The table the index Name belongs to
+index_owner(Name, Table) :-
+    table_indexes(Table, Indexes),
+    member(I, Indexes),
+    index_named(Name, I),
+    !.
+
+index_named(Name, I) :-
+    I.get(name) == Name.
+
+index_without(Column, I) :-
+    \+ memberchk(Column, I.get(columns)).
+
+rename_index_column(Column, NewColumn, I, Renamed) :-
+    maplist([Col, NewCol]>>(Col == Column -> NewCol = NewColumn ; NewCol = Col),
+            I.get(columns), Columns),
+    Renamed = I.put(columns, Columns).
+
+% Keeps the indexes of a table that satisfy Keep, each rewritten by
+% Goal(Index, NewIndex)
+rewrite_indexes(Table, Keep, Goal) :-
+    column_indexes(Table, Indexes),
+    include(Keep, Indexes, Kept),
+    maplist(Goal, Kept, NewIndexes),
+    retractall(table_indexes(Table, _)),
+    assert(table_indexes(Table, NewIndexes)).
+
+% Applies Goal(Data, NewData) to every row of a table
+rewrite_rows(Table, Goal, Count) :-
+    findall(Id-Data, table_data(Table, Id, Data), Rows),
+    retractall(table_data(Table, _, _)),
+    forall(member(Id-Data, Rows),
+           (   call(Goal, Data, NewData),
+               assert(table_data(Table, Id, NewData))
+           )),
+    length(Rows, Count).
+
+% The DEFAULT value of a column, or null
+column_default(Constraints, Column, Default) :-
+    (   member(C, Constraints),
+        C.get(type) == "default",
+        C.get(columns) == [Column]
+    ->  Default = C.get(default)
+    ;   Default = null
+    ).
+
+% Updates the foreign keys that reference Table after it was renamed to
+% NewTable, or after its column Column was renamed to NewColumn (both none
+% for a table rename). Children are the tables whose constraints change,
+% for the caller to save.
+rename_references(Table, NewTable, Column, NewColumn, Children) :-
+    atom_string(Table, TableName),
+    atom_string(NewTable, NewTableName),
+    findall(Child-Renamed,
+            ( table_constraints(Child, Constraints),
+              maplist(rename_reference(TableName, NewTableName, Column, NewColumn), Constraints, Renamed),
+              Renamed \== Constraints
+            ),
+            Changes),
+    forall(member(Child-Renamed, Changes),
+           ( retractall(table_constraints(Child, _)),
+             assert(table_constraints(Child, Renamed))
+           )),
+    pairs_keys(Changes, Children).
+
+rename_reference(Table, NewTable, Column, NewColumn, C, Renamed) :-
+    (   C.get(type) == "foreign_key",
+        Ref = C.get(references),
+        atom_string(RefTable, Ref.get(table)),
+        atom_string(RefTable, Table)
+    ->  (   Column == none
+        ->  Renamed = C.put(references, Ref.put(table, NewTable))
+        ;   maplist([Col, NewCol]>>(Col == Column -> NewCol = NewColumn ; NewCol = Col),
+                    Ref.get(columns), Columns),
+            Renamed = C.put(references, Ref.put(columns, Columns))
+        )
+    ;   Renamed = C
+    ).
+
+% Files are left alone during a transaction; commit brings them up to date
+delete_table_file(_, _) :-
+    active_tx(_, _),
+    !.
+delete_table_file(Table, Suffix) :-
+    table_file(Table, Suffix, FilePath),
+    (   exists_file(FilePath)
+    ->  delete_file(FilePath)
+    ;   true
+    ).
+
+% Files are written to a temporary file that then replaces the old one, so
+% a crash never leaves a half-written table behind
+write_table_file(FilePath, Goal) :-
+    atom_concat(FilePath, '.tmp', TmpPath),
+    setup_call_cleanup(open(TmpPath, write, Stream),
+                       call(Goal, Stream),
+                       close(Stream)),
+    rename_file(TmpPath, FilePath).
+
+% Changes that span several files are made all or not at all. Saves and
+% Deletes are Table-Suffix pairs. Each file saved is first written next to
+% its final place, then the plan of renames and deletions left to do is
+% written, and only then carried out. A crash before the plan is in place
+% leaves the old files; one after it is finished by finish_pending_writes
+% on the next start.
+save_table_files(_, _) :-
+    active_tx(_, _),
+    !.
+save_table_files(Saves, Deletes) :-
+    maplist(write_pending_file, Saves, Renames),
+    findall(delete(FilePath),
+            ( member(Table-Suffix, Deletes),
+              table_file(Table, Suffix, FilePath)
+            ),
+            Removals),
+    append(Renames, Removals, Steps),
+    pending_writes_file(Plan),
+    write_table_file(Plan, write_steps(Steps)),
+    maplist(finish_step, Steps),
+    delete_file(Plan).
+
+write_pending_file(Table-Suffix, rename(TmpPath, FilePath)) :-
+    table_file(Table, Suffix, FilePath),
+    atom_concat(FilePath, '.tmp', TmpPath),
+    table_file_writer(Suffix, Table, Goal),
+    setup_call_cleanup(open(TmpPath, write, Stream),
+                       call(Goal, Stream),
+                       close(Stream)).
+
+table_file_writer('_schema.pl', Table, write_schema(Table)).
+table_file_writer('_data.pl', Table, write_table_data(Table)).
+
+table_file_kind(Table, Table-'_schema.pl').
+table_file_kind(Table, Table-'_data.pl').
+
+schema_files(Tables, Files) :-
+    sort(Tables, Unique),
+    findall(Table-'_schema.pl', member(Table, Unique), Files).
+
+write_steps(Steps, Stream) :-
+    forall(member(Step, Steps), format(Stream, '~q.~n', [Step])).
+
+% Steps can be repeated: a rename whose file is gone was already done
+finish_step(rename(TmpPath, FilePath)) :-
+    (   exists_file(TmpPath)
+    ->  rename_file(TmpPath, FilePath)
+    ;   true
+    ).
+finish_step(delete(FilePath)) :-
+    (   exists_file(FilePath)
+    ->  delete_file(FilePath)
+    ;   true
+    ).
+
+% The plan is not a .pl file, so load_all_tables never reads it
+pending_writes_file(Plan) :-
+    db_directory(Dir),
+    atom_concat(Dir, 'pending_writes', Plan).
+
+% Finishes the changes a crash interrupted after their plan was written,
+% and removes the files of changes it interrupted before
+finish_pending_writes :-
+    pending_writes_file(Plan),
+    (   exists_file(Plan)
+    ->  setup_call_cleanup(open(Plan, read, Stream),
+                           read_steps(Stream, Steps),
+                           close(Stream)),
+        maplist(finish_step, Steps),
+        delete_file(Plan)
+    ;   true
+    ),
+    db_directory(Dir),
+    atom_concat(Dir, '*.tmp', Pattern),
+    expand_file_name(Pattern, Leftovers),
+    forall(member(File, Leftovers), delete_file(File)).
+
+read_steps(Stream, Steps) :-
+    read_term(Stream, Step, []),
+    (   Step == end_of_file
+    ->  Steps = []
+    ;   Steps = [Step|Rest],
+        read_steps(Stream, Rest)
+    ).
+
+save_schema(_) :-
+    active_tx(_, _),
+    !.
+save_schema(Table) :-
+    table_file(Table, '_schema.pl', FilePath),
+    write_table_file(FilePath, write_schema(Table)).
+
+write_schema(Table, Stream) :-
     table_schema(Table, Columns),
-    open(FilePath, write, Stream),
+    column_types(Table, Types),
+    column_constraints(Table, Constraints),
     format(Stream, ':- dynamic table_schema/2.~n', []),
+    format(Stream, ':- dynamic table_types/2.~n', []),
+    column_indexes(Table, Indexes),
+    format(Stream, ':- dynamic table_constraints/2.~n', []),
+    format(Stream, ':- dynamic table_indexes/2.~n', []),
     format(Stream, 'table_schema(~q, ~q).~n', [Table, Columns]),
-    close(Stream).
+    format(Stream, 'table_types(~q, ~q).~n', [Table, Types]),
+    format(Stream, 'table_constraints(~q, ~q).~n', [Table, Constraints]),
+    format(Stream, 'table_indexes(~q, ~q).~n', [Table, Indexes]).
+
+column_spec(Spec, Name, Type) :-
+    is_dict(Spec),
+    !,
+    Name = Spec.get(name),
+    Type = Spec.get(type, "").
+column_spec(Name, Name, "").
 
+column_constraints(Table, Constraints) :-
+    (   table_constraints(Table, Constraints)
+    ->  true
+    ;   Constraints = []
+    ).
+
+column_indexes(Table, Indexes) :-
+    (   table_indexes(Table, Indexes)
+    ->  true
+    ;   Indexes = []
+    ).
+
+% Tables saved before column types existed are untyped
+column_types(Table, Types) :-
+    (   table_types(Table, Types)
+    ->  true
+    ;   table_schema(Table, Columns),
+        maplist([_, ""]>>true, Columns, Types)
+    ).
+
+save_table_data(_) :-
+    active_tx(_, _),
+    !.
 save_table_data(Table) :-
-    db_directory(Dir),
-    atom_concat(Dir, Table, BasePath),
-    atom_concat(BasePath, '_data.pl', FilePath),
-    open(FilePath, write, Stream),
+    table_file(Table, '_data.pl', FilePath),
+    write_table_file(FilePath, write_table_data(Table)).
+
+write_table_data(Table, Stream) :-
     format(Stream, ':- dynamic table_data/3.~n', []),
     forall(table_data(Table, Id, Data),
-           format(Stream, 'table_data(~q, ~w, ~q).~n', [Table, Id, Data])),
-    close(Stream).
+           format(Stream, 'table_data(~q, ~w, ~q).~n', [Table, Id, Data])).
 
+% Loads every saved table. The facts are read and asserted rather than
+% consulted, so that loading one table's file never replaces the clauses
+% loaded from another.
 load_all_tables :-
     db_directory(Dir),
     atom_concat(Dir, '*.pl', Pattern),
     expand_file_name(Pattern, Files),
-    forall(member(File, Files), consult(File)).
+    forall(member(File, Files), load_table_file(File)).
+
+load_table_file(File) :-
+    setup_call_cleanup(open(File, read, Stream),
+                       load_terms(Stream),
+                       close(Stream)).
+
+load_terms(Stream) :-
+    read_term(Stream, Term, []),
+    (   Term == end_of_file
+    ->  true
+    ;   load_term(Term),
+        load_terms(Stream)
+    ).
+
+load_term((:- _)) :- !.
+load_term(Fact) :- assertz(Fact).
 
 :- initialization(init_db).
 
@@ -192,11 +980,19 @@ load_all_tables :-
 % Then use curl or any HTTP client:
 % curl -X POST http://localhost:8080/query \
 %   -H "Content-Type: application/json" \
-%   -d '{"type":"create_table","table":"users","columns":["name","email","age"]}'
+%   -d '{"type":"create_table","table":"users","columns":[{"name":"name","type":"TEXT"},{"name":"email","type":"TEXT"},{"name":"age","type":"INT"}]}'
+%
+% curl -X POST http://localhost:8080/query \
+%   -H "Content-Type: application/json" \
+%   -d '{"type":"insert","table":"users","values":{"name":"John Doe","email":"john@example.com","age":30}}'
 %
 % curl -X POST http://localhost:8080/query \
 %   -H "Content-Type: application/json" \
-%   -d '{"type":"insert","table":"users","values":["John Doe","john@example.com",30]}'
+%   -d '{"type":"schema","table":"users"}'
+%
+% curl -X POST http://localhost:8080/query \
+%   -H "Content-Type: application/json" \
+%   -d '{"type":"insert_many","table":"users","rows":[{"name":"Ann"},{"name":"Bob"}]}'
 %
 % curl -X POST http://localhost:8080/query \
 %   -H "Content-Type: application/json" \
@@ -204,8 +1000,52 @@ load_all_tables :-
 %
 % curl -X POST http://localhost:8080/query \
 %   -H "Content-Type: application/json" \
+%   -d '{"type":"select","table":"users","where":{"age":{"op":">=","value":30}}}'
+%
+% curl -X POST http://localhost:8080/query \
+%   -H "Content-Type: application/json" \
 %   -d '{"type":"update","table":"users","set":{"age":31},"where":{"name":"John Doe"}}'
 %
 % curl -X POST http://localhost:8080/query \
 %   -H "Content-Type: application/json" \
 %   -d '{"type":"delete","table":"users","where":{"name":"John Doe"}}'
+%
+% curl -X POST http://localhost:8080/query \
+%   -H "Content-Type: application/json" \
+%   -d '{"type":"truncate","table":"users"}'
+%
+% curl -X POST http://localhost:8080/query \
+%   -H "Content-Type: application/json" \
+%   -d '{"type":"delete","table":"users","ids":[3,7]}'
+%
+% curl -X POST http://localhost:8080/query \
+%   -H "Content-Type: application/json" \
+%   -d '{"type":"drop_table","table":"users","if_exists":true}'
+%
+% curl -X POST http://localhost:8080/query \
+%   -H "Content-Type: application/json" \
+%   -d '{"type":"list_tables"}'
+%
+% curl -X POST http://localhost:8080/query \
+%   -H "Content-Type: application/json" \
+%   -d '{"type":"describe_table","table":"users"}'
+%
+% curl -X POST http://localhost:8080/query \
+%   -H "Content-Type: application/json" \
+%   -d '{"type":"create_index","table":"users","index":{"name":"users_email","columns":["email"],"unique":true}}'
+%
+% curl -X POST http://localhost:8080/query \
+%   -H "Content-Type: application/json" \
+%   -d '{"type":"drop_index","name":"users_email","if_exists":true}'
+%
+% curl -X POST http://localhost:8080/query \
+%   -H "Content-Type: application/json" \
+%   -d '{"type":"begin"}'
+%
+% curl -X POST http://localhost:8080/query \
+%   -H "Content-Type: application/json" \
+%   -d '{"type":"insert","table":"users","values":{"name":"Ann"},"tx":"tx1"}'
+%
+% curl -X POST http://localhost:8080/query \
+%   -H "Content-Type: application/json" \
+%   -d '{"type":"commit","tx":"tx1"}'
@@ -0,0 +1,485 @@
+// Package dbfile reads and writes the table files of the Prolog server in
+// db-engine/engine.pl. Each table has a <name>_schema.pl file holding its
+// table_schema/2, table_types/2 and table_constraints/2 facts and a
+// <name>_data.pl file with one table_data/3 fact per row.
+package dbfile
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"weird/db/engine/client"
+)
+
+const (
+	SchemaSuffix = "_schema.pl"
+	DataSuffix   = "_data.pl"
+)
+
+// Table is everything the files of one table hold. Row values are typed
+// like those of a client.Response.
+type Table struct {
+	Name        string
+	Columns     []string
+	Types       []string
+	Constraints []client.Constraint
+	Indexes     []client.Index
+	Rows        []client.Row
+}
+
+// PendingWrites is the file in which the server plans a change spanning
+// several files before carrying it out, see save_table_files
+const PendingWrites = "pending_writes"
+
+// ReadDir loads every .pl file of a db_files directory and returns its
+// tables sorted by name. A change the server was interrupted in after
+// planning it is read as done, the way the server finishes it when it
+// next starts.
+func ReadDir(dir string) ([]*Table, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.pl"))
+	if err != nil {
+		return nil, err
+	}
+	sources := make(map[string]string, len(files))
+	for _, file := range files {
+		sources[filepath.Base(file)] = file
+	}
+	if err := applyPending(dir, sources); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var facts []Compound
+	for _, name := range names {
+		file := sources[name]
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		fileFacts, err := ReadFacts(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		facts = append(facts, fileFacts...)
+	}
+	return Tables(facts)
+}
+
+// applyPending updates sources, the file to read for each file name of
+// dir, with the steps of a pending change: rename(Tmp, File) reads File
+// from Tmp while Tmp is still there, delete(File) drops File. The server
+// writes paths relative to where it runs, so only their base names count.
+func applyPending(dir string, sources map[string]string) error {
+	f, err := os.Open(filepath.Join(dir, PendingWrites))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	steps, err := ReadFacts(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %w", PendingWrites, err)
+	}
+
+	for _, step := range steps {
+		switch {
+		case step.Functor == "rename" && len(step.Args) == 2:
+			tmp := filepath.Join(dir, filepath.Base(text(step.Args[0])))
+			if _, err := os.Stat(tmp); err == nil {
+				sources[filepath.Base(text(step.Args[1]))] = tmp
+			}
+		case step.Functor == "delete" && len(step.Args) == 1:
+			delete(sources, filepath.Base(text(step.Args[0])))
+		default:
+			return fmt.Errorf("%s: unknown step %s", PendingWrites, FormatTerm(step))
+		}
+	}
+	return nil
+}
+
+// Tables assembles tables from their facts. Tables saved before column
+// types existed get untyped columns; rows of a table without a schema are
+// ignored, as the server never reaches them.
+func Tables(facts []Compound) ([]*Table, error) {
+	tables := make(map[string]*Table)
+	types := make(map[string][]string)
+	constraints := make(map[string][]client.Constraint)
+	indexes := make(map[string][]client.Index)
+	rows := make(map[string][]client.Row)
+
+	for _, fact := range facts {
+		arity := len(fact.Args)
+		switch {
+		case fact.Functor == "table_schema" && arity == 2:
+			name := text(fact.Args[0])
+			columns, err := textList(fact.Args[1])
+			if err != nil {
+				return nil, fmt.Errorf("table_schema of %s: %w", name, err)
+			}
+			tables[name] = &Table{Name: name, Columns: columns}
+		case fact.Functor == "table_types" && arity == 2:
+			name := text(fact.Args[0])
+			list, err := textList(fact.Args[1])
+			if err != nil {
+				return nil, fmt.Errorf("table_types of %s: %w", name, err)
+			}
+			types[name] = list
+		case fact.Functor == "table_constraints" && arity == 2:
+			name := text(fact.Args[0])
+			list, err := constraintList(fact.Args[1])
+			if err != nil {
+				return nil, fmt.Errorf("table_constraints of %s: %w", name, err)
+			}
+			constraints[name] = list
+		case fact.Functor == "table_indexes" && arity == 2:
+			name := text(fact.Args[0])
+			list, err := indexList(fact.Args[1])
+			if err != nil {
+				return nil, fmt.Errorf("table_indexes of %s: %w", name, err)
+			}
+			indexes[name] = list
+		case fact.Functor == "table_data" && arity == 3:
+			name := text(fact.Args[0])
+			id, ok := fact.Args[1].(int64)
+			if !ok {
+				return nil, fmt.Errorf("table_data of %s: invalid id %s", name, FormatTerm(fact.Args[1]))
+			}
+			data, ok := fact.Args[2].([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("table_data of %s: row %d is not a list", name, id)
+			}
+			row := client.Row{ID: int(id), Data: make([]interface{}, len(data))}
+			for i, cell := range data {
+				row.Data[i] = value(cell)
+			}
+			rows[name] = append(rows[name], row)
+		}
+	}
+
+	result := make([]*Table, 0, len(tables))
+	for name, t := range tables {
+		t.Types = types[name]
+		if t.Types == nil {
+			t.Types = make([]string, len(t.Columns))
+		}
+		t.Constraints = constraints[name]
+		t.Indexes = indexes[name]
+		t.Rows = rows[name]
+		for _, row := range t.Rows {
+			for i := range row.Data {
+				if i < len(t.Types) {
+					row.Data[i] = client.NormalizeValue(row.Data[i], t.Types[i])
+				}
+			}
+		}
+		result = append(result, t)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+// WriteSchema writes a table's schema file the way save_schema does
+func WriteSchema(w io.Writer, t *Table) error {
+	types := t.Types
+	if types == nil {
+		types = make([]string, len(t.Columns))
+	}
+	constraints := make([]interface{}, len(t.Constraints))
+	for i, c := range t.Constraints {
+		constraints[i] = constraintTerm(c)
+	}
+	indexes := make([]interface{}, len(t.Indexes))
+	for i, index := range t.Indexes {
+		indexes[i] = indexTerm(index)
+	}
+
+	var b bytes.Buffer
+	b.WriteString(":- dynamic table_schema/2.\n")
+	b.WriteString(":- dynamic table_types/2.\n")
+	b.WriteString(":- dynamic table_constraints/2.\n")
+	b.WriteString(":- dynamic table_indexes/2.\n")
+	writeFact(&b, "table_schema", t.Name, t.Columns)
+	writeFact(&b, "table_types", t.Name, types)
+	writeFact(&b, "table_constraints", t.Name, constraints)
+	writeFact(&b, "table_indexes", t.Name, indexes)
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// WriteData writes a table's data file the way save_table_data does
+func WriteData(w io.Writer, t *Table) error {
+	var b bytes.Buffer
+	b.WriteString(":- dynamic table_data/3.\n")
+	for _, row := range t.Rows {
+		data := row.Data
+		if data == nil {
+			data = []interface{}{}
+		}
+		writeFact(&b, "table_data", t.Name, row.ID, data)
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// SaveTable writes both files of a table into dir. Each file is written to
+// a temporary file that is flushed to disk and then replaces the old one,
+// so a crash never leaves a half-written table behind.
+func SaveTable(dir string, t *Table) error {
+	if err := writeFile(filepath.Join(dir, t.Name+SchemaSuffix), func(w io.Writer) error {
+		return WriteSchema(w, t)
+	}); err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(dir, t.Name+DataSuffix), func(w io.Writer) error {
+		return WriteData(w, t)
+	})
+}
+
+// RemoveTable deletes the files of a table from dir; missing files are
+// not an error
+func RemoveTable(dir string, name string) error {
+	for _, suffix := range []string{SchemaSuffix, DataSuffix} {
+		err := os.Remove(filepath.Join(dir, name+suffix))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// TableNames returns the names of the tables that have a file in dir,
+// judging by file names alone
+func TableNames(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var names []string
+	for _, entry := range entries {
+		for _, suffix := range []string{SchemaSuffix, DataSuffix} {
+			name, ok := strings.CutSuffix(entry.Name(), suffix)
+			if ok && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func writeFile(path string, write func(io.Writer) error) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func writeFact(b *bytes.Buffer, functor string, args ...interface{}) {
+	b.WriteString(FormatTerm(Compound{Functor: functor, Args: args}))
+	b.WriteString(".\n")
+}
+
+// constraintTerm is the dict the server stores for a constraint: the JSON
+// object the client sent it
+func constraintTerm(c client.Constraint) map[string]interface{} {
+	dict := map[string]interface{}{
+		"name": c.Name,
+		"type": c.Type,
+	}
+	if c.Table != "" {
+		dict["table"] = c.Table
+	}
+	if len(c.Columns) > 0 {
+		dict["columns"] = c.Columns
+	}
+	if c.Check != "" {
+		dict["check"] = c.Check
+	}
+	if c.Default != nil {
+		dict["default"] = c.Default
+	}
+	if c.References != nil {
+		columns := c.References.Columns
+		if columns == nil {
+			columns = []string{}
+		}
+		dict["references"] = map[string]interface{}{
+			"table":     c.References.Table,
+			"columns":   columns,
+			"on_delete": c.References.OnDelete,
+		}
+	}
+	return dict
+}
+
+func constraintList(term interface{}) ([]client.Constraint, error) {
+	list, ok := term.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("not a list: %s", FormatTerm(term))
+	}
+	constraints := make([]client.Constraint, 0, len(list))
+	for _, item := range list {
+		dict, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("constraint is not a dict: %s", FormatTerm(item))
+		}
+		c := client.Constraint{
+			Name:    text(dict["name"]),
+			Type:    text(dict["type"]),
+			Table:   text(dict["table"]),
+			Check:   text(dict["check"]),
+			Default: value(dict["default"]),
+		}
+		if columns, ok := dict["columns"]; ok {
+			list, err := textList(columns)
+			if err != nil {
+				return nil, err
+			}
+			c.Columns = list
+		}
+		if ref, ok := dict["references"].(map[string]interface{}); ok {
+			columns, err := textList(ref["columns"])
+			if err != nil {
+				return nil, err
+			}
+			c.References = &client.Reference{
+				Table:    text(ref["table"]),
+				Columns:  columns,
+				OnDelete: text(ref["on_delete"]),
+			}
+		}
+		constraints = append(constraints, c)
+	}
+	return constraints, nil
+}
+
+// indexTerm is the dict the server stores for an index
+func indexTerm(index client.Index) map[string]interface{} {
+	columns := index.Columns
+	if columns == nil {
+		columns = []string{}
+	}
+	return map[string]interface{}{
+		"name":    index.Name,
+		"columns": columns,
+		"unique":  index.Unique,
+	}
+}
+
+func indexList(term interface{}) ([]client.Index, error) {
+	list, ok := term.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("not a list: %s", FormatTerm(term))
+	}
+	indexes := make([]client.Index, 0, len(list))
+	for _, item := range list {
+		dict, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("index is not a dict: %s", FormatTerm(item))
+		}
+		columns, err := textList(dict["columns"])
+		if err != nil {
+			return nil, err
+		}
+		unique, _ := value(dict["unique"]).(bool)
+		indexes = append(indexes, client.Index{
+			Name:    text(dict["name"]),
+			Columns: columns,
+			Unique:  unique,
+		})
+	}
+	return indexes, nil
+}
+
+// value converts a term to the Go value the server would send for it in
+// JSON: null, true and false become nil and bools, other atoms strings
+func value(term interface{}) interface{} {
+	switch t := term.(type) {
+	case Atom:
+		switch t {
+		case "null":
+			return nil
+		case "true":
+			return true
+		case "false":
+			return false
+		}
+		return string(t)
+	case []interface{}:
+		list := make([]interface{}, len(t))
+		for i, item := range t {
+			list[i] = value(item)
+		}
+		return list
+	case map[string]interface{}:
+		dict := make(map[string]interface{}, len(t))
+		for key, item := range t {
+			dict[key] = value(item)
+		}
+		return dict
+	case Var, Compound:
+		return FormatTerm(t)
+	default:
+		return t
+	}
+}
+
+// text returns a string or atom as a Go string; a missing value is ""
+func text(term interface{}) string {
+	switch t := term.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case Atom:
+		return string(t)
+	default:
+		return FormatTerm(t)
+	}
+}
+
+func textList(term interface{}) ([]string, error) {
+	if term == nil {
+		return nil, nil
+	}
+	list, ok := term.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("not a list: %s", FormatTerm(term))
+	}
+	out := make([]string, len(list))
+	for i, item := range list {
+		out[i] = text(item)
+	}
+	return out, nil
+}
@@ -0,0 +1,195 @@
+package dbfile
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"weird/db/engine/client"
+)
+
+// posts is the table held by testdata/posts_*.pl, files laid out the way
+// engine.pl's save_schema and save_table_data write them
+var posts = &Table{
+	Name:    "posts",
+	Columns: []string{"id", "author", "body", "score", "draft"},
+	Types:   []string{"INT", "TEXT", "TEXT", "FLOAT", "BOOL"},
+	Constraints: []client.Constraint{
+		{Name: "posts_pkey", Type: client.PrimaryKeyConstraint, Columns: []string{"id"}},
+		{
+			Name:       "posts_author_fkey",
+			Type:       client.ForeignKeyConstraint,
+			Columns:    []string{"author"},
+			References: &client.Reference{Table: "users", Columns: []string{"name"}, OnDelete: client.SetNullAction},
+		},
+		{Name: "posts_score_default", Type: client.DefaultConstraint, Columns: []string{"score"}, Default: -1.5},
+		{Name: "posts_body_check", Type: client.CheckConstraint, Check: `body != 'it''s "empty"'`},
+	},
+	Indexes: []client.Index{{Name: "posts_author_idx", Columns: []string{"author"}, Unique: true}},
+	Rows: []client.Row{
+		{ID: 1, Data: []interface{}{int64(1), "ann", "tab\there\nnewline", 0.1, false}},
+		{ID: 2, Data: []interface{}{int64(2), "O'Neil", `say "hi" \ bye`, 1e20, true}},
+		{ID: 3, Data: []interface{}{int64(3), nil, "null", -2.5e-7, nil}},
+		{ID: 4, Data: []interface{}{int64(4), "zoë", "bell\a\b\f\v\x01", math.Inf(-1), true}},
+		{ID: 7, Data: []interface{}{int64(7), "", "", 3.0, false}},
+	},
+}
+
+// describe prints tables for a failure message
+func describe(tables []*Table) string {
+	var b strings.Builder
+	for _, t := range tables {
+		fmt.Fprintf(&b, "%+v\n", *t)
+	}
+	return b.String()
+}
+
+func TestReadServerFiles(t *testing.T) {
+	// A table saved before column types, constraints and indexes existed
+	tables, err := ReadDir("../db-engine/db_files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*Table{{
+		Name:    "Users",
+		Columns: []string{"name", "email", "password"},
+		Types:   []string{"", "", ""},
+	}}
+	if !reflect.DeepEqual(tables, want) {
+		t.Errorf("got %s", describe(tables))
+	}
+}
+
+func TestReadGolden(t *testing.T) {
+	tables, err := ReadDir("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 1 {
+		t.Fatalf("got %d tables", len(tables))
+	}
+	got := tables[0]
+	if !reflect.DeepEqual(got.Columns, posts.Columns) || !reflect.DeepEqual(got.Types, posts.Types) {
+		t.Errorf("got columns %v of types %v", got.Columns, got.Types)
+	}
+	if !reflect.DeepEqual(got.Constraints, posts.Constraints) {
+		t.Errorf("got constraints %+v", got.Constraints)
+	}
+	if !reflect.DeepEqual(got.Indexes, posts.Indexes) {
+		t.Errorf("got indexes %+v", got.Indexes)
+	}
+	for i, row := range got.Rows {
+		if i >= len(posts.Rows) || !reflect.DeepEqual(row, posts.Rows[i]) {
+			t.Errorf("row %d: got %#v", i, row)
+		}
+	}
+	if len(got.Rows) != len(posts.Rows) {
+		t.Errorf("got %d rows, want %d", len(got.Rows), len(posts.Rows))
+	}
+}
+
+func TestWriteDataGolden(t *testing.T) {
+	want, err := os.ReadFile(filepath.Join("testdata", "posts"+DataSuffix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := WriteData(&b, posts); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != string(want) {
+		t.Errorf("got\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestSaveTableRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	if err := SaveTable(dir, posts); err != nil {
+		t.Fatal(err)
+	}
+	tables, err := ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 1 || !reflect.DeepEqual(tables[0], posts) {
+		t.Errorf("got %s", describe(tables))
+	}
+
+	names, err := TableNames(dir)
+	if err != nil || !reflect.DeepEqual(names, []string{"posts"}) {
+		t.Errorf("got names %v (%v)", names, err)
+	}
+	if err := RemoveTable(dir, "posts"); err != nil {
+		t.Fatal(err)
+	}
+	if tables, err := ReadDir(dir); err != nil || len(tables) != 0 {
+		t.Errorf("after remove: got %s (%v)", describe(tables), err)
+	}
+}
+
+func TestReadDirPendingWrites(t *testing.T) {
+	dir := t.TempDir()
+	old := &Table{Name: "a", Columns: []string{"x"}, Types: []string{"INT"}}
+	for _, table := range []*Table{old, {Name: "b", Columns: []string{"y"}, Types: []string{""}}} {
+		if err := SaveTable(dir, table); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The server stopped after writing the new files of a and the plan,
+	// before renaming them into place and deleting b
+	renamed := &Table{Name: "a", Columns: []string{"z"}, Types: []string{"INT"}, Rows: []client.Row{{ID: 1, Data: []interface{}{int64(5)}}}}
+	renamed.Constraints, renamed.Indexes = []client.Constraint{}, []client.Index{}
+	var schema, data bytes.Buffer
+	if err := WriteSchema(&schema, renamed); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteData(&data, renamed); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"a_schema.pl.tmp": schema.String(),
+		"a_data.pl.tmp":   data.String(),
+		PendingWrites: `rename('db_files/a_schema.pl.tmp','db_files/a_schema.pl').
+rename('db_files/a_data.pl.tmp','db_files/a_data.pl').
+delete('db_files/b_schema.pl').
+delete('db_files/b_data.pl').
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tables, err := ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 1 || !reflect.DeepEqual(tables[0], renamed) {
+		t.Errorf("got %s", describe(tables))
+	}
+
+	// Once a file is renamed its step reads the file in place
+	if err := os.Rename(filepath.Join(dir, "a_schema.pl.tmp"), filepath.Join(dir, "a_schema.pl")); err != nil {
+		t.Fatal(err)
+	}
+	tables, err = ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 1 || !reflect.DeepEqual(tables[0], renamed) {
+		t.Errorf("after a rename: got %s", describe(tables))
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, PendingWrites), []byte("move(a, b).\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadDir(dir); err == nil {
+		t.Error("an unknown step was accepted")
+	}
+}
@@ -0,0 +1,192 @@
+package dbfile
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"weird/db/engine/client"
+)
+
+// FormatTerm writes a term the way SWI-Prolog's writeq does, so that it
+// reads back as the same term. Besides the types a Reader produces it
+// accepts nil (the atom null), bools (true and false), []string and the
+// other Go integer types.
+func FormatTerm(term interface{}) string {
+	var b strings.Builder
+	writeTerm(&b, term)
+	return b.String()
+}
+
+func writeTerm(b *strings.Builder, term interface{}) {
+	switch t := term.(type) {
+	case nil:
+		b.WriteString("null")
+	case bool:
+		b.WriteString(strconv.FormatBool(t))
+	case string:
+		b.WriteString(quote(t, '"'))
+	case Atom:
+		b.WriteString(formatAtom(string(t)))
+	case Var:
+		b.WriteString(string(t))
+	case int:
+		b.WriteString(strconv.Itoa(t))
+	case int64:
+		b.WriteString(strconv.FormatInt(t, 10))
+	case float64:
+		b.WriteString(formatFloat(t))
+	case []string:
+		b.WriteByte('[')
+		for i, s := range t {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(quote(s, '"'))
+		}
+		b.WriteByte(']')
+	case []interface{}:
+		b.WriteByte('[')
+		for i, item := range t {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeTerm(b, item)
+		}
+		b.WriteByte(']')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for key := range t {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		b.WriteString("_{")
+		for i, key := range keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(formatAtom(key))
+			b.WriteByte(':')
+			value := FormatTerm(t[key])
+			if strings.HasPrefix(value, "-") {
+				// Keep a negative number from fusing with the ':'
+				b.WriteByte(' ')
+			}
+			b.WriteString(value)
+		}
+		b.WriteByte('}')
+	case Compound:
+		b.WriteString(formatAtom(t.Functor))
+		if len(t.Args) == 0 {
+			return
+		}
+		b.WriteByte('(')
+		for i, arg := range t.Args {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			writeTerm(b, arg)
+		}
+		b.WriteByte(')')
+	default:
+		// Any other number or value is written through its normalized form
+		switch v := client.NormalizeValue(term, "").(type) {
+		case int64, float64:
+			writeTerm(b, v)
+		default:
+			b.WriteString(quote(fmt.Sprint(term), '"'))
+		}
+	}
+}
+
+// formatFloat writes a float so Prolog reads it back as a float: there is
+// always a fraction, and infinities and NaN use SWI-Prolog's syntax
+func formatFloat(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "1.5NaN"
+	case math.IsInf(f, 1):
+		return "1.0Inf"
+	case math.IsInf(f, -1):
+		return "-1.0Inf"
+	}
+	abs := math.Abs(f)
+	if abs != 0 && (abs < 1e-4 || abs >= 1e15) {
+		s := strconv.FormatFloat(f, 'e', -1, 64)
+		mantissa, exp, _ := strings.Cut(s, "e")
+		if !strings.Contains(mantissa, ".") {
+			mantissa += ".0"
+		}
+		// Go pads the exponent to two digits and signs it, SWI-Prolog
+		// writes 1.0e20 and 1.0e-5
+		sign := ""
+		if strings.HasPrefix(exp, "-") {
+			sign = "-"
+		}
+		return mantissa + "e" + sign + strings.TrimLeft(exp, "+-0")
+	}
+	s := strconv.FormatFloat(f, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
+
+// formatAtom quotes an atom unless it reads back unquoted
+func formatAtom(name string) string {
+	if name == "[]" || name == "{}" {
+		return name
+	}
+	if name != "" && unicode.IsLower([]rune(name)[0]) {
+		plain := true
+		for _, c := range name {
+			if !isAlnum(c) {
+				plain = false
+				break
+			}
+		}
+		if plain {
+			return name
+		}
+	}
+	if name != "" && strings.Trim(name, symbolChars) == "" && name != "." {
+		return name
+	}
+	return quote(name, '\'')
+}
+
+func quote(s string, q rune) string {
+	var b strings.Builder
+	b.WriteRune(q)
+	for _, c := range s {
+		switch c {
+		case q, '\\':
+			b.WriteRune('\\')
+			b.WriteRune(c)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\a':
+			b.WriteString(`\a`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\v':
+			b.WriteString(`\v`)
+		default:
+			if unicode.IsControl(c) {
+				fmt.Fprintf(&b, `\%03o\`, c)
+			} else {
+				b.WriteRune(c)
+			}
+		}
+	}
+	b.WriteRune(q)
+	return b.String()
+}
@@ -0,0 +1,590 @@
+package dbfile
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Terms read from a file are represented with plain Go values: double
+// quoted strings are Go strings, integers int64, floats float64, lists
+// []interface{} and dicts map[string]interface{} (their tag is dropped).
+// Atoms, variables and compound terms have their own types.
+
+// Atom is a Prolog atom, quoted or not
+type Atom string
+
+// Var is a Prolog variable, such as the anonymous tag of a dict
+type Var string
+
+// Compound is a term f(Args...). Every fact of a file is one.
+type Compound struct {
+	Functor string
+	Args    []interface{}
+}
+
+type tokenKind int
+
+const (
+	endToken tokenKind = iota // the '.' that ends a clause
+	atomToken
+	varToken
+	stringToken
+	intToken
+	floatToken
+	punctToken // ( ) [ ] { } , | :
+	eofToken
+)
+
+type termToken struct {
+	kind tokenKind
+	text string
+	// value holds the parsed number of int and float tokens
+	value interface{}
+	// open is set when the token is directly followed by '(' or '{' with no
+	// layout in between, making it a functor or a dict tag
+	open rune
+}
+
+// Reader reads the clauses of a Prolog file one fact at a time
+type Reader struct {
+	src  []rune
+	pos  int
+	line int
+}
+
+func NewReader(r io.Reader) (*Reader, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{src: []rune(string(data)), line: 1}, nil
+}
+
+// ReadFacts returns every fact of a file in order; directives such as
+// ":- dynamic table_data/3." are skipped
+func ReadFacts(r io.Reader) ([]Compound, error) {
+	reader, err := NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	var facts []Compound
+	for {
+		fact, err := reader.Next()
+		if err == io.EOF {
+			return facts, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		facts = append(facts, fact)
+	}
+}
+
+// Next returns the next fact, or io.EOF after the last one
+func (r *Reader) Next() (Compound, error) {
+	for {
+		tok, err := r.token()
+		if err != nil {
+			return Compound{}, err
+		}
+		switch {
+		case tok.kind == eofToken:
+			return Compound{}, io.EOF
+		case tok.kind == atomToken && tok.text == ":-" && tok.open == 0:
+			if err := r.skipClause(); err != nil {
+				return Compound{}, err
+			}
+			continue
+		}
+
+		term, err := r.termFrom(tok)
+		if err != nil {
+			return Compound{}, err
+		}
+		end, err := r.token()
+		if err != nil {
+			return Compound{}, err
+		}
+		if end.kind != endToken {
+			return Compound{}, r.errorf("expected '.' after clause, found %q", end.text)
+		}
+		switch t := term.(type) {
+		case Compound:
+			return t, nil
+		case Atom:
+			return Compound{Functor: string(t)}, nil
+		default:
+			return Compound{}, r.errorf("clause is not a fact: %s", FormatTerm(term))
+		}
+	}
+}
+
+func (r *Reader) skipClause() error {
+	for {
+		tok, err := r.token()
+		if err != nil {
+			return err
+		}
+		switch tok.kind {
+		case endToken:
+			return nil
+		case eofToken:
+			return r.errorf("unexpected end of file in directive")
+		}
+	}
+}
+
+func (r *Reader) term() (interface{}, error) {
+	tok, err := r.token()
+	if err != nil {
+		return nil, err
+	}
+	return r.termFrom(tok)
+}
+
+func (r *Reader) termFrom(tok termToken) (interface{}, error) {
+	switch tok.kind {
+	case intToken, floatToken:
+		return tok.value, nil
+	case stringToken:
+		return tok.text, nil
+	case varToken:
+		if tok.open == '{' {
+			return r.dict()
+		}
+		return Var(tok.text), nil
+	case atomToken:
+		switch tok.open {
+		case '(':
+			r.next()
+			args, err := r.sequence(")")
+			if err != nil {
+				return nil, err
+			}
+			return Compound{Functor: tok.text, Args: args}, nil
+		case '{':
+			return r.dict()
+		}
+		if tok.text == "-" {
+			// A minus sign written apart from its number
+			next, err := r.token()
+			if err != nil {
+				return nil, err
+			}
+			switch v := next.value.(type) {
+			case int64:
+				return -v, nil
+			case float64:
+				return -v, nil
+			}
+			return nil, r.errorf("unexpected %q after '-'", next.text)
+		}
+		if tok.text == "[]" {
+			return []interface{}{}, nil
+		}
+		return Atom(tok.text), nil
+	case punctToken:
+		switch tok.text {
+		case "[":
+			return r.list()
+		case "(":
+			t, err := r.term()
+			if err != nil {
+				return nil, err
+			}
+			return t, r.expect(")")
+		}
+	case endToken:
+		return nil, r.errorf("unexpected end of clause")
+	case eofToken:
+		return nil, r.errorf("unexpected end of file")
+	}
+	return nil, r.errorf("unexpected %q", tok.text)
+}
+
+func (r *Reader) list() ([]interface{}, error) {
+	items, err := r.sequence("]")
+	if err != nil {
+		return nil, err
+	}
+	if items == nil {
+		items = []interface{}{}
+	}
+	return items, nil
+}
+
+// sequence reads comma separated terms up to the closing punctuation
+func (r *Reader) sequence(closing string) ([]interface{}, error) {
+	var items []interface{}
+	tok, err := r.token()
+	if err != nil {
+		return nil, err
+	}
+	if tok.kind == punctToken && tok.text == closing {
+		return items, nil
+	}
+	for {
+		item, err := r.termFrom(tok)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+
+		sep, err := r.token()
+		if err != nil {
+			return nil, err
+		}
+		if sep.kind != punctToken {
+			return nil, r.errorf("expected ',' or '%s', found %q", closing, sep.text)
+		}
+		switch sep.text {
+		case closing:
+			return items, nil
+		case ",":
+		default:
+			return nil, r.errorf("expected ',' or '%s', found %q", closing, sep.text)
+		}
+		if tok, err = r.token(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// dict reads the {Key:Value, ...} part of a dict whose tag was just read
+func (r *Reader) dict() (map[string]interface{}, error) {
+	if err := r.expect("{"); err != nil {
+		return nil, err
+	}
+	dict := make(map[string]interface{})
+	tok, err := r.token()
+	if err != nil {
+		return nil, err
+	}
+	if tok.kind == punctToken && tok.text == "}" {
+		return dict, nil
+	}
+	for {
+		var key string
+		switch tok.kind {
+		case atomToken, intToken:
+			key = tok.text
+		default:
+			return nil, r.errorf("invalid dict key %q", tok.text)
+		}
+		if err := r.expect(":"); err != nil {
+			return nil, err
+		}
+		value, err := r.term()
+		if err != nil {
+			return nil, err
+		}
+		dict[key] = value
+
+		sep, err := r.token()
+		if err != nil {
+			return nil, err
+		}
+		if sep.kind == punctToken && sep.text == "}" {
+			return dict, nil
+		}
+		if sep.kind != punctToken || sep.text != "," {
+			return nil, r.errorf("expected ',' or '}', found %q", sep.text)
+		}
+		if tok, err = r.token(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (r *Reader) expect(punct string) error {
+	tok, err := r.token()
+	if err != nil {
+		return err
+	}
+	if tok.kind != punctToken || tok.text != punct {
+		return r.errorf("expected '%s', found %q", punct, tok.text)
+	}
+	return nil
+}
+
+func (r *Reader) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", r.line, fmt.Sprintf(format, args...))
+}
+
+const symbolChars = "+-*/\\^<>=~:.?@#&$"
+
+func (r *Reader) next() rune {
+	if r.pos >= len(r.src) {
+		return 0
+	}
+	c := r.src[r.pos]
+	r.pos++
+	if c == '\n' {
+		r.line++
+	}
+	return c
+}
+
+func (r *Reader) at(offset int) rune {
+	if r.pos+offset < len(r.src) {
+		return r.src[r.pos+offset]
+	}
+	return 0
+}
+
+func (r *Reader) skipLayout() error {
+	for r.pos < len(r.src) {
+		c := r.at(0)
+		switch {
+		case unicode.IsSpace(c):
+			r.next()
+		case c == '%':
+			for r.pos < len(r.src) && r.at(0) != '\n' {
+				r.next()
+			}
+		case c == '/' && r.at(1) == '*':
+			r.next()
+			r.next()
+			for !(r.at(0) == '*' && r.at(1) == '/') {
+				if r.pos >= len(r.src) {
+					return r.errorf("unterminated comment")
+				}
+				r.next()
+			}
+			r.next()
+			r.next()
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (r *Reader) token() (termToken, error) {
+	if err := r.skipLayout(); err != nil {
+		return termToken{}, err
+	}
+	if r.pos >= len(r.src) {
+		return termToken{kind: eofToken, text: "end of file"}, nil
+	}
+
+	start := r.pos
+	c := r.at(0)
+	var tok termToken
+	switch {
+	case c == '.' && (r.pos+1 >= len(r.src) || unicode.IsSpace(r.at(1)) || r.at(1) == '%'):
+		r.next()
+		return termToken{kind: endToken, text: "."}, nil
+	case unicode.IsDigit(c) || (c == '-' && unicode.IsDigit(r.at(1))):
+		return r.number()
+	case c == '_' || unicode.IsUpper(c):
+		for r.pos < len(r.src) && isAlnum(r.at(0)) {
+			r.next()
+		}
+		tok = termToken{kind: varToken, text: string(r.src[start:r.pos])}
+	case unicode.IsLower(c):
+		for r.pos < len(r.src) && isAlnum(r.at(0)) {
+			r.next()
+		}
+		tok = termToken{kind: atomToken, text: string(r.src[start:r.pos])}
+	case c == '\'':
+		text, err := r.quoted('\'')
+		if err != nil {
+			return termToken{}, err
+		}
+		tok = termToken{kind: atomToken, text: text}
+	case c == '"':
+		text, err := r.quoted('"')
+		if err != nil {
+			return termToken{}, err
+		}
+		return termToken{kind: stringToken, text: text}, nil
+	case strings.ContainsRune("()[]{},|", c):
+		r.next()
+		if c == '[' && r.at(0) == ']' {
+			r.next()
+			tok = termToken{kind: atomToken, text: "[]"}
+			break
+		}
+		return termToken{kind: punctToken, text: string(c)}, nil
+	case c == ':' && (!strings.ContainsRune(symbolChars, r.at(1)) || r.at(1) == '-' && unicode.IsDigit(r.at(2))):
+		r.next()
+		return termToken{kind: punctToken, text: ":"}, nil
+	case strings.ContainsRune(symbolChars, c):
+		for r.pos < len(r.src) && strings.ContainsRune(symbolChars, r.at(0)) {
+			r.next()
+		}
+		tok = termToken{kind: atomToken, text: string(r.src[start:r.pos])}
+	default:
+		return termToken{}, r.errorf("unexpected character %q", c)
+	}
+
+	if next := r.at(0); next == '(' || next == '{' {
+		tok.open = next
+	}
+	return tok, nil
+}
+
+// number reads an integer or float, including SWI-Prolog's 1.0Inf and
+// 1.5NaN
+func (r *Reader) number() (termToken, error) {
+	start := r.pos
+	if r.at(0) == '-' {
+		r.next()
+	}
+	digits := func() {
+		for unicode.IsDigit(r.at(0)) || (r.at(0) == '_' && unicode.IsDigit(r.at(1))) {
+			r.next()
+		}
+	}
+	digits()
+	isFloat := false
+	if r.at(0) == '.' && unicode.IsDigit(r.at(1)) {
+		isFloat = true
+		r.next()
+		digits()
+	}
+	if e := r.at(0); e == 'e' || e == 'E' {
+		sign := 0
+		if r.at(1) == '+' || r.at(1) == '-' {
+			sign = 1
+		}
+		if unicode.IsDigit(r.at(1 + sign)) {
+			isFloat = true
+			r.next()
+			if sign == 1 {
+				r.next()
+			}
+			digits()
+		}
+	}
+	text := strings.ReplaceAll(string(r.src[start:r.pos]), "_", "")
+
+	if isFloat && (r.matchWord("Inf") || r.matchWord("NaN")) {
+		special := string(r.src[r.pos-3 : r.pos])
+		text += special
+		if special == "NaN" {
+			return termToken{kind: floatToken, text: text, value: math.NaN()}, nil
+		}
+		return termToken{kind: floatToken, text: text, value: math.Inf(signOf(text))}, nil
+	}
+
+	if isFloat {
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return termToken{}, r.errorf("invalid number %s", text)
+		}
+		return termToken{kind: floatToken, text: text, value: f}, nil
+	}
+	n, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		// Integers beyond 64 bits are kept as close as a float can be
+		f, ferr := strconv.ParseFloat(text, 64)
+		if ferr != nil {
+			return termToken{}, r.errorf("invalid number %s", text)
+		}
+		return termToken{kind: floatToken, text: text, value: f}, nil
+	}
+	return termToken{kind: intToken, text: text, value: n}, nil
+}
+
+func (r *Reader) matchWord(word string) bool {
+	for i, c := range word {
+		if r.at(i) != c {
+			return false
+		}
+	}
+	if isAlnum(r.at(len(word))) {
+		return false
+	}
+	for range word {
+		r.next()
+	}
+	return true
+}
+
+func signOf(text string) int {
+	if strings.HasPrefix(text, "-") {
+		return -1
+	}
+	return 1
+}
+
+// quoted reads a quoted atom or string, resolving escape sequences and
+// doubled quotes
+func (r *Reader) quoted(quote rune) (string, error) {
+	r.next()
+	var b strings.Builder
+	for {
+		if r.pos >= len(r.src) {
+			return "", r.errorf("unterminated quoted text")
+		}
+		c := r.next()
+		switch {
+		case c == quote && r.at(0) == quote:
+			r.next()
+			b.WriteRune(quote)
+		case c == quote:
+			return b.String(), nil
+		case c == '\\':
+			if err := r.escape(&b); err != nil {
+				return "", err
+			}
+		default:
+			b.WriteRune(c)
+		}
+	}
+}
+
+func (r *Reader) escape(b *strings.Builder) error {
+	c := r.next()
+	switch c {
+	case 'n':
+		b.WriteRune('\n')
+	case 't':
+		b.WriteRune('\t')
+	case 'r':
+		b.WriteRune('\r')
+	case 'a':
+		b.WriteRune('\a')
+	case 'b':
+		b.WriteRune('\b')
+	case 'f':
+		b.WriteRune('\f')
+	case 'v':
+		b.WriteRune('\v')
+	case 'e':
+		b.WriteRune(0x1b)
+	case 's':
+		b.WriteRune(' ')
+	case '0', '1', '2', '3', '4', '5', '6', '7', 'x':
+		base, start := 8, r.pos-1
+		if c == 'x' {
+			base, start = 16, r.pos
+		}
+		for r.pos < len(r.src) && r.at(0) != '\\' {
+			r.next()
+		}
+		code, err := strconv.ParseInt(string(r.src[start:r.pos]), base, 32)
+		if err != nil || r.next() != '\\' {
+			return r.errorf("invalid character escape")
+		}
+		b.WriteRune(rune(code))
+	case '\n':
+		// An escaped newline continues the text on the next line
+	case 0:
+		return r.errorf("unterminated quoted text")
+	default:
+		// \\, \', \" and \` stand for the character itself
+		b.WriteRune(c)
+	}
+	return nil
+}
+
+func isAlnum(c rune) bool {
+	return c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
@@ -0,0 +1,144 @@
+package dbfile
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFormatTerm(t *testing.T) {
+	tests := []struct {
+		term interface{}
+		want string
+	}{
+		{nil, "null"},
+		{true, "true"},
+		{int64(-42), "-42"},
+		{7, "7"},
+		{0.1, "0.1"},
+		{3.0, "3.0"},
+		{-0.0001, "-0.0001"},
+		{1e20, "1.0e20"},
+		{-2.5e-7, "-2.5e-7"},
+		{1.5e300, "1.5e300"},
+		{math.Inf(1), "1.0Inf"},
+		{math.Inf(-1), "-1.0Inf"},
+		{math.NaN(), "1.5NaN"},
+		{"say \"hi\"\n", `"say \"hi\"\n"`},
+		{"back\\slash\tO'Neil", `"back\\slash\tO'Neil"`},
+		{"bell\a\b\f\v\x01", `"bell\a\b\f\v\001\"`},
+		{"zoë", `"zoë"`},
+		{Atom("null"), "null"},
+		{Atom("O'Neil"), `'O\'Neil'`},
+		{Atom("Users"), "'Users'"},
+		{Atom(":-"), ":-"},
+		{Atom("[]"), "[]"},
+		{[]string{"a", "b"}, `["a","b"]`},
+		{[]interface{}{}, "[]"},
+		{[]interface{}{int64(1), nil, "x"}, `[1,null,"x"]`},
+		{map[string]interface{}{"name": "pk", "columns": []string{"id"}}, `_{columns:["id"],name:"pk"}`},
+		{map[string]interface{}{"default": int64(-1)}, `_{default: -1}`},
+		{Compound{Functor: "table_data", Args: []interface{}{"t", 1, []interface{}{}}}, `table_data("t", 1, [])`},
+	}
+	for _, tt := range tests {
+		if got := FormatTerm(tt.term); got != tt.want {
+			t.Errorf("FormatTerm(%#v) = %s, want %s", tt.term, got, tt.want)
+		}
+	}
+}
+
+func TestTermRoundTrip(t *testing.T) {
+	terms := []interface{}{
+		"",
+		"say \"hi\"\n\tto O'Neil \\ \x01\x7f zoë",
+		Atom("O'Neil"),
+		Atom("null"),
+		Atom("a b"),
+		Var("_123"),
+		int64(math.MinInt64),
+		int64(math.MaxInt64),
+		0.1,
+		-0.0001,
+		1e15,
+		-2.5e-7,
+		math.MaxFloat64,
+		math.SmallestNonzeroFloat64,
+		math.Inf(-1),
+		[]interface{}{},
+		[]interface{}{int64(-1), -1.5, []interface{}{"nested"}},
+		map[string]interface{}{},
+		map[string]interface{}{
+			"default":    int64(-3),
+			"references": map[string]interface{}{"table": "users", "columns": []interface{}{"name"}},
+		},
+		Compound{Functor: "rename", Args: []interface{}{"a.tmp", "a.pl"}},
+	}
+	for _, term := range terms {
+		text := "fact(" + FormatTerm(term) + ").\n"
+		facts, err := ReadFacts(strings.NewReader(text))
+		if err != nil {
+			t.Errorf("reading %s: %v", text, err)
+			continue
+		}
+		if len(facts) != 1 || len(facts[0].Args) != 1 {
+			t.Errorf("reading %s: got %#v", text, facts)
+			continue
+		}
+		if got := facts[0].Args[0]; !reflect.DeepEqual(got, term) {
+			t.Errorf("reading %s: got %#v, want %#v", text, got, term)
+		}
+	}
+
+	facts, err := ReadFacts(strings.NewReader("fact(" + FormatTerm(math.NaN()) + ")."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f, ok := facts[0].Args[0].(float64); !ok || !math.IsNaN(f) {
+		t.Errorf("NaN: got %#v", facts[0].Args[0])
+	}
+}
+
+func TestReadFacts(t *testing.T) {
+	src := `
+% written by hand
+:- dynamic table_data/3.
+table_data('t', 1, [1_000, 'it''s', "a\
+b", "\x41\\x42\", - 2]). /* trailing */
+empty.
+`
+	facts, err := ReadFacts(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Compound{
+		{Functor: "table_data", Args: []interface{}{
+			Atom("t"),
+			int64(1),
+			[]interface{}{int64(1000), Atom("it's"), "ab", "AB", int64(-2)},
+		}},
+		{Functor: "empty"},
+	}
+	if !reflect.DeepEqual(facts, want) {
+		t.Errorf("got %#v", facts)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`fact("open).`, "line 1: unterminated quoted text"},
+		{"fact(1)", "line 1: expected '.' after clause, found \"end of file\""},
+		{"\n\nfact([1 2]).", "line 3: expected ',' or ']', found \"2\""},
+		{"42.", "line 1: clause is not a fact: 42"},
+		{"fact(_{1.5:x}).", "line 1: invalid dict key \"1.5\""},
+	}
+	for _, tt := range tests {
+		_, err := ReadFacts(strings.NewReader(tt.src))
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("reading %q: got error %v, want %s", tt.src, err, tt.want)
+		}
+	}
+}
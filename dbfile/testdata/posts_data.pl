@@ -0,0 +1,6 @@
+:- dynamic table_data/3.
+table_data("posts", 1, [1,"ann","tab\there\nnewline",0.1,false]).
+table_data("posts", 2, [2,"O'Neil","say \"hi\" \\ bye",1.0e20,true]).
+table_data("posts", 3, [3,null,"null",-2.5e-7,null]).
+table_data("posts", 4, [4,"zoë","bell\a\b\f\v\001\",-1.0Inf,true]).
+table_data("posts", 7, [7,"","",3.0,false]).
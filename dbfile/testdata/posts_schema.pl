@@ -0,0 +1,8 @@
+:- dynamic table_schema/2.
+:- dynamic table_types/2.
+:- dynamic table_constraints/2.
+:- dynamic table_indexes/2.
+table_schema("posts", ["id","author","body","score","draft"]).
+table_types("posts", ["INT","TEXT","TEXT","FLOAT","BOOL"]).
+table_constraints("posts", [_14810{columns:["id"],name:"posts_pkey",type:"primary_key"},_14906{type:"foreign_key",name:"posts_author_fkey",columns:["author"],references:_14870{table:"users",on_delete:"SET NULL",columns:["name"]}},_14990{name:"posts_score_default",type:"default",columns:["score"],default: -1.5},_{type:"check",name:"posts_body_check",check:"body != 'it''s \"empty\"'"}]).
+table_indexes("posts", [_15102{unique:true,name:"posts_author_idx",columns:["author"]}]).
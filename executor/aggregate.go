@@ -0,0 +1,248 @@
+package executor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"weird/db/engine/ast"
+	"weird/db/engine/client"
+)
+
+// aggregate groups the rows of a response by the GROUP BY columns and
+// computes every aggregate used by the query. The result is a synthetic
+// response with one row per group whose columns are the GROUP BY columns
+// followed by one column per aggregate, named after the call (e.g.
+// "COUNT(*)"), typed as aggregateType says. Without GROUP BY all rows form
+// a single group, so a query over an empty table still yields one row.
+func aggregate(resp *client.Response, stmt *ast.SELECTQueryStatement) (*client.Response, error) {
+	groupIdx := make([]int, len(stmt.GroupBy))
+	for i, col := range stmt.GroupBy {
+		idx := tableColumnIndex(resp.Columns, resp.Table, col)
+		if idx < 0 {
+			return nil, fmt.Errorf("unknown column %q in GROUP BY", col)
+		}
+		groupIdx[i] = idx
+	}
+
+	// Plain columns in the select list must be grouped on
+	for _, field := range stmt.Fields {
+		ident, ok := field.(*ast.Identifier)
+		if !ok {
+			continue
+		}
+		if ident.Name == "*" || tableColumnIndex(stmt.GroupBy, stmt.Table, ident.Name) < 0 {
+			return nil, fmt.Errorf("column %q must appear in GROUP BY or be used in an aggregate", ident.Name)
+		}
+	}
+
+	aggregates := collectAggregates(stmt)
+	argIdx := make([]int, len(aggregates))
+	for i, agg := range aggregates {
+		argIdx[i] = -1
+		if agg.Argument.Name == "*" {
+			continue
+		}
+		idx := tableColumnIndex(resp.Columns, resp.Table, agg.Argument.Name)
+		if idx < 0 {
+			return nil, fmt.Errorf("unknown column %q in %s", agg.Argument.Name, agg.String())
+		}
+		argIdx[i] = idx
+	}
+
+	// Bucket rows by group key, keeping groups in first-seen order
+	var keys []string
+	groups := make(map[string][]client.Row)
+	if len(stmt.GroupBy) == 0 {
+		keys = append(keys, "")
+		groups[""] = resp.Rows
+	} else {
+		for _, row := range resp.Rows {
+			parts := make([]string, len(groupIdx))
+			for i, idx := range groupIdx {
+				parts[i] = keyString(cell(row, idx))
+			}
+			key := strings.Join(parts, "\x00")
+			if _, seen := groups[key]; !seen {
+				keys = append(keys, key)
+			}
+			groups[key] = append(groups[key], row)
+		}
+	}
+
+	columns := make([]string, 0, len(groupIdx)+len(aggregates))
+	for _, idx := range groupIdx {
+		columns = append(columns, resp.Columns[idx])
+	}
+	for _, agg := range aggregates {
+		columns = append(columns, agg.String())
+	}
+
+	var types []string
+	if typed(resp) {
+		types = make([]string, 0, len(columns))
+		for _, idx := range groupIdx {
+			types = append(types, resp.Types[idx])
+		}
+		for i, agg := range aggregates {
+			argType := ""
+			if argIdx[i] >= 0 {
+				argType = resp.Types[argIdx[i]]
+			}
+			types = append(types, aggregateType(agg.Function, argType))
+		}
+	}
+
+	rows := make([]client.Row, 0, len(keys))
+	for i, key := range keys {
+		members := groups[key]
+		data := make([]interface{}, 0, len(columns))
+		if len(members) > 0 {
+			for _, idx := range groupIdx {
+				data = append(data, cell(members[0], idx))
+			}
+		}
+		for j, agg := range aggregates {
+			value, err := computeAggregate(agg, argIdx[j], members)
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, value)
+		}
+		rows = append(rows, client.Row{ID: i + 1, Data: data})
+	}
+
+	result := *resp
+	result.Columns = columns
+	result.Types = types
+	result.Rows = rows
+	return &result, nil
+}
+
+// aggregateType returns the type of an aggregate's result given the type
+// of its argument column, following computeAggregate: COUNT is an INT and
+// AVG a FLOAT, while MIN and MAX have their argument's type. SUM is an INT
+// or a FLOAT over a column of that type, and untyped over an untyped
+// column, where it may be either.
+func aggregateType(function string, argType string) string {
+	switch function {
+	case "COUNT":
+		return string(ast.IntColumn)
+	case "AVG":
+		return string(ast.FloatColumn)
+	case "SUM":
+		if argType == string(ast.IntColumn) || argType == string(ast.FloatColumn) {
+			return argType
+		}
+		return string(ast.UntypedColumn)
+	default:
+		return argType
+	}
+}
+
+// collectAggregates returns the distinct aggregate calls of the select list
+// and the HAVING clause
+func collectAggregates(stmt *ast.SELECTQueryStatement) []*ast.AggregateExpression {
+	seen := make(map[string]bool)
+	var result []*ast.AggregateExpression
+
+	var walk func(expr ast.Expression)
+	walk = func(expr ast.Expression) {
+		switch ex := expr.(type) {
+		case *ast.AggregateExpression:
+			if !seen[ex.String()] {
+				seen[ex.String()] = true
+				result = append(result, ex)
+			}
+		case *ast.BinaryExpression:
+			walk(ex.Left)
+			walk(ex.Right)
+		case *ast.UnaryExpression:
+			walk(ex.Operand)
+		}
+	}
+
+	for _, field := range stmt.Fields {
+		walk(field)
+	}
+	if stmt.Having != nil {
+		walk(stmt.Having)
+	}
+	return result
+}
+
+// computeAggregate evaluates one aggregate over the rows of a group. idx is
+// the argument column, or -1 for COUNT(*), which unlike COUNT(col) also
+// counts NULLs. SUM, AVG, MIN and MAX skip NULLs and are NULL themselves
+// when no value is left. COUNT is always an integer, SUM is an integer when
+// every summed value is one, and AVG is always a float.
+func computeAggregate(agg *ast.AggregateExpression, idx int, rows []client.Row) (interface{}, error) {
+	if agg.Function == "COUNT" {
+		count := int64(0)
+		for _, row := range rows {
+			if idx < 0 || !isNull(cell(row, idx)) {
+				count++
+			}
+		}
+		return count, nil
+	}
+
+	// The other aggregates ignore NULLs
+	values := make([]interface{}, 0, len(rows))
+	for _, row := range rows {
+		if v := cell(row, idx); !isNull(v) {
+			values = append(values, v)
+		}
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	switch agg.Function {
+	case "SUM", "AVG":
+		sum := 0.0
+		intSum := int64(0)
+		integers := true
+		for _, v := range values {
+			if n, ok := v.(int64); ok {
+				intSum += n
+				sum += float64(n)
+				continue
+			}
+			integers = false
+			f, err := strconv.ParseFloat(valueString(v), 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s requires numeric values, got %s", agg.String(), describeValue(v))
+			}
+			sum += f
+		}
+		if agg.Function == "AVG" {
+			return sum / float64(len(values)), nil
+		}
+		if integers {
+			return intSum, nil
+		}
+		return sum, nil
+
+	case "MIN", "MAX":
+		best := values[0]
+		for _, v := range values[1:] {
+			cmp := compareValues(v, best)
+			if (agg.Function == "MIN" && cmp < 0) || (agg.Function == "MAX" && cmp > 0) {
+				best = v
+			}
+		}
+		return best, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported aggregate function: %s", agg.Function)
+	}
+}
+
+// fieldNames returns the result column name of each select list entry
+func fieldNames(fields []ast.Expression) []string {
+	names := make([]string, len(fields))
+	for i, field := range fields {
+		names[i] = field.String()
+	}
+	return names
+}
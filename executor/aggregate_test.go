@@ -0,0 +1,89 @@
+package executor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAggregates(t *testing.T) {
+	e := newExecutor(t, people...)
+	all := "COUNT(*), COUNT(age), SUM(age), AVG(age), MIN(age), MAX(age)"
+	tests := []struct {
+		query string
+		want  [][]interface{}
+	}{
+		{"SELECT " + all + " FROM people", [][]interface{}{
+			{int64(4), int64(3), int64(90), 30.0, int64(20), int64(40)},
+		}},
+		{"SELECT city, " + all + " FROM people GROUP BY city", [][]interface{}{
+			{"oslo", int64(2), int64(2), int64(70), 35.0, int64(30), int64(40)},
+			{"rome", int64(2), int64(1), int64(20), 20.0, int64(20), int64(20)},
+		}},
+		// A group of NULLs counts them for COUNT(*) only
+		{"SELECT " + all + " FROM people WHERE name = 'dan'", [][]interface{}{
+			{int64(1), int64(0), nil, nil, nil, nil},
+		}},
+		// Without GROUP BY even no rows make a group
+		{"SELECT " + all + " FROM people WHERE name = 'zed'", [][]interface{}{
+			{int64(0), int64(0), nil, nil, nil, nil},
+		}},
+		{"SELECT city FROM people GROUP BY city HAVING COUNT(age) > 1", [][]interface{}{{"oslo"}}},
+		{"SELECT city, MAX(name) FROM people GROUP BY city HAVING MIN(age) < 25", [][]interface{}{{"rome", "dan"}}},
+		{"SELECT name FROM people GROUP BY name HAVING SUM(age) > 0 ORDER BY name DESC", [][]interface{}{
+			{"cid"}, {"bob"}, {"ann"},
+		}},
+		{"SELECT city, COUNT(*) FROM people GROUP BY city ORDER BY COUNT(*) DESC, city DESC LIMIT 1", [][]interface{}{
+			{"rome", int64(2)},
+		}},
+	}
+	for _, test := range tests {
+		if got := values(query(t, e, test.query)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.query, got, test.want)
+		}
+	}
+}
+
+func TestAggregateNulls(t *testing.T) {
+	e := newExecutor(t,
+		"CREATE TABLE n (k TEXT, x, f FLOAT)",
+		"INSERT INTO n VALUES ('a', NULL, NULL), ('a', 3, 1.5), ('b', NULL, NULL), ('a', 'z', NULL), ('b', NULL, 2)",
+	)
+	tests := []struct {
+		query string
+		want  [][]interface{}
+	}{
+		// MIN and MAX skip a leading NULL
+		{"SELECT k, COUNT(x), MIN(x), MAX(x) FROM n GROUP BY k", [][]interface{}{
+			{"a", int64(2), int64(3), "z"},
+			{"b", int64(0), nil, nil},
+		}},
+		// AVG divides by the number of values that are not NULL
+		{"SELECT k, COUNT(f), SUM(f), AVG(f) FROM n GROUP BY k", [][]interface{}{
+			{"a", int64(1), 1.5, 1.5},
+			{"b", int64(1), 2.0, 2.0},
+		}},
+		{"SELECT COUNT(*), COUNT(f), AVG(f) FROM n", [][]interface{}{{int64(5), int64(2), 1.75}}},
+	}
+	for _, test := range tests {
+		if got := values(query(t, e, test.query)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.query, got, test.want)
+		}
+	}
+
+	queryFails(t, e, "SELECT SUM(x) FROM n", "SUM(x) requires numeric values")
+}
+
+func TestAggregateErrors(t *testing.T) {
+	e := newExecutor(t, people...)
+	for q, message := range map[string]string{
+		"SELECT name, COUNT(*) FROM people":                    `column "name" must appear in GROUP BY`,
+		"SELECT *, COUNT(*) FROM people GROUP BY name":         `column "*" must appear in GROUP BY`,
+		"SELECT COUNT(height) FROM people":                     `unknown column "height" in COUNT(height)`,
+		"SELECT COUNT(*) FROM people GROUP BY height":          `unknown column "height" in GROUP BY`,
+		"SELECT SUM(city) FROM people":                         "SUM(city) requires numeric values",
+		"SELECT name FROM people WHERE COUNT(*) > 1":           "aggregate COUNT(*) is not allowed here",
+		"SELECT city FROM people GROUP BY city HAVING age > 1": `unknown column "age"`,
+	} {
+		queryFails(t, e, q, message)
+	}
+}
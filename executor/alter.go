@@ -0,0 +1,203 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+	"weird/db/engine/ast"
+	"weird/db/engine/client"
+	"weird/db/engine/parser"
+)
+
+// executeAlterTable executes an ALTER TABLE statement. Column changes are
+// validated here against the table's rows and constraints; the server then
+// rewrites the stored schema and rows.
+func (e *Executor) executeAlterTable(stmt *ast.ALTERTableStatement) (*client.Response, error) {
+	if rename, ok := stmt.Action.(*ast.RenameTableAction); ok {
+		return e.client.RenameTable(stmt.Table, rename.NewName)
+	}
+
+	schema, err := e.client.Schema(stmt.Table)
+	if err != nil {
+		return schema, err
+	}
+
+	switch action := stmt.Action.(type) {
+	case *ast.AddColumnAction:
+		return e.addColumn(schema, action.Column)
+	case *ast.DropColumnAction:
+		return e.dropColumn(schema, action.Column)
+	case *ast.RenameColumnAction:
+		return e.renameColumn(schema, action.Column, action.NewName)
+	default:
+		return nil, fmt.Errorf("unsupported ALTER TABLE action: %s", stmt.Action.String())
+	}
+}
+
+// addColumn adds a column to a table. Its constraints must hold for the
+// existing rows once they are given the column's DEFAULT (or NULL), so
+// e.g. NOT NULL without a DEFAULT only works on an empty table.
+func (e *Executor) addColumn(schema *client.Response, col ast.ColumnDefinition) (*client.Response, error) {
+	if columnIndex(schema.Columns, col.Name) >= 0 {
+		return nil, fmt.Errorf("column %q already exists in table %s", col.Name, schema.Table)
+	}
+
+	columns := append(append([]string(nil), schema.Columns...), col.Name)
+	definitions := make([]client.Column, len(columns))
+	types := make([]string, len(columns))
+	for i, name := range columns {
+		if i < len(schema.Types) {
+			types[i] = schema.Types[i]
+		}
+		definitions[i] = client.Column{Name: name, Type: types[i]}
+	}
+	types[len(types)-1] = string(col.Type)
+	definitions[len(definitions)-1].Type = string(col.Type)
+
+	b := newConstraintBuilder(schema.Table, columns, schema.Constraints)
+	if err := b.addColumn(col); err != nil {
+		return nil, err
+	}
+	if err := e.resolveForeignKeys(schema.Table, definitions, b.constraints); err != nil {
+		return nil, err
+	}
+
+	altered := *schema
+	altered.Columns = columns
+	altered.Types = types
+	altered.Constraints = b.constraints
+	ts, err := newTableSchema(&altered)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := e.client.SelectAll(schema.Table)
+	if err != nil {
+		return existing, err
+	}
+	rows := make([]map[string]interface{}, len(existing.Rows))
+	for i, row := range existing.Rows {
+		rows[i] = make(map[string]interface{}, len(columns))
+		for _, name := range schema.Columns {
+			rows[i][name] = cell(row, columnIndex(existing.Columns, name))
+		}
+		rows[i][col.Name] = ts.defaults[col.Name]
+		if err := ts.checkRow(rows[i]); err != nil {
+			return nil, err
+		}
+	}
+	if err := ts.checkKeys(&client.Response{Columns: columns}, rows); err != nil {
+		return nil, err
+	}
+	if col.References != nil {
+		if err := e.checkReferences(ts, rows); err != nil {
+			return nil, err
+		}
+	}
+
+	return e.client.AddColumn(schema.Table, definitions[len(definitions)-1], b.constraints)
+}
+
+// dropColumn removes a column from a table along with every constraint
+// that involves it. A column referenced by a foreign key of another table
+// cannot be dropped.
+func (e *Executor) dropColumn(schema *client.Response, column string) (*client.Response, error) {
+	idx := columnIndex(schema.Columns, column)
+	if idx < 0 {
+		return nil, fmt.Errorf("unknown column %q in table %s", column, schema.Table)
+	}
+	column = schema.Columns[idx]
+	if len(schema.Columns) == 1 {
+		return nil, fmt.Errorf("cannot drop column %q: it is the only column of table %s", column, schema.Table)
+	}
+
+	refs, err := e.client.References(schema.Table)
+	if err != nil {
+		return refs, err
+	}
+	for _, fk := range refs.Constraints {
+		ownKey := strings.EqualFold(fk.Table, schema.Table) && columnIndex(fk.Columns, column) >= 0
+		if columnIndex(fk.References.Columns, column) >= 0 && !ownKey {
+			return nil, fmt.Errorf("cannot drop column %q of table %s: it is referenced by foreign key %q of table %s",
+				column, schema.Table, fk.Name, fk.Table)
+		}
+	}
+
+	var kept []client.Constraint
+	for _, constraint := range schema.Constraints {
+		if columnIndex(constraintColumns(constraint), column) < 0 {
+			kept = append(kept, constraint)
+		}
+	}
+
+	return e.client.DropColumn(schema.Table, column, kept)
+}
+
+// renameColumn renames a column, rewriting the constraints that mention it
+func (e *Executor) renameColumn(schema *client.Response, column, newName string) (*client.Response, error) {
+	idx := columnIndex(schema.Columns, column)
+	if idx < 0 {
+		return nil, fmt.Errorf("unknown column %q in table %s", column, schema.Table)
+	}
+	column = schema.Columns[idx]
+	if other := columnIndex(schema.Columns, newName); other >= 0 && other != idx {
+		return nil, fmt.Errorf("column %q already exists in table %s", newName, schema.Table)
+	}
+
+	rename := func(columns []string) []string {
+		renamed := make([]string, len(columns))
+		for i, col := range columns {
+			renamed[i] = col
+			if strings.EqualFold(col, column) {
+				renamed[i] = newName
+			}
+		}
+		return renamed
+	}
+
+	constraints := make([]client.Constraint, len(schema.Constraints))
+	for i, constraint := range schema.Constraints {
+		constraint.Columns = rename(constraint.Columns)
+		if constraint.Type == client.CheckConstraint {
+			condition, err := parser.ParseExpression(constraint.Check)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CHECK constraint %q: %w", constraint.Name, err)
+			}
+			constraint.Check = renameIdentifiers(condition, column, newName).String()
+		}
+		if constraint.References != nil && strings.EqualFold(constraint.References.Table, schema.Table) {
+			ref := *constraint.References
+			ref.Columns = rename(ref.Columns)
+			constraint.References = &ref
+		}
+		constraints[i] = constraint
+	}
+
+	return e.client.RenameColumn(schema.Table, column, newName, constraints)
+}
+
+// constraintColumns returns the columns a constraint involves. CHECK
+// constraints stored without their columns have them taken from the
+// condition.
+func constraintColumns(constraint client.Constraint) []string {
+	if constraint.Type == client.CheckConstraint && len(constraint.Columns) == 0 {
+		if condition, err := parser.ParseExpression(constraint.Check); err == nil {
+			return referencedColumns(condition)
+		}
+	}
+	return constraint.Columns
+}
+
+// renameIdentifiers renames every reference to a column in an expression
+func renameIdentifiers(expr ast.Expression, column, newName string) ast.Expression {
+	switch ex := expr.(type) {
+	case *ast.Identifier:
+		if strings.EqualFold(ex.Name, column) {
+			return ast.NewIdentifier(newName)
+		}
+	case *ast.BinaryExpression:
+		return ast.NewBinaryExpression(renameIdentifiers(ex.Left, column, newName), ex.Operator, renameIdentifiers(ex.Right, column, newName))
+	case *ast.UnaryExpression:
+		return ast.NewUnaryExpression(ex.Operator, renameIdentifiers(ex.Operand, column, newName))
+	}
+	return expr
+}
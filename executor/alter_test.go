@@ -0,0 +1,63 @@
+package executor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAlterTable(t *testing.T) {
+	e := newExecutor(t, people...)
+	query(t, e, "ALTER TABLE people ADD COLUMN score FLOAT DEFAULT 1")
+	query(t, e, "ALTER TABLE people DROP COLUMN city")
+	query(t, e, "ALTER TABLE people RENAME COLUMN age TO years")
+	query(t, e, "ALTER TABLE people RENAME TO folk")
+	query(t, e, "INSERT INTO folk VALUES ('eve', 50, 2.5)")
+
+	resp := query(t, e, "SELECT * FROM folk WHERE years >= 30 ORDER BY name")
+	if want := []string{"name", "years", "score"}; !reflect.DeepEqual(resp.Columns, want) {
+		t.Errorf("got columns %v, want %v", resp.Columns, want)
+	}
+	if want := []string{"TEXT", "INT", "FLOAT"}; !reflect.DeepEqual(resp.Types, want) {
+		t.Errorf("got types %v, want %v", resp.Types, want)
+	}
+	want := [][]interface{}{{"ann", int64(30), 1.0}, {"cid", int64(40), 1.0}, {"eve", int64(50), 2.5}}
+	if got := values(resp); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	for q, message := range map[string]string{
+		"SELECT * FROM people":                                      "Table does not exist",
+		"SELECT city FROM folk":                                     `unknown column "city"`,
+		"ALTER TABLE folk ADD COLUMN NAME TEXT":                     `column "NAME" already exists in table folk`,
+		"ALTER TABLE folk DROP COLUMN age":                          `unknown column "age" in table folk`,
+		"ALTER TABLE folk RENAME COLUMN years TO name":              `column "name" already exists in table folk`,
+		"ALTER TABLE folk ADD COLUMN n INT NOT NULL":                `NOT NULL constraint "folk_n_not_null"`,
+		"ALTER TABLE folk ADD COLUMN k INT UNIQUE DEFAULT 1":        `UNIQUE constraint "folk_k_key"`,
+		"ALTER TABLE folk ADD COLUMN c INT CHECK (c > 0) DEFAULT 0": `CHECK constraint "folk_c_check"`,
+		"ALTER TABLE nowhere ADD COLUMN c INT":                      "Table does not exist",
+	} {
+		queryFails(t, e, q, message)
+	}
+}
+
+func TestAlterConstraints(t *testing.T) {
+	e := newExecutor(t,
+		"CREATE TABLE parents (id INT PRIMARY KEY, code TEXT)",
+		"CREATE TABLE kids (id INT PRIMARY KEY, parent INT REFERENCES parents, age INT CHECK (age < 18))",
+		"INSERT INTO parents VALUES (1, 'a')",
+		"INSERT INTO kids VALUES (1, 1, 5)",
+	)
+	queryFails(t, e, "ALTER TABLE parents DROP COLUMN id", `cannot drop column "id" of table parents: it is referenced by foreign key "kids_parent_fkey"`)
+	queryFails(t, e, "ALTER TABLE kids ADD COLUMN p2 INT REFERENCES parents DEFAULT 9", `FOREIGN KEY constraint "kids_p2_fkey"`)
+
+	// Constraints follow a renamed column and go with a dropped one
+	query(t, e, "ALTER TABLE kids RENAME COLUMN age TO years")
+	queryFails(t, e, "INSERT INTO kids VALUES (2, 1, 20)", `CHECK constraint "kids_age_check" on table kids violated by years = 20`)
+	query(t, e, "ALTER TABLE kids DROP COLUMN years")
+	query(t, e, "INSERT INTO kids VALUES (2, 1)")
+	query(t, e, "ALTER TABLE kids DROP COLUMN parent")
+	query(t, e, "ALTER TABLE parents DROP COLUMN id")
+
+	query(t, e, "ALTER TABLE kids ADD COLUMN nick TEXT UNIQUE")
+	queryFails(t, e, "UPDATE kids SET nick = 'x'", `UNIQUE constraint "kids_nick_key"`)
+}
@@ -0,0 +1,468 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"weird/db/engine/ast"
+	"weird/db/engine/client"
+)
+
+// SetAtomic turns atomic mode on or off. In atomic mode ExecuteProgram,
+// and so ExecuteQuery, runs each program in a transaction of its own: if a
+// statement fails, the changes of the ones before it are undone too.
+// Against a backend without transactions the executor instead records how
+// to take back each change a statement makes, and does so on failure. A
+// program run while a transaction is already open belongs to that
+// transaction instead.
+func (e *Executor) SetAtomic(atomic bool) {
+	e.atomic = atomic
+}
+
+func (e *Executor) executeAtomic(statements []ast.Statement) ([]*client.Response, error) {
+	for i, stmt := range statements {
+		if _, ok := stmt.(ast.TransactionStatement); ok {
+			return nil, &StatementError{Index: i, Statement: stmt,
+				Err: fmt.Errorf("transaction statements are not allowed in atomic mode")}
+		}
+	}
+
+	tx, err := e.base.Begin()
+	if errors.Is(err, client.ErrTxUnsupported) {
+		return e.executeJournaled(statements)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	e.tx = tx
+	e.client = tx
+	results, err := e.executeAll(statements)
+	if err != nil {
+		// executeAll rolled the transaction back
+		return results, err
+	}
+	tx, _ = e.endTx()
+	if _, err := tx.Commit(); err != nil {
+		return results, fmt.Errorf("failed to commit: %w", err)
+	}
+	return results, nil
+}
+
+// executeJournaled runs statements through a journal and has it undo
+// their changes if one fails
+func (e *Executor) executeJournaled(statements []ast.Statement) ([]*client.Response, error) {
+	j := newJournal(e.base)
+	e.client = j
+	defer func() { e.client = e.base }()
+
+	results, err := e.executeAll(statements)
+	if err != nil {
+		if uerr := j.undo(); uerr != nil {
+			return results, fmt.Errorf("%w (undo failed: %v)", err, uerr)
+		}
+		return results, fmt.Errorf("%w (changes undone)", err)
+	}
+	return results, nil
+}
+
+// inTransaction runs fn, which makes several changes, so that they apply
+// all or not at all: within the open transaction or atomic program if there
+// is one, else in a transaction of its own, or through a journal against a
+// backend without transactions.
+func (e *Executor) inTransaction(fn func() (*client.Response, error)) (*client.Response, error) {
+	if e.client != e.base {
+		return fn()
+	}
+
+	tx, err := e.base.Begin()
+	if errors.Is(err, client.ErrTxUnsupported) {
+		j := newJournal(e.base)
+		e.client = j
+		defer func() { e.client = e.base }()
+
+		resp, err := fn()
+		if err != nil {
+			if uerr := j.undo(); uerr != nil {
+				return resp, fmt.Errorf("%w (undo failed: %v)", err, uerr)
+			}
+		}
+		return resp, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	e.client = tx
+	resp, err := fn()
+	e.client = e.base
+	if err != nil {
+		if _, rerr := tx.Rollback(); rerr != nil {
+			return resp, fmt.Errorf("%w (rollback failed: %v)", err, rerr)
+		}
+		return resp, err
+	}
+	if _, err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit: %w", err)
+	}
+	return resp, nil
+}
+
+// journal passes requests on to a DbClient, recording for each change
+// that succeeds the requests that take it back: the rows an insert added
+// are deleted, updated rows get their old values again, deleted rows are
+// inserted anew under their old IDs and a change to a table's definition
+// is reversed. Only DropTable, Truncate and DropColumn, which discard
+// values, keep a copy of the whole table. undo makes the steps in reverse
+// order.
+type journal struct {
+	client.DbClient
+	steps []func() error
+}
+
+// tableImage is what it takes to make a table again: its definition and
+// its rows with their IDs
+type tableImage struct {
+	columns     []client.Column
+	constraints []client.Constraint
+	indexes     []client.Index
+	ids         []int
+	rows        []map[string]interface{}
+}
+
+func newJournal(db client.DbClient) *journal {
+	return &journal{DbClient: db}
+}
+
+// record adds step to the undo of a change, if the change was made
+func (j *journal) record(err error, step func() error) {
+	if err == nil {
+		j.steps = append(j.steps, step)
+	}
+}
+
+// undo takes back every change made through the journal, latest first
+func (j *journal) undo() error {
+	for i := len(j.steps) - 1; i >= 0; i-- {
+		if err := j.steps[i](); err != nil {
+			return err
+		}
+	}
+	j.steps = nil
+	return nil
+}
+
+// image returns the definition and rows of table, or nil if there is no
+// such table
+func (j *journal) image(table string) (*tableImage, error) {
+	schema, err := j.DbClient.Schema(table)
+	if err != nil {
+		if schema != nil && schema.Status == "error" {
+			return nil, nil
+		}
+		return nil, err
+	}
+	data, err := j.DbClient.SelectAll(table)
+	if err != nil {
+		return nil, err
+	}
+
+	image := &tableImage{
+		columns:     make([]client.Column, len(schema.Columns)),
+		constraints: schema.Constraints,
+		indexes:     schema.Indexes,
+	}
+	for i, name := range schema.Columns {
+		image.columns[i] = client.Column{Name: name}
+		if i < len(schema.Types) {
+			image.columns[i].Type = schema.Types[i]
+		}
+	}
+	image.ids, image.rows = rowValues(data)
+	return image, nil
+}
+
+// restore makes the table of image again under the name table
+func (j *journal) restore(table string, image *tableImage) error {
+	if _, err := j.DbClient.CreateTable(table, image.columns, image.constraints); err != nil {
+		return fmt.Errorf("restoring table %s: %w", table, err)
+	}
+	if err := j.reinsert(table, image.ids, image.rows); err != nil {
+		return err
+	}
+	for _, index := range image.indexes {
+		if _, err := j.DbClient.CreateIndex(table, index); err != nil {
+			return fmt.Errorf("restoring table %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// reinsert puts rows back into table under their old IDs
+func (j *journal) reinsert(table string, ids []int, rows []map[string]interface{}) error {
+	if _, err := j.DbClient.InsertRows(table, ids, rows); err != nil {
+		return fmt.Errorf("restoring rows of %s: %w", table, err)
+	}
+	return nil
+}
+
+// rowValues returns the IDs of the rows of resp and their values keyed by
+// column name
+func rowValues(resp *client.Response) ([]int, []map[string]interface{}) {
+	ids := make([]int, len(resp.Rows))
+	rows := make([]map[string]interface{}, len(resp.Rows))
+	for i, row := range resp.Rows {
+		ids[i] = row.ID
+		rows[i] = make(map[string]interface{}, len(resp.Columns))
+		for k, col := range resp.Columns {
+			if k < len(row.Data) {
+				rows[i][col] = row.Data[k]
+			}
+		}
+	}
+	return ids, rows
+}
+
+func (j *journal) CreateTable(table string, columns []client.Column, constraints []client.Constraint) (*client.Response, error) {
+	resp, err := j.DbClient.CreateTable(table, columns, constraints)
+	j.record(err, func() error {
+		_, err := j.DbClient.DropTable(table, false)
+		return err
+	})
+	return resp, err
+}
+
+func (j *journal) DropTable(table string, ifExists bool) (*client.Response, error) {
+	image, err := j.image(table)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := j.DbClient.DropTable(table, ifExists)
+	if image != nil {
+		j.record(err, func() error { return j.restore(table, image) })
+	}
+	return resp, err
+}
+
+func (j *journal) Truncate(table string) (*client.Response, error) {
+	data, err := j.DbClient.SelectAll(table)
+	if err != nil {
+		return data, err
+	}
+	ids, rows := rowValues(data)
+	resp, err := j.DbClient.Truncate(table)
+	j.record(err, func() error { return j.reinsert(table, ids, rows) })
+	return resp, err
+}
+
+func (j *journal) AddColumn(table string, column client.Column, constraints []client.Constraint) (*client.Response, error) {
+	schema, err := j.DbClient.Schema(table)
+	if err != nil {
+		return schema, err
+	}
+	resp, err := j.DbClient.AddColumn(table, column, constraints)
+	j.record(err, func() error {
+		_, err := j.DbClient.DropColumn(table, column.Name, schema.Constraints)
+		return err
+	})
+	return resp, err
+}
+
+// DropColumn copies the whole table, as adding the column back would put
+// it last
+func (j *journal) DropColumn(table string, column string, constraints []client.Constraint) (*client.Response, error) {
+	image, err := j.image(table)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := j.DbClient.DropColumn(table, column, constraints)
+	if image != nil {
+		j.record(err, func() error {
+			if _, err := j.DbClient.DropTable(table, false); err != nil {
+				return fmt.Errorf("restoring table %s: %w", table, err)
+			}
+			return j.restore(table, image)
+		})
+	}
+	return resp, err
+}
+
+func (j *journal) RenameColumn(table string, column string, newName string, constraints []client.Constraint) (*client.Response, error) {
+	schema, err := j.DbClient.Schema(table)
+	if err != nil {
+		return schema, err
+	}
+	resp, err := j.DbClient.RenameColumn(table, column, newName, constraints)
+	j.record(err, func() error {
+		_, err := j.DbClient.RenameColumn(table, newName, column, schema.Constraints)
+		return err
+	})
+	return resp, err
+}
+
+func (j *journal) RenameTable(table string, newName string) (*client.Response, error) {
+	resp, err := j.DbClient.RenameTable(table, newName)
+	j.record(err, func() error {
+		_, err := j.DbClient.RenameTable(newName, table)
+		return err
+	})
+	return resp, err
+}
+
+func (j *journal) CreateIndex(table string, index client.Index) (*client.Response, error) {
+	resp, err := j.DbClient.CreateIndex(table, index)
+	j.record(err, func() error {
+		_, err := j.DbClient.DropIndex(index.Name, false)
+		return err
+	})
+	return resp, err
+}
+
+// DropIndex first looks for the definition of the index, which only the
+// schemas tell
+func (j *journal) DropIndex(name string, ifExists bool) (*client.Response, error) {
+	tables, err := j.DbClient.ListTables()
+	if err != nil {
+		return nil, err
+	}
+	var owner string
+	var def client.Index
+	for _, info := range tables.Tables {
+		schema, err := j.DbClient.Schema(info.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, index := range schema.Indexes {
+			if index.Name == name {
+				owner, def = info.Name, index
+			}
+		}
+	}
+
+	resp, err := j.DbClient.DropIndex(name, ifExists)
+	if owner != "" {
+		j.record(err, func() error {
+			_, err := j.DbClient.CreateIndex(owner, def)
+			return err
+		})
+	}
+	return resp, err
+}
+
+func (j *journal) Insert(table string, values map[string]interface{}) (*client.Response, error) {
+	resp, err := j.DbClient.Insert(table, values)
+	if err == nil {
+		j.recordInsert(table, []int{resp.ID})
+	}
+	return resp, err
+}
+
+func (j *journal) InsertMany(table string, rows []map[string]interface{}) (*client.Response, error) {
+	resp, err := j.DbClient.InsertMany(table, rows)
+	if err != nil || len(rows) == 0 {
+		return resp, err
+	}
+	if len(resp.IDs) != len(rows) {
+		return resp, fmt.Errorf("the server did not tell the IDs of the rows inserted into %s", table)
+	}
+	j.recordInsert(table, resp.IDs)
+	return resp, nil
+}
+
+func (j *journal) InsertRows(table string, ids []int, rows []map[string]interface{}) (*client.Response, error) {
+	resp, err := j.DbClient.InsertRows(table, ids, rows)
+	if err == nil {
+		j.recordInsert(table, ids)
+	}
+	return resp, err
+}
+
+// recordInsert notes that the rows ids of table are to be deleted again
+func (j *journal) recordInsert(table string, ids []int) {
+	ids = append([]int{}, ids...)
+	j.record(nil, func() error {
+		if _, err := j.DbClient.DeleteRows(table, ids); err != nil {
+			return fmt.Errorf("removing rows of %s: %w", table, err)
+		}
+		return nil
+	})
+}
+
+func (j *journal) Update(table string, set map[string]interface{}, where map[string]interface{}) (*client.Response, error) {
+	before, err := j.DbClient.Select(table, where)
+	if err != nil {
+		return before, err
+	}
+	resp, err := j.DbClient.Update(table, set, where)
+	j.recordUpdate(err, table, before, set)
+	return resp, err
+}
+
+func (j *journal) UpdateRows(table string, ids []int, set map[string]interface{}) (*client.Response, error) {
+	before, err := j.DbClient.SelectRows(table, ids)
+	if err != nil {
+		return before, err
+	}
+	resp, err := j.DbClient.UpdateRows(table, ids, set)
+	j.recordUpdate(err, table, before, set)
+	return resp, err
+}
+
+// recordUpdate notes that the rows of before are to get back their values
+// of the columns in set
+func (j *journal) recordUpdate(err error, table string, before *client.Response, set map[string]interface{}) {
+	j.record(err, func() error {
+		for _, row := range before.Rows {
+			old := make(map[string]interface{}, len(set))
+			for k, col := range before.Columns {
+				if _, ok := set[col]; ok && k < len(row.Data) {
+					old[col] = row.Data[k]
+				}
+			}
+			if _, err := j.DbClient.UpdateRows(table, []int{row.ID}, old); err != nil {
+				return fmt.Errorf("restoring rows of %s: %w", table, err)
+			}
+		}
+		return nil
+	})
+}
+
+func (j *journal) Delete(table string, where map[string]interface{}) (*client.Response, error) {
+	before, err := j.DbClient.Select(table, where)
+	if err != nil {
+		return before, err
+	}
+	resp, err := j.DbClient.Delete(table, where)
+	j.recordDelete(err, table, before)
+	return resp, err
+}
+
+func (j *journal) DeleteRows(table string, ids []int) (*client.Response, error) {
+	before, err := j.DbClient.SelectRows(table, ids)
+	if err != nil {
+		return before, err
+	}
+	resp, err := j.DbClient.DeleteRows(table, ids)
+	j.recordDelete(err, table, before)
+	return resp, err
+}
+
+func (j *journal) DeleteAll(table string) (*client.Response, error) {
+	before, err := j.DbClient.SelectAll(table)
+	if err != nil {
+		return before, err
+	}
+	resp, err := j.DbClient.DeleteAll(table)
+	j.recordDelete(err, table, before)
+	return resp, err
+}
+
+// recordDelete notes that the rows of before are to be inserted again
+func (j *journal) recordDelete(err error, table string, before *client.Response) {
+	ids, rows := rowValues(before)
+	j.record(err, func() error { return j.reinsert(table, ids, rows) })
+}
+
+// Begin is refused: the journal already stands in for a transaction
+func (j *journal) Begin() (client.Tx, error) {
+	return nil, fmt.Errorf("transaction already in progress")
+}
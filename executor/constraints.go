@@ -0,0 +1,450 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+	"weird/db/engine/ast"
+	"weird/db/engine/client"
+	"weird/db/engine/parser"
+)
+
+// ConstraintError reports a row rejected by a table constraint. Columns
+// and Values hold the columns the constraint covers and the offending
+// values written to them.
+type ConstraintError struct {
+	Table      string
+	Constraint string // Constraint name, e.g. "users_email_key"
+	Type       string // One of the client.*Constraint kinds
+	Columns    []string
+	Values     []interface{}
+}
+
+func (e *ConstraintError) Error() string {
+	pairs := make([]string, len(e.Columns))
+	for i, col := range e.Columns {
+		value := "NULL"
+		if i < len(e.Values) && e.Values[i] != nil {
+			value = describeValue(e.Values[i])
+		}
+		pairs[i] = col + " = " + value
+	}
+	return fmt.Sprintf("%s constraint %q on table %s violated by %s",
+		constraintLabel(e.Type), e.Constraint, e.Table, strings.Join(pairs, ", "))
+}
+
+// constraintLabel returns the SQL spelling of a constraint kind
+func constraintLabel(kind string) string {
+	switch kind {
+	case client.PrimaryKeyConstraint:
+		return "PRIMARY KEY"
+	case client.UniqueConstraint:
+		return "UNIQUE"
+	case client.NotNullConstraint:
+		return "NOT NULL"
+	case client.CheckConstraint:
+		return "CHECK"
+	case client.ForeignKeyConstraint:
+		return "FOREIGN KEY"
+	default:
+		return strings.ToUpper(kind)
+	}
+}
+
+// tableSchema is the schema of a table as the executor validates writes
+// against it: column types plus the constraints stored with the table
+type tableSchema struct {
+	table       string
+	columns     []string
+	types       map[string]ast.ColumnType
+	defaults    map[string]interface{}
+	notNull     []client.Constraint
+	keys        []client.Constraint // PRIMARY KEY, UNIQUE and unique indexes
+	checks      []checkConstraint
+	foreignKeys []client.Constraint
+}
+
+// checkConstraint is a CHECK constraint with its parsed condition
+type checkConstraint struct {
+	client.Constraint
+	condition ast.Expression
+	columns   []string // Columns the condition refers to
+}
+
+// newTableSchema builds a tableSchema from a schema response
+func newTableSchema(schema *client.Response) (*tableSchema, error) {
+	ts := &tableSchema{
+		table:    schema.Table,
+		columns:  schema.Columns,
+		types:    columnTypes(schema),
+		defaults: make(map[string]interface{}),
+	}
+
+	for _, constraint := range schema.Constraints {
+		switch constraint.Type {
+		case client.PrimaryKeyConstraint, client.UniqueConstraint:
+			ts.keys = append(ts.keys, constraint)
+		case client.NotNullConstraint:
+			ts.notNull = append(ts.notNull, constraint)
+		case client.ForeignKeyConstraint:
+			ts.foreignKeys = append(ts.foreignKeys, constraint)
+		case client.DefaultConstraint:
+			if len(constraint.Columns) == 1 {
+				ts.defaults[constraint.Columns[0]] = constraint.Default
+			}
+		case client.CheckConstraint:
+			condition, err := parser.ParseExpression(constraint.Check)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CHECK constraint %q: %w", constraint.Name, err)
+			}
+			ts.checks = append(ts.checks, checkConstraint{
+				Constraint: constraint,
+				condition:  condition,
+				columns:    referencedColumns(condition),
+			})
+		}
+	}
+	// A unique index holds its rows to a key like a UNIQUE constraint does;
+	// checking it here enforces it on backends without indexes too
+	for _, index := range schema.Indexes {
+		if index.Unique {
+			ts.keys = append(ts.keys, client.Constraint{Type: client.UniqueConstraint, Name: index.Name, Columns: index.Columns})
+		}
+	}
+
+	return ts, nil
+}
+
+// constrained reports whether updates to the table must be validated
+// against the full rows they produce
+func (ts *tableSchema) constrained() bool {
+	return len(ts.notNull) > 0 || len(ts.keys) > 0 || len(ts.checks) > 0 || len(ts.foreignKeys) > 0
+}
+
+// isForeignKeyColumn reports whether a column is part of a FOREIGN KEY
+func (ts *tableSchema) isForeignKeyColumn(column string) bool {
+	for _, fk := range ts.foreignKeys {
+		if columnIndex(fk.Columns, column) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// isKeyColumn reports whether a column is part of a PRIMARY KEY or UNIQUE
+// constraint, and so may be referenced by a foreign key
+func (ts *tableSchema) isKeyColumn(column string) bool {
+	for _, key := range ts.keys {
+		if columnIndex(key.Columns, column) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// applyDefaults fills the columns an INSERT left out with their DEFAULT
+// value, or NULL when they have none
+func (ts *tableSchema) applyDefaults(row map[string]interface{}) {
+	for _, col := range ts.columns {
+		if _, ok := row[col]; !ok {
+			row[col] = ts.defaults[col]
+		}
+	}
+}
+
+// checkRow validates the NOT NULL and CHECK constraints of a complete row.
+// As in SQL, a CHECK is only violated when its condition is false, not
+// when a NULL makes it unknown.
+func (ts *tableSchema) checkRow(row map[string]interface{}) error {
+	for _, constraint := range ts.keys {
+		if constraint.Type != client.PrimaryKeyConstraint {
+			continue
+		}
+		for _, col := range constraint.Columns {
+			if row[col] == nil {
+				return ts.violation(constraint, constraint.Columns, row)
+			}
+		}
+	}
+
+	for _, constraint := range ts.notNull {
+		for _, col := range constraint.Columns {
+			if row[col] == nil {
+				return ts.violation(constraint, constraint.Columns, row)
+			}
+		}
+	}
+
+	data := make([]interface{}, len(ts.columns))
+	for i, col := range ts.columns {
+		data[i] = row[col]
+	}
+	candidate := client.Row{Data: data}
+
+	for _, check := range ts.checks {
+		value, err := evaluate(check.condition, ts.table, ts.columns, candidate)
+		if err == nil {
+			_, err = logical(value)
+		}
+		if err != nil {
+			return fmt.Errorf("CHECK constraint %q: %w", check.Name, err)
+		}
+		if value == false {
+			return ts.violation(check.Constraint, check.columns, row)
+		}
+	}
+
+	return nil
+}
+
+// checkKeys validates the PRIMARY KEY and UNIQUE constraints of rows about
+// to be written against the rows that stay in the table and against each
+// other. Keys containing NULL never conflict.
+func (ts *tableSchema) checkKeys(existing *client.Response, rows []map[string]interface{}) error {
+	for _, constraint := range ts.keys {
+		seen := keySet(existing, constraint.Columns)
+		for _, row := range rows {
+			key, ok := rowKey(row, constraint.Columns)
+			if !ok {
+				continue
+			}
+			if seen[key] {
+				return ts.violation(constraint, constraint.Columns, row)
+			}
+			seen[key] = true
+		}
+	}
+	return nil
+}
+
+// uniqueKey builds the key of a UNIQUE constraint from its column values,
+// reporting false when one of them is NULL. Values of different kinds never
+// share a key, so TEXT '01' and '1' are distinct.
+func uniqueKey(values []interface{}) (string, bool) {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		if v == nil {
+			return "", false
+		}
+		parts[i] = client.TypedKey(v)
+	}
+	return strings.Join(parts, "\x00"), true
+}
+
+// violation builds the error for a constraint broken by a row
+func (ts *tableSchema) violation(constraint client.Constraint, columns []string, row map[string]interface{}) error {
+	values := make([]interface{}, len(columns))
+	names := make([]string, len(columns))
+	for i, col := range columns {
+		names[i] = col
+		if idx := columnIndex(ts.columns, col); idx >= 0 {
+			names[i] = ts.columns[idx]
+		}
+		values[i] = row[names[i]]
+	}
+	return &ConstraintError{
+		Table:      ts.table,
+		Constraint: constraint.Name,
+		Type:       constraint.Type,
+		Columns:    names,
+		Values:     values,
+	}
+}
+
+// referencedColumns lists the distinct columns an expression refers to
+func referencedColumns(expr ast.Expression) []string {
+	var columns []string
+	seen := make(map[string]bool)
+
+	var walk func(expr ast.Expression)
+	walk = func(expr ast.Expression) {
+		switch ex := expr.(type) {
+		case *ast.Identifier:
+			if !seen[ex.Name] {
+				seen[ex.Name] = true
+				columns = append(columns, ex.Name)
+			}
+		case *ast.BinaryExpression:
+			walk(ex.Left)
+			walk(ex.Right)
+		case *ast.UnaryExpression:
+			walk(ex.Operand)
+		}
+	}
+
+	walk(expr)
+	return columns
+}
+
+// tableConstraints converts the column and table constraints of a CREATE
+// TABLE statement into named constraints. Unnamed constraints are named
+// after the table and their columns, following the PostgreSQL convention
+// (users_pkey, users_email_key, users_age_check, orders_user_id_fkey).
+// The referenced side of foreign keys is resolved by resolveForeignKeys.
+func tableConstraints(stmt *ast.CREATETableStatement) ([]client.Constraint, error) {
+	names := make([]string, len(stmt.Columns))
+	for i, col := range stmt.Columns {
+		names[i] = col.Name
+	}
+
+	b := newConstraintBuilder(stmt.Table, names, nil)
+	for _, col := range stmt.Columns {
+		if err := b.addColumn(col); err != nil {
+			return nil, err
+		}
+	}
+	for _, tc := range stmt.Constraints {
+		if err := b.addTableConstraint(tc); err != nil {
+			return nil, err
+		}
+	}
+	return b.constraints, nil
+}
+
+// constraintBuilder collects the named constraints of a table
+type constraintBuilder struct {
+	table       string
+	columns     []string
+	constraints []client.Constraint
+	used        map[string]bool
+}
+
+// newConstraintBuilder starts from the existing constraints of a table
+func newConstraintBuilder(table string, columns []string, existing []client.Constraint) *constraintBuilder {
+	b := &constraintBuilder{
+		table:       table,
+		columns:     columns,
+		constraints: append([]client.Constraint(nil), existing...),
+		used:        make(map[string]bool),
+	}
+	for _, constraint := range existing {
+		b.used[constraint.Name] = true
+	}
+	return b
+}
+
+// add appends a constraint, naming it after name when it has no name yet
+func (b *constraintBuilder) add(constraint client.Constraint, name string) error {
+	if constraint.Name == "" {
+		constraint.Name = name
+		for n := 1; b.used[constraint.Name]; n++ {
+			constraint.Name = fmt.Sprintf("%s%d", name, n)
+		}
+	} else if b.used[constraint.Name] {
+		return fmt.Errorf("constraint %q is defined more than once", constraint.Name)
+	}
+
+	if constraint.Type == client.PrimaryKeyConstraint {
+		for _, existing := range b.constraints {
+			if existing.Type == client.PrimaryKeyConstraint {
+				return fmt.Errorf("table %s can have only one PRIMARY KEY", b.table)
+			}
+		}
+	}
+
+	b.used[constraint.Name] = true
+	b.constraints = append(b.constraints, constraint)
+	return nil
+}
+
+// resolve maps constraint columns to the table's spelling of them
+func (b *constraintBuilder) resolve(columns []string) ([]string, error) {
+	resolved := make([]string, len(columns))
+	for i, col := range columns {
+		idx := tableColumnIndex(b.columns, b.table, col)
+		if idx < 0 {
+			return nil, fmt.Errorf("unknown column %q in constraint", col)
+		}
+		resolved[i] = b.columns[idx]
+	}
+	return resolved, nil
+}
+
+// addColumn adds the inline constraints of a column definition
+func (b *constraintBuilder) addColumn(col ast.ColumnDefinition) error {
+	prefix := b.table + "_" + col.Name
+	single := []string{col.Name}
+
+	if col.PrimaryKey {
+		if err := b.add(client.Constraint{Type: client.PrimaryKeyConstraint, Columns: single}, b.table+"_pkey"); err != nil {
+			return err
+		}
+	}
+	if col.Unique {
+		if err := b.add(client.Constraint{Type: client.UniqueConstraint, Columns: single}, prefix+"_key"); err != nil {
+			return err
+		}
+	}
+	if col.NotNull {
+		if err := b.add(client.Constraint{Type: client.NotNullConstraint, Columns: single}, prefix+"_not_null"); err != nil {
+			return err
+		}
+	}
+	if col.Default != nil && col.Default.Kind != ast.NullLiteral {
+		value, err := coerceValue(col.Default.Value, col.Type)
+		if err != nil {
+			return fmt.Errorf("DEFAULT for column %q: %w", col.Name, err)
+		}
+		if err := b.add(client.Constraint{Type: client.DefaultConstraint, Columns: single, Default: value}, prefix+"_default"); err != nil {
+			return err
+		}
+	}
+	if col.Check != nil {
+		columns, err := b.resolve(referencedColumns(col.Check))
+		if err != nil {
+			return err
+		}
+		if err := b.add(client.Constraint{Type: client.CheckConstraint, Columns: columns, Check: col.Check.String()}, prefix+"_check"); err != nil {
+			return err
+		}
+	}
+	if col.References != nil {
+		fk := client.Constraint{Type: client.ForeignKeyConstraint, Columns: single, References: reference(col.References)}
+		if err := b.add(fk, prefix+"_fkey"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addTableConstraint adds a table-level constraint
+func (b *constraintBuilder) addTableConstraint(tc ast.TableConstraint) error {
+	constraint := client.Constraint{Name: tc.Name}
+	var name string
+	columns := tc.Columns
+
+	switch tc.Kind {
+	case ast.PrimaryKeyConstraint:
+		constraint.Type = client.PrimaryKeyConstraint
+		name = b.table + "_pkey"
+	case ast.UniqueConstraint:
+		constraint.Type = client.UniqueConstraint
+		name = b.table + "_" + strings.Join(tc.Columns, "_") + "_key"
+	case ast.CheckConstraint:
+		constraint.Type = client.CheckConstraint
+		constraint.Check = tc.Check.String()
+		columns = referencedColumns(tc.Check)
+		name = b.table + "_check"
+	case ast.ForeignKeyConstraint:
+		constraint.Type = client.ForeignKeyConstraint
+		constraint.References = reference(tc.References)
+		name = b.table + "_" + strings.Join(tc.Columns, "_") + "_fkey"
+	}
+
+	resolved, err := b.resolve(columns)
+	if err != nil {
+		return err
+	}
+	if len(resolved) > 0 {
+		constraint.Columns = resolved
+	}
+	return b.add(constraint, name)
+}
+
+// reference converts the REFERENCES part of a foreign key
+func reference(fk *ast.ForeignKey) *client.Reference {
+	return &client.Reference{
+		Table:    fk.Table,
+		Columns:  append([]string(nil), fk.Columns...),
+		OnDelete: string(fk.OnDelete),
+	}
+}
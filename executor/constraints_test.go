@@ -0,0 +1,121 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"weird/db/engine/client"
+	"weird/db/engine/storage"
+)
+
+func TestKeyConstraints(t *testing.T) {
+	e := newExecutor(t,
+		"CREATE TABLE users (id INT PRIMARY KEY, email TEXT UNIQUE, tag UNIQUE)",
+		"INSERT INTO users VALUES (1, 'a@x', NULL), (2, NULL, 1)",
+	)
+	for q, message := range map[string]string{
+		"INSERT INTO users VALUES (1, 'b@x', NULL)":                   `PRIMARY KEY constraint "users_pkey" on table users violated by id = 1`,
+		"INSERT INTO users VALUES (NULL, 'b@x', NULL)":                `PRIMARY KEY constraint "users_pkey" on table users violated by id = NULL`,
+		"INSERT INTO users VALUES (3, 'a@x', NULL)":                   `UNIQUE constraint "users_email_key" on table users violated by email = "a@x"`,
+		"INSERT INTO users VALUES (3, 'c@x', 1.0)":                    `UNIQUE constraint "users_tag_key"`,
+		"INSERT INTO users VALUES (3, 'c@x', NULL), (4, 'c@x', NULL)": `UNIQUE constraint "users_email_key"`,
+		"UPDATE users SET email = 'a@x' WHERE id = 2":                 `UNIQUE constraint "users_email_key"`,
+		"UPDATE users SET id = 5":                                     `PRIMARY KEY constraint "users_pkey"`,
+	} {
+		queryFails(t, e, q, message)
+	}
+
+	// NULLs never conflict, and neither do values of different kinds
+	query(t, e, "INSERT INTO users VALUES (3, NULL, '1'), (4, NULL, NULL)")
+	// A row may be updated to the key it already has
+	query(t, e, "UPDATE users SET email = 'a@x' WHERE id = 1")
+	query(t, e, "UPDATE users SET id = 9 WHERE id = 4")
+
+	want := []interface{}{int64(1), int64(2), int64(3), int64(9)}
+	if got := firstColumn(t, e, "SELECT id FROM users ORDER BY id"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	var ce *ConstraintError
+	_, err := e.ExecuteQuery("INSERT INTO users (id) VALUES (2)")
+	if !errors.As(err, &ce) || ce.Type != client.PrimaryKeyConstraint || !reflect.DeepEqual(ce.Values, []interface{}{int64(2)}) {
+		t.Errorf("got %#v", err)
+	}
+}
+
+func TestRowConstraints(t *testing.T) {
+	e := newExecutor(t,
+		"CREATE TABLE items (name TEXT NOT NULL, qty INT DEFAULT 1 CHECK (qty >= 0), price FLOAT, CHECK (price > 0 OR name = 'free'))",
+	)
+	query(t, e, "INSERT INTO items (name, price) VALUES ('pen', 1.5)")
+	// A CHECK that NULL makes unknown passes
+	query(t, e, "INSERT INTO items (name, qty) VALUES ('cap', NULL)")
+	query(t, e, "INSERT INTO items VALUES ('free', 0, 0)")
+
+	want := [][]interface{}{{"pen", int64(1), 1.5}, {"cap", nil, nil}, {"free", int64(0), 0.0}}
+	if got := values(query(t, e, "SELECT * FROM items")); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	for q, message := range map[string]string{
+		"INSERT INTO items (qty) VALUES (1)":                               `NOT NULL constraint "items_name_not_null" on table items violated by name = NULL`,
+		"INSERT INTO items VALUES ('ink', -1, 2)":                          `CHECK constraint "items_qty_check" on table items violated by qty = -1`,
+		"INSERT INTO items VALUES ('ink', 1, 0)":                           `CHECK constraint "items_check" on table items violated by price = 0, name = "ink"`,
+		"UPDATE items SET name = NULL WHERE name = 'pen'":                  `NOT NULL constraint "items_name_not_null"`,
+		"UPDATE items SET qty = -2":                                        `CHECK constraint "items_qty_check"`,
+		"CREATE TABLE bad (a INT DEFAULT 'x')":                             `DEFAULT for column "a"`,
+		"CREATE TABLE bad (a INT PRIMARY KEY, PRIMARY KEY (a))":            "table bad can have only one PRIMARY KEY",
+		"CREATE TABLE bad (a INT, UNIQUE (b))":                             `unknown column "b" in constraint`,
+		"CREATE TABLE bad (a INT UNIQUE, CONSTRAINT bad_a_key UNIQUE (a))": `constraint "bad_a_key" is defined more than once`,
+	} {
+		queryFails(t, e, q, message)
+	}
+}
+
+// countingClient counts the tables read whole through it
+type countingClient struct {
+	client.DbClient
+	selectAll int
+}
+
+func (c *countingClient) SelectAll(table string) (*client.Response, error) {
+	c.selectAll++
+	return c.DbClient.SelectAll(table)
+}
+
+func TestKeyLookups(t *testing.T) {
+	c := &countingClient{DbClient: storage.NewEngine()}
+	e := NewExecutor(c)
+	query(t, e, "CREATE TABLE t (id INT PRIMARY KEY, code TEXT UNIQUE)")
+
+	batch := func(from, to int) string {
+		var rows []string
+		for i := from; i < to; i++ {
+			rows = append(rows, fmt.Sprintf("(%d, 'c%d')", i, i))
+		}
+		return "INSERT INTO t VALUES " + strings.Join(rows, ", ")
+	}
+
+	// A few rows at a time are checked by looking their keys up
+	for i := 0; i < 100; i++ {
+		query(t, e, batch(i, i+1))
+	}
+	query(t, e, "UPDATE t SET code = 'new' WHERE id = 5")
+	if c.selectAll != 0 {
+		t.Errorf("small writes read the table %d times", c.selectAll)
+	}
+	queryFails(t, e, batch(100, 101)+", (7, 'c7b')", `PRIMARY KEY constraint "t_pkey" on table t violated by id = 7`)
+
+	// A large batch reads the table once per constraint instead
+	query(t, e, batch(100, 200))
+	if c.selectAll != 2 {
+		t.Errorf("a large batch read the table %d times, want 2", c.selectAll)
+	}
+	queryFails(t, e, batch(200, 260)+", (150, 'x')", "violated by id = 150")
+	queryFails(t, e, batch(200, 260)+", (260, 'new')", `violated by code = "new"`)
+	if got := firstColumn(t, e, "SELECT COUNT(*) FROM t"); !reflect.DeepEqual(got, []interface{}{int64(200)}) {
+		t.Errorf("got count %v, want 200", got)
+	}
+}
@@ -0,0 +1,131 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+	"weird/db/engine/ast"
+	"weird/db/engine/client"
+)
+
+// executeShowTables lists the tables as a result set with one row per
+// table: its name, its column definitions and its row count
+func (e *Executor) executeShowTables(stmt *ast.SHOWTablesStatement) (*client.Response, error) {
+	resp, err := e.client.ListTables()
+	if err != nil {
+		return resp, err
+	}
+
+	rows := make([]client.Row, len(resp.Tables))
+	for i, table := range resp.Tables {
+		definitions := make([]string, len(table.Columns))
+		for j, col := range table.Columns {
+			definitions[j] = col
+			if j < len(table.Types) && table.Types[j] != "" {
+				definitions[j] += " " + table.Types[j]
+			}
+		}
+		rows[i] = client.Row{ID: i + 1, Data: []interface{}{table.Name, strings.Join(definitions, ", "), int64(table.Count)}}
+	}
+
+	result := *resp
+	result.Columns = []string{"table", "columns", "rows"}
+	result.Types = []string{"TEXT", "TEXT", "INT"}
+	result.Rows = rows
+	result.Count = len(rows)
+	return &result, nil
+}
+
+// executeDescribe describes a table as a result set with one row per
+// column: its name, type, whether it accepts NULL, the keys it is part of,
+// its default in SQL syntax and the names of the constraints and indexes
+// involving it. RowCount holds the table's row count.
+func (e *Executor) executeDescribe(stmt *ast.DESCRIBETableStatement) (*client.Response, error) {
+	resp, err := e.client.DescribeTable(stmt.Table)
+	if err != nil {
+		return resp, err
+	}
+
+	rows := make([]client.Row, len(resp.Columns))
+	for i, col := range resp.Columns {
+		var typ interface{}
+		if i < len(resp.Types) && resp.Types[i] != "" {
+			typ = resp.Types[i]
+		}
+
+		nullable := true
+		var keys, names []string
+		var defaultValue interface{}
+		for _, constraint := range resp.Constraints {
+			if columnIndex(constraintColumns(constraint), col) < 0 {
+				continue
+			}
+			names = append(names, constraint.Name)
+
+			switch constraint.Type {
+			case client.PrimaryKeyConstraint:
+				nullable = false
+				keys = append(keys, "PRIMARY KEY")
+			case client.UniqueConstraint:
+				keys = append(keys, "UNIQUE")
+			case client.NotNullConstraint:
+				nullable = false
+			case client.DefaultConstraint:
+				defaultValue = defaultText(constraint.Default)
+			case client.ForeignKeyConstraint:
+				keys = append(keys, fmt.Sprintf("REFERENCES %s (%s)",
+					constraint.References.Table, strings.Join(constraint.References.Columns, ", ")))
+			}
+		}
+
+		var indexes []string
+		for _, index := range resp.Indexes {
+			if columnIndex(index.Columns, col) < 0 {
+				continue
+			}
+			if index.Unique {
+				indexes = append(indexes, index.Name+" (UNIQUE)")
+			} else {
+				indexes = append(indexes, index.Name)
+			}
+		}
+
+		rows[i] = client.Row{ID: i + 1, Data: []interface{}{
+			col, typ, nullable, nullableText(strings.Join(keys, ", ")), defaultValue,
+			nullableText(strings.Join(names, ", ")), nullableText(strings.Join(indexes, ", ")),
+		}}
+	}
+
+	result := *resp
+	result.Columns = []string{"column", "type", "nullable", "key", "default", "constraints", "indexes"}
+	result.Types = []string{"TEXT", "TEXT", "BOOL", "TEXT", "TEXT", "TEXT", "TEXT"}
+	result.Rows = rows
+	result.Count = len(rows)
+	return &result, nil
+}
+
+// defaultText writes a DEFAULT value as the literal that gives it, so that
+// the text '1' and the number 1 read differently; NULL stays NULL
+func defaultText(value interface{}) interface{} {
+	switch v := client.NormalizeValue(value, "").(type) {
+	case nil:
+		return nil
+	case string:
+		return ast.NewStringLiteral(v).String()
+	case int64:
+		return ast.NewIntegerLiteral(v).String()
+	case float64:
+		return ast.NewFloatLiteral(v).String()
+	case bool:
+		return ast.NewBooleanLiteral(v).String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// nullableText returns NULL for an empty string
+func nullableText(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
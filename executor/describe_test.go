@@ -0,0 +1,64 @@
+package executor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestShowTables(t *testing.T) {
+	e := newExecutor(t, shop...)
+	query(t, e, "CREATE TABLE empty (x)")
+
+	resp := query(t, e, "SHOW TABLES")
+	if want := []string{"table", "columns", "rows"}; !reflect.DeepEqual(resp.Columns, want) {
+		t.Errorf("got columns %v, want %v", resp.Columns, want)
+	}
+	want := map[string][]interface{}{
+		"people": {"people", "name TEXT, age INT, city TEXT", int64(4)},
+		"orders": {"orders", "buyer TEXT, item TEXT, qty INT", int64(5)},
+		"empty":  {"empty", "x", int64(0)},
+	}
+	got := make(map[string][]interface{})
+	for _, row := range resp.Rows {
+		got[row.Data[0].(string)] = row.Data
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	e := newExecutor(t,
+		"CREATE TABLE users (id INT PRIMARY KEY, email TEXT NOT NULL UNIQUE, nick TEXT DEFAULT '1', n DEFAULT 1)",
+		"CREATE TABLE posts (id INT PRIMARY KEY, author INT REFERENCES users, stars INT CHECK (stars <= 5))",
+		"INSERT INTO users VALUES (1, 'a@x', NULL, NULL), (2, 'b@x', NULL, NULL)",
+	)
+
+	resp := query(t, e, "DESCRIBE users")
+	if want := []string{"column", "type", "nullable", "key", "default", "constraints", "indexes"}; !reflect.DeepEqual(resp.Columns, want) {
+		t.Errorf("got columns %v, want %v", resp.Columns, want)
+	}
+	want := [][]interface{}{
+		{"id", "INT", false, "PRIMARY KEY", nil, "users_pkey", nil},
+		{"email", "TEXT", false, "UNIQUE", nil, "users_email_key, users_email_not_null", nil},
+		{"nick", "TEXT", true, nil, "'1'", "users_nick_default", nil},
+		{"n", nil, true, nil, "1", "users_n_default", nil},
+	}
+	if got := values(resp); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if resp.RowCount != 2 {
+		t.Errorf("got row count %d, want 2", resp.RowCount)
+	}
+
+	want = [][]interface{}{
+		{"id", "INT", false, "PRIMARY KEY", nil, "posts_pkey", nil},
+		{"author", "INT", true, "REFERENCES users (id)", nil, "posts_author_fkey", nil},
+		{"stars", "INT", true, nil, nil, "posts_stars_check", nil},
+	}
+	if got := values(query(t, e, "DESC posts")); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	queryFails(t, e, "DESCRIBE nowhere", "Table does not exist")
+}
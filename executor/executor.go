@@ -11,7 +11,14 @@ type DbExecutor interface {
 	ExecuteQuery(query string) ([]*client.Response, error)
 }
 type Executor struct {
+	// client runs the statements: base, or the open transaction
 	client client.DbClient
+	base   client.DbClient
+	tx     client.Tx
+	atomic bool
+	// refs caches the foreign keys pointing at each table for the
+	// statements of one program, until one of them changes a schema
+	refs map[string][]client.Constraint
 }
 
 // NewExecutor creates a new executor with a database client
@@ -24,11 +31,18 @@ func NewExecutor(dbClient client.DbClient) *Executor {
 		fmt.Println(resp)*/
 	return &Executor{
 		client: dbClient,
+		base:   dbClient,
 	}
 }
 
 // Execute executes an AST statement and returns the response
 func (e *Executor) Execute(stmt ast.Statement) (*client.Response, error) {
+	switch stmt.(type) {
+	case *ast.SELECTQueryStatement, *ast.INSERTStatement, *ast.UPDATEStatement, *ast.DELETEStatement:
+	default:
+		e.refs = nil
+	}
+
 	switch s := stmt.(type) {
 	case *ast.SELECTQueryStatement:
 		return e.executeSelect(s)
@@ -38,133 +52,430 @@ func (e *Executor) Execute(stmt ast.Statement) (*client.Response, error) {
 		return e.executeUpdate(s)
 	case *ast.DELETEStatement:
 		return e.executeDelete(s)
+	case *ast.CREATETableStatement:
+		return e.executeCreateTable(s)
+	case *ast.SHOWTablesStatement:
+		return e.executeShowTables(s)
+	case *ast.DESCRIBETableStatement:
+		return e.executeDescribe(s)
+	case *ast.ALTERTableStatement:
+		return e.executeAlterTable(s)
+	case *ast.DROPTableStatement:
+		return e.executeDropTable(s)
+	case *ast.TRUNCATETableStatement:
+		return e.executeTruncate(s)
+	case *ast.CREATEIndexStatement:
+		return e.executeCreateIndex(s)
+	case *ast.DROPIndexStatement:
+		return e.client.DropIndex(s.Name, s.IfExists)
+	case *ast.BEGINStatement:
+		return e.executeBegin(s)
+	case *ast.COMMITStatement:
+		return e.executeCommit(s)
+	case *ast.ROLLBACKStatement:
+		return e.executeRollback(s)
 	default:
 		return nil, fmt.Errorf("unsupported statement type: %T", stmt)
 	}
 }
 
 func (e *Executor) executeSelect(stmt *ast.SELECTQueryStatement) (*client.Response, error) {
-	return e.client.SelectAll(stmt.Table)
+	var resp *client.Response
+	var err error
+	if len(stmt.Joins) > 0 {
+		resp, err = e.fetchJoined(stmt)
+		if err == nil && stmt.Where != nil {
+			resp, err = filterRows(resp, stmt.Where)
+		}
+	} else {
+		resp, err = e.fetchRows(stmt.Table, stmt.Where)
+	}
+	if err != nil {
+		return resp, err
+	}
+
+	if stmt.HasAggregates() {
+		if resp, err = aggregate(resp, stmt); err != nil {
+			return nil, err
+		}
+	}
+
+	if stmt.Having != nil {
+		if resp, err = filterRows(resp, stmt.Having); err != nil {
+			return nil, err
+		}
+	}
+
+	// Sort before projecting so ORDER BY may use columns not selected
+	if resp, err = sortRows(resp, stmt.OrderBy); err != nil {
+		return nil, err
+	}
+	resp = paginate(resp, stmt.Limit, stmt.Offset)
+
+	return project(resp, fieldNames(stmt.Fields))
 }
 
 func (e *Executor) executeInsert(stmt *ast.INSERTStatement) (*client.Response, error) {
-	values := make([]interface{}, len(stmt.Values))
-	for i, v := range stmt.Values {
-		values[i] = cleanValue(v)
+	schema, err := e.client.Schema(stmt.Table)
+	if err != nil {
+		return schema, err
 	}
-	return e.client.Insert(stmt.Table, values)
-}
 
-// executeUpdate executes an UPDATE statement
-func (e *Executor) executeUpdate(stmt *ast.UPDATEStatement) (*client.Response, error) {
-	// Convert assignments to map[string]interface{}
-	set := make(map[string]interface{})
-	for col, val := range stmt.Assignments {
-		set[col] = cleanValue(val)
+	var tuples [][]interface{}
+	if stmt.Select != nil {
+		resp, err := e.executeSelect(stmt.Select)
+		if err != nil {
+			return resp, err
+		}
+		for _, row := range resp.Rows {
+			tuples = append(tuples, row.Data)
+		}
+	} else {
+		for _, literals := range stmt.Rows {
+			tuple := make([]interface{}, len(literals))
+			for i, v := range literals {
+				tuple[i] = v.Value
+			}
+			tuples = append(tuples, tuple)
+		}
 	}
 
-	// Build WHERE clause if present
-	var where map[string]interface{}
-	if stmt.WhereColumn != "" {
-		where = map[string]interface{}{
-			stmt.WhereColumn: cleanValue(stmt.WhereValue),
+	ts, err := newTableSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]map[string]interface{}, len(tuples))
+	for i, tuple := range tuples {
+		if rows[i], err = mapInsertValues(schema.Columns, stmt.Columns, tuple); err != nil {
+			return nil, fmt.Errorf("row %d: %w", i+1, err)
+		}
+		ts.applyDefaults(rows[i])
+		if err = coerceRow(rows[i], ts.types); err != nil {
+			return nil, fmt.Errorf("row %d: %w", i+1, err)
 		}
+		if err = ts.checkRow(rows[i]); err != nil {
+			return nil, fmt.Errorf("row %d: %w", i+1, err)
+		}
+	}
+
+	if err := e.checkStoredKeys(ts, ts.keys, rows, nil); err != nil {
+		return nil, err
+	}
+	if err := e.checkReferences(ts, rows); err != nil {
+		return nil, err
 	}
 
-	return e.client.Update(stmt.Table, set, where)
+	// A single row keeps the plain insert so the response carries its ID
+	if len(rows) == 1 {
+		return e.client.Insert(stmt.Table, rows[0])
+	}
+	return e.client.InsertMany(stmt.Table, rows)
 }
 
-// executeDelete executes a DELETE statement
-func (e *Executor) executeDelete(stmt *ast.DELETEStatement) (*client.Response, error) {
-	// Build WHERE clause if present
-	var where map[string]interface{}
-	if stmt.WhereColumn != "" {
-		where = map[string]interface{}{
-			stmt.WhereColumn: cleanValue(stmt.WhereValue),
+// mapInsertValues pairs INSERT values with schema columns by name. Without
+// a column list the values must cover the whole schema in order; with one,
+// every listed column must exist and columns left out are absent from the
+// result, to be filled in with their defaults.
+func mapInsertValues(schema []string, columns []string, values []interface{}) (map[string]interface{}, error) {
+	if len(columns) == 0 {
+		if len(values) != len(schema) {
+			return nil, fmt.Errorf("table has %d columns but %d values were supplied", len(schema), len(values))
 		}
+		columns = schema
+	} else if len(values) != len(columns) {
+		return nil, fmt.Errorf("%d columns were listed but %d values were supplied", len(columns), len(values))
 	}
 
-	if where == nil {
-		// No WHERE clause means delete all
-		return e.client.DeleteAll(stmt.Table)
+	row := make(map[string]interface{}, len(schema))
+	for i, col := range columns {
+		idx := columnIndex(schema, col)
+		if idx < 0 {
+			return nil, fmt.Errorf("unknown column %q", col)
+		}
+		name := schema[idx]
+		if _, assigned := row[name]; assigned {
+			return nil, fmt.Errorf("column %q specified more than once", name)
+		}
+		row[name] = values[i]
 	}
 
-	return e.client.Delete(stmt.Table, where)
+	return row, nil
 }
 
-// cleanValue removes quotes from string literals and converts to appropriate type
-func cleanValue(value string) interface{} {
-	// Remove surrounding quotes if present
-	if len(value) >= 2 {
-		if (value[0] == '\'' && value[len(value)-1] == '\'') ||
-			(value[0] == '"' && value[len(value)-1] == '"') {
-			return value[1 : len(value)-1]
+// executeUpdate executes an UPDATE statement
+func (e *Executor) executeUpdate(stmt *ast.UPDATEStatement) (*client.Response, error) {
+	schema, err := e.client.Schema(stmt.Table)
+	if err != nil {
+		return schema, err
+	}
+
+	ts, err := newTableSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	// Convert assignments to map[string]interface{}, checking each value
+	// against its column type
+	set := make(map[string]interface{})
+	for col, val := range stmt.Assignments {
+		idx := tableColumnIndex(schema.Columns, stmt.Table, col)
+		if idx < 0 {
+			return nil, fmt.Errorf("unknown column %q in table %s", col, stmt.Table)
+		}
+		name := schema.Columns[idx]
+		value, err := coerceValue(val.Value, ts.types[name])
+		if err != nil {
+			return nil, fmt.Errorf("column %q: %w", name, err)
 		}
+		set[name] = value
 	}
 
-	// Try to detect if it's a number
-	// Simple check: if it contains only digits and possibly a decimal point
-	if isNumeric(value) {
-		return value // Return as string, let JSON marshaling handle it
+	// Push the WHERE clause down when the backend can evaluate it whole,
+	// unless constraints have to be checked on the updated rows first
+	where, exact := pushdownWhere(stmt.Where, stmt.Table)
+	if exact && !ts.constrained() {
+		if err := checkColumns(stmt.Where, stmt.Table, schema.Columns); err != nil {
+			return nil, err
+		}
+		return e.client.Update(stmt.Table, set, where)
 	}
 
-	return value
+	// Otherwise filter candidate rows here and update them by ID
+	matched, err := e.fetchRows(stmt.Table, stmt.Where)
+	if err != nil {
+		return matched, err
+	}
+	if ts.constrained() {
+		if err := e.checkUpdate(ts, matched, set); err != nil {
+			return nil, err
+		}
+	}
+	return e.client.UpdateRows(stmt.Table, rowIDs(matched), set)
+}
+
+// checkStoredKeys validates the given PRIMARY KEY and UNIQUE constraints
+// of rows about to be written against each other and against the rows the
+// table holds, other than the skipped ones. Stored keys are looked up as
+// findKeys does, so that a small write doesn't read the whole table. Keys
+// containing NULL never conflict.
+func (e *Executor) checkStoredKeys(ts *tableSchema, keys []client.Constraint, rows []map[string]interface{}, skip map[int]bool) error {
+	for _, constraint := range keys {
+		lookup := make(map[string]map[string]interface{})
+		for _, row := range rows {
+			key, ok := rowKey(row, constraint.Columns)
+			if !ok {
+				continue
+			}
+			if _, seen := lookup[key]; seen {
+				return ts.violation(constraint, constraint.Columns, row)
+			}
+			where := make(map[string]interface{}, len(constraint.Columns))
+			for _, col := range constraint.Columns {
+				where[col] = row[col]
+			}
+			lookup[key] = where
+		}
+
+		found := make(map[string]bool)
+		if err := e.findKeys(ts.table, constraint.Columns, lookup, skip, found); err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if key, ok := rowKey(row, constraint.Columns); ok && found[key] {
+				return ts.violation(constraint, constraint.Columns, row)
+			}
+		}
+	}
+	return nil
 }
 
-// isNumeric checks if a string represents a numeric value
-func isNumeric(s string) bool {
-	if len(s) == 0 {
-		return false
+// checkUpdate validates the rows an UPDATE would produce from the matched
+// rows against the table's constraints
+func (e *Executor) checkUpdate(ts *tableSchema, matched *client.Response, set map[string]interface{}) error {
+	updated := make([]map[string]interface{}, len(matched.Rows))
+	ids := make(map[int]bool, len(matched.Rows))
+	for i, row := range matched.Rows {
+		ids[row.ID] = true
+		values := make(map[string]interface{}, len(ts.columns))
+		for _, col := range ts.columns {
+			values[col] = cell(row, columnIndex(matched.Columns, col))
+		}
+		for col, value := range set {
+			values[col] = value
+		}
+		if err := ts.checkRow(values); err != nil {
+			return err
+		}
+		updated[i] = values
 	}
 
-	hasDot := false
-	for i, c := range s {
-		if c == '-' && i == 0 {
-			continue
+	// Keys must stay unique among the rows left untouched and the updated
+	// ones. Keys the update doesn't write to were unique before and stay so.
+	var changed []client.Constraint
+	for _, key := range ts.keys {
+		for _, col := range key.Columns {
+			if _, ok := set[col]; ok {
+				changed = append(changed, key)
+				break
+			}
 		}
-		if c == '.' {
-			if hasDot {
-				return false
+	}
+	if err := e.checkStoredKeys(ts, changed, updated, ids); err != nil {
+		return err
+	}
+
+	// Foreign keys only need checking when the update writes to them, and
+	// referencing rows only when it changes a key they may point at
+	for col := range set {
+		if ts.isForeignKeyColumn(col) {
+			if err := e.checkReferences(ts, updated); err != nil {
+				return err
 			}
-			hasDot = true
-			continue
+			break
 		}
-		if c < '0' || c > '9' {
-			return false
+	}
+	for col := range set {
+		if ts.isKeyColumn(col) {
+			return e.checkReferencedUpdate(ts, matched, set)
 		}
 	}
-	return true
+	return nil
 }
 
-// ExecuteProgram executes all statements in a program
-func (e *Executor) ExecuteProgram(program *ast.Program) ([]*client.Response, error) {
-	results := make([]*client.Response, 0, len(program.Statements))
+// executeDelete executes a DELETE statement
+func (e *Executor) executeDelete(stmt *ast.DELETEStatement) (*client.Response, error) {
+	// Rows of a table referenced by foreign keys are deleted by ID once
+	// the ON DELETE action of each reference has been planned, all in one
+	// transaction as cascades and SET NULL updates take several requests
+	refs, err := e.references(stmt.Table)
+	if err != nil {
+		return nil, err
+	}
+	if len(refs) > 0 {
+		return e.inTransaction(func() (*client.Response, error) {
+			matched, err := e.fetchRows(stmt.Table, stmt.Where)
+			if err != nil {
+				return matched, err
+			}
+			return e.deleteRows(stmt.Table, matched)
+		})
+	}
 
-	for _, stmt := range program.Statements {
-		resp, err := e.Execute(stmt)
+	if stmt.Where == nil {
+		// No WHERE clause means delete all
+		return e.client.DeleteAll(stmt.Table)
+	}
+
+	// Push the WHERE clause down when the backend can evaluate it whole
+	where, exact := pushdownWhere(stmt.Where, stmt.Table)
+	if exact {
+		schema, err := e.client.Schema(stmt.Table)
 		if err != nil {
-			return results, fmt.Errorf("failed to execute statement '%s': %w", stmt.String(), err)
+			return schema, err
 		}
-		results = append(results, resp)
+		if err := checkColumns(stmt.Where, stmt.Table, schema.Columns); err != nil {
+			return nil, err
+		}
+		return e.client.Delete(stmt.Table, where)
 	}
 
-	return results, nil
+	// Otherwise filter candidate rows here and delete them by ID
+	matched, err := e.fetchRows(stmt.Table, stmt.Where)
+	if err != nil {
+		return matched, err
+	}
+	return e.client.DeleteRows(stmt.Table, rowIDs(matched))
+}
+
+// executeCreateTable executes a CREATE TABLE statement
+func (e *Executor) executeCreateTable(stmt *ast.CREATETableStatement) (*client.Response, error) {
+	columns := make([]client.Column, len(stmt.Columns))
+	for i, col := range stmt.Columns {
+		columns[i] = client.Column{Name: col.Name, Type: string(col.Type)}
+	}
+
+	constraints, err := tableConstraints(stmt)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.resolveForeignKeys(stmt.Table, columns, constraints); err != nil {
+		return nil, err
+	}
+	return e.client.CreateTable(stmt.Table, columns, constraints)
+}
+
+// executeDropTable executes a DROP TABLE statement
+func (e *Executor) executeDropTable(stmt *ast.DROPTableStatement) (*client.Response, error) {
+	if err := e.checkNotReferenced(stmt.Table, "drop"); err != nil {
+		return nil, err
+	}
+	return e.client.DropTable(stmt.Table, stmt.IfExists)
+}
+
+// executeTruncate executes a TRUNCATE TABLE statement
+func (e *Executor) executeTruncate(stmt *ast.TRUNCATETableStatement) (*client.Response, error) {
+	if err := e.checkNotReferenced(stmt.Table, "truncate"); err != nil {
+		return nil, err
+	}
+	return e.client.Truncate(stmt.Table)
+}
+
+// executeCreateIndex executes a CREATE INDEX statement
+func (e *Executor) executeCreateIndex(stmt *ast.CREATEIndexStatement) (*client.Response, error) {
+	return e.client.CreateIndex(stmt.Table, client.Index{
+		Name:    stmt.Name,
+		Columns: stmt.Columns,
+		Unique:  stmt.Unique,
+	})
+}
+
+// StatementError reports the statement a program stopped at. Index is its
+// position in the program, counting from 0.
+type StatementError struct {
+	Index     int
+	Statement ast.Statement
+	Err       error
+}
+
+func (e *StatementError) Error() string {
+	return fmt.Sprintf("failed to execute statement %d '%s': %v", e.Index+1, e.Statement.String(), e.Err)
+}
+
+func (e *StatementError) Unwrap() error {
+	return e.Err
+}
+
+// ExecuteProgram executes all statements in a program, stopping at the
+// first that fails; a transaction it fails in is rolled back. In atomic
+// mode the program as a whole is applied or not at all, see SetAtomic.
+func (e *Executor) ExecuteProgram(program *ast.Program) ([]*client.Response, error) {
+	return e.ExecuteMultiple(program.Statements)
 }
 
 // ExecuteMultiple executes multiple statements and returns all results
 func (e *Executor) ExecuteMultiple(statements []ast.Statement) ([]*client.Response, error) {
+	e.refs = nil
+	if e.atomic && e.tx == nil {
+		return e.executeAtomic(statements)
+	}
+	return e.executeAll(statements)
+}
+
+func (e *Executor) executeAll(statements []ast.Statement) ([]*client.Response, error) {
 	results := make([]*client.Response, 0, len(statements))
 
-	for _, stmt := range statements {
+	for i, stmt := range statements {
 		resp, err := e.Execute(stmt)
 		if err != nil {
-			return results, fmt.Errorf("failed to execute statement '%s': %w", stmt.String(), err)
+			return results, e.abort(&StatementError{Index: i, Statement: stmt, Err: err})
 		}
 		results = append(results, resp)
 	}
 
 	return results, nil
 }
+
 func (e *Executor) ExecuteQuery(q string) ([]*client.Response, error) {
 	program, err := parser.ParseString(q)
 	if err != nil {
@@ -173,7 +484,12 @@ func (e *Executor) ExecuteQuery(q string) ([]*client.Response, error) {
 	return e.ExecuteProgram(program)
 }
 
-// Close closes the executor and its underlying client
+// Close closes the executor and its underlying client, rolling back a
+// transaction left open
 func (e *Executor) Close() error {
-	return e.client.Close()
+	if e.tx != nil {
+		tx, _ := e.endTx()
+		tx.Rollback()
+	}
+	return e.base.Close()
 }
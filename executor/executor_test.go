@@ -0,0 +1,124 @@
+package executor
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"weird/db/engine/client"
+	"weird/db/engine/storage"
+)
+
+// newExecutor returns an Executor over an in-process storage engine on
+// which the setup queries have been run
+func newExecutor(t *testing.T, setup ...string) *Executor {
+	t.Helper()
+	e := NewExecutor(storage.NewEngine())
+	for _, q := range setup {
+		query(t, e, q)
+	}
+	return e
+}
+
+// query runs q, failing the test unless it succeeds, and returns the
+// response to its last statement
+func query(t *testing.T, e *Executor, q string) *client.Response {
+	t.Helper()
+	results, err := e.ExecuteQuery(q)
+	if err != nil {
+		t.Fatalf("%s: %v", q, err)
+	}
+	return results[len(results)-1]
+}
+
+// queryFails fails the test unless q fails with an error containing message
+func queryFails(t *testing.T, e *Executor, q string, message string) {
+	t.Helper()
+	_, err := e.ExecuteQuery(q)
+	if err == nil {
+		t.Fatalf("%s: expected an error containing %q, got success", q, message)
+	}
+	if !strings.Contains(err.Error(), message) {
+		t.Fatalf("%s: expected an error containing %q, got %v", q, message, err)
+	}
+}
+
+// values returns the data of the rows of a response
+func values(resp *client.Response) [][]interface{} {
+	var data [][]interface{}
+	for _, row := range resp.Rows {
+		data = append(data, row.Data)
+	}
+	return data
+}
+
+func TestMapInsertValues(t *testing.T) {
+	schema := []string{"name", "age", "city"}
+	tests := []struct {
+		columns []string
+		values  []interface{}
+		want    map[string]interface{}
+		err     string
+	}{
+		{nil, []interface{}{"ann", 30, "oslo"}, map[string]interface{}{"name": "ann", "age": 30, "city": "oslo"}, ""},
+		{[]string{"city", "name"}, []interface{}{"oslo", "ann"}, map[string]interface{}{"name": "ann", "city": "oslo"}, ""},
+		{[]string{"AGE"}, []interface{}{30}, map[string]interface{}{"age": 30}, ""},
+		{nil, []interface{}{"ann", 30}, nil, "table has 3 columns but 2 values were supplied"},
+		{[]string{"name", "age"}, []interface{}{"ann"}, nil, "2 columns were listed but 1 values were supplied"},
+		{[]string{"name", "height"}, []interface{}{"ann", 1}, nil, `unknown column "height"`},
+		{[]string{"name", "Name"}, []interface{}{"ann", "bob"}, nil, `column "name" specified more than once`},
+	}
+	for _, test := range tests {
+		got, err := mapInsertValues(schema, test.columns, test.values)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%v %v: got error %v, want %q", test.columns, test.values, err, test.err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v %v: got %v, %v, want %v", test.columns, test.values, got, err, test.want)
+		}
+	}
+}
+
+func TestInsertColumns(t *testing.T) {
+	e := newExecutor(t, "CREATE TABLE people (name TEXT, age INT, city TEXT)")
+	query(t, e, "INSERT INTO people (city, name) VALUES ('oslo', 'ann')")
+	query(t, e, "INSERT INTO people VALUES ('bob', 20, 'rome')")
+	query(t, e, "INSERT INTO people (age) VALUES (40)")
+
+	want := [][]interface{}{{"ann", nil, "oslo"}, {"bob", int64(20), "rome"}, {nil, int64(40), nil}}
+	if got := values(query(t, e, "SELECT * FROM people")); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	queryFails(t, e, "INSERT INTO people VALUES ('cid', 40)", "table has 3 columns but 2 values were supplied")
+	queryFails(t, e, "INSERT INTO people (name, name) VALUES ('a', 'b')", `column "name" specified more than once`)
+	queryFails(t, e, "INSERT INTO people (height) VALUES (1)", `unknown column "height"`)
+}
+
+func TestInsertBatch(t *testing.T) {
+	e := newExecutor(t, people...)
+	if resp := query(t, e, "INSERT INTO people (name) VALUES ('eve'), ('fay')"); resp.Count != 2 {
+		t.Errorf("got count %d, want 2", resp.Count)
+	}
+	if resp := query(t, e, "INSERT INTO people (name) VALUES ('gus')"); resp.ID != 7 {
+		t.Errorf("got ID %d, want 7", resp.ID)
+	}
+
+	// A bad row keeps the whole batch out
+	queryFails(t, e, "INSERT INTO people VALUES ('hal', 1, 'x'), ('ida', 'old', 'y')", "row 2:")
+	queryFails(t, e, "INSERT INTO people VALUES ('hal', 1, 'x'), ('ida', 2)", "row 2: table has 3 columns")
+	if got := firstColumn(t, e, "SELECT name FROM people WHERE name = 'hal'"); got != nil {
+		t.Errorf("got %v after a failed batch", got)
+	}
+
+	query(t, e, "CREATE TABLE names (who TEXT, years INT)")
+	query(t, e, "INSERT INTO names SELECT name, age FROM people WHERE city = 'oslo' ORDER BY age DESC")
+	query(t, e, "INSERT INTO names (years, who) SELECT age, name FROM people WHERE name = 'bob'")
+	want := [][]interface{}{{"cid", int64(40)}, {"ann", int64(30)}, {"bob", int64(20)}}
+	if got := values(query(t, e, "SELECT * FROM names")); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	queryFails(t, e, "INSERT INTO names SELECT name FROM people", "row 1: table has 2 columns but 1 values were supplied")
+}
@@ -0,0 +1,475 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+	"weird/db/engine/client"
+)
+
+// resolveForeignKeys completes the FOREIGN KEY constraints of a new table:
+// a reference without columns points at the primary key of the referenced
+// table, and the referenced columns must exist and form its PRIMARY KEY or
+// a UNIQUE key. A table may reference itself.
+func (e *Executor) resolveForeignKeys(table string, columns []client.Column, constraints []client.Constraint) error {
+	for i := range constraints {
+		fk := &constraints[i]
+		if fk.Type != client.ForeignKeyConstraint {
+			continue
+		}
+
+		var parentColumns []string
+		var parentConstraints []client.Constraint
+		if strings.EqualFold(fk.References.Table, table) {
+			for _, col := range columns {
+				parentColumns = append(parentColumns, col.Name)
+			}
+			parentConstraints = constraints
+		} else {
+			schema, err := e.client.Schema(fk.References.Table)
+			if err != nil {
+				return fmt.Errorf("foreign key %q: %w", fk.Name, err)
+			}
+			fk.References.Table = schema.Table
+			parentColumns = schema.Columns
+			parentConstraints = schema.Constraints
+		}
+
+		if len(fk.References.Columns) == 0 {
+			for _, key := range parentConstraints {
+				if key.Type == client.PrimaryKeyConstraint {
+					fk.References.Columns = key.Columns
+				}
+			}
+			if len(fk.References.Columns) == 0 {
+				return fmt.Errorf("foreign key %q: table %s has no primary key", fk.Name, fk.References.Table)
+			}
+		}
+
+		for j, col := range fk.References.Columns {
+			idx := columnIndex(parentColumns, col)
+			if idx < 0 {
+				return fmt.Errorf("foreign key %q: unknown column %q in table %s", fk.Name, col, fk.References.Table)
+			}
+			fk.References.Columns[j] = parentColumns[idx]
+		}
+		if len(fk.References.Columns) != len(fk.Columns) {
+			return fmt.Errorf("foreign key %q: %d columns reference %d columns", fk.Name, len(fk.Columns), len(fk.References.Columns))
+		}
+
+		referencesKey := false
+		for _, key := range parentConstraints {
+			if (key.Type == client.PrimaryKeyConstraint || key.Type == client.UniqueConstraint) && sameColumns(key.Columns, fk.References.Columns) {
+				referencesKey = true
+			}
+		}
+		if !referencesKey {
+			return fmt.Errorf("foreign key %q: columns (%s) of table %s are not a PRIMARY KEY or UNIQUE",
+				fk.Name, strings.Join(fk.References.Columns, ", "), fk.References.Table)
+		}
+	}
+	return nil
+}
+
+// sameColumns reports whether two column lists hold the same columns,
+// ignoring order
+func sameColumns(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, col := range a {
+		if columnIndex(b, col) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// checkReferences validates the FOREIGN KEY constraints of rows about to
+// be written: every non-NULL key must exist in the referenced table. Rows
+// of a self-referencing table may also point at each other.
+func (e *Executor) checkReferences(ts *tableSchema, rows []map[string]interface{}) error {
+	for _, fk := range ts.foreignKeys {
+		keys := make(map[string]bool)
+		if strings.EqualFold(fk.References.Table, ts.table) {
+			for _, row := range rows {
+				if key, ok := rowKey(row, fk.References.Columns); ok {
+					keys[key] = true
+				}
+			}
+		}
+
+		// The distinct keys not found yet, with the values to look them up
+		missing := make(map[string]map[string]interface{})
+		for _, row := range rows {
+			key, ok := rowKey(row, fk.Columns)
+			if !ok || keys[key] {
+				continue
+			}
+			where := make(map[string]interface{}, len(fk.Columns))
+			for i, col := range fk.Columns {
+				where[fk.References.Columns[i]] = row[col]
+			}
+			missing[key] = where
+		}
+		if err := e.findKeys(fk.References.Table, fk.References.Columns, missing, nil, keys); err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			if key, ok := rowKey(row, fk.Columns); ok && !keys[key] {
+				return ts.violation(fk, fk.Columns, row)
+			}
+		}
+	}
+	return nil
+}
+
+// keyLookupLimit is the number of keys past which findKeys reads the whole
+// table rather than looking each key up
+const keyLookupLimit = 32
+
+// findKeys adds to found those of the keys on columns of a table, given as
+// WHERE maps on those columns, that the table holds in rows other than
+// the skipped ones
+func (e *Executor) findKeys(table string, columns []string, keys map[string]map[string]interface{}, skip map[int]bool, found map[string]bool) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	if len(keys) > keyLookupLimit {
+		resp, err := e.client.SelectAll(table)
+		if err != nil {
+			return err
+		}
+		for key := range keySet(withoutRows(resp, skip), columns) {
+			found[key] = true
+		}
+		return nil
+	}
+
+	for key, where := range keys {
+		resp, err := e.client.Select(table, where)
+		if err != nil {
+			return err
+		}
+		// The backend compares loosely; only a key of the same kind counts
+		if keySet(withoutRows(resp, skip), columns)[key] {
+			found[key] = true
+		}
+	}
+	return nil
+}
+
+// withoutRows returns a response without the rows whose IDs are in skip
+func withoutRows(resp *client.Response, skip map[int]bool) *client.Response {
+	if len(skip) == 0 {
+		return resp
+	}
+	kept := *resp
+	kept.Rows = nil
+	for _, row := range resp.Rows {
+		if !skip[row.ID] {
+			kept.Rows = append(kept.Rows, row)
+		}
+	}
+	return &kept
+}
+
+// references returns the FOREIGN KEY constraints pointing at table. Only
+// statements that change schemas change them, so they are looked up once
+// per program until such a statement runs.
+func (e *Executor) references(table string) ([]client.Constraint, error) {
+	if refs, ok := e.refs[table]; ok {
+		return refs, nil
+	}
+	resp, err := e.client.References(table)
+	if err != nil {
+		return nil, err
+	}
+	if e.refs == nil {
+		e.refs = make(map[string][]client.Constraint)
+	}
+	e.refs[table] = resp.Constraints
+	return resp.Constraints, nil
+}
+
+// checkReferencedUpdate refuses an UPDATE that changes key values still
+// referenced by a foreign key, since there is no ON UPDATE action
+func (e *Executor) checkReferencedUpdate(ts *tableSchema, matched *client.Response, set map[string]interface{}) error {
+	refs, err := e.references(ts.table)
+	if err != nil {
+		return err
+	}
+
+	for _, fk := range refs {
+		changes := false
+		for _, col := range fk.References.Columns {
+			if _, ok := set[col]; ok {
+				changes = true
+			}
+		}
+		if !changes {
+			continue
+		}
+
+		// Keys that no longer exist once the update is applied
+		removed := make(map[string]bool)
+		for _, row := range matched.Rows {
+			old, ok := responseRowKey(matched, row, fk.References.Columns)
+			if !ok {
+				continue
+			}
+			values := make(map[string]interface{}, len(fk.References.Columns))
+			for _, col := range fk.References.Columns {
+				values[col] = cell(row, columnIndex(matched.Columns, col))
+				if v, ok := set[col]; ok {
+					values[col] = v
+				}
+			}
+			if updated, ok := rowKey(values, fk.References.Columns); !ok || updated != old {
+				removed[old] = true
+			}
+		}
+		if len(removed) == 0 {
+			continue
+		}
+
+		child, err := e.client.SelectAll(fk.Table)
+		if err != nil {
+			return err
+		}
+		for _, row := range child.Rows {
+			if key, ok := responseRowKey(child, row, fk.Columns); ok && removed[key] {
+				return referenceError(fk, child, row)
+			}
+		}
+	}
+	return nil
+}
+
+// checkNotReferenced refuses to drop or truncate a table that other tables
+// reference
+func (e *Executor) checkNotReferenced(table, action string) error {
+	refs, err := e.client.References(table)
+	if err != nil {
+		return err
+	}
+	for _, fk := range refs.Constraints {
+		if !strings.EqualFold(fk.Table, table) {
+			return fmt.Errorf("cannot %s table %s: it is referenced by foreign key %q of table %s", action, table, fk.Name, fk.Table)
+		}
+	}
+	return nil
+}
+
+// deletePlan collects the effects of a DELETE on the tables that reference
+// the deleted rows, before anything is written
+type deletePlan struct {
+	tables  []string                // Tables with rows to delete, in discovery order
+	deletes map[string][]int        // Row IDs to delete per table
+	seen    map[string]map[int]bool // Rows already scheduled for deletion
+	nulls   []nullUpdate
+}
+
+// nullUpdate sets the foreign key columns of some rows to NULL
+type nullUpdate struct {
+	table   string
+	ids     []int
+	columns []string
+}
+
+// deleteRows deletes the given rows of a table and applies the ON DELETE
+// action of every foreign key that references them. The response counts
+// all rows affected, including cascaded deletes and SET NULL updates.
+func (e *Executor) deleteRows(table string, rows *client.Response) (*client.Response, error) {
+	plan := &deletePlan{
+		deletes: make(map[string][]int),
+		seen:    make(map[string]map[int]bool),
+	}
+	plan.schedule(table, rows.Rows)
+	if err := e.planDelete(plan, table, rows); err != nil {
+		return nil, err
+	}
+
+	total := 0
+	for _, update := range plan.nulls {
+		// Rows deleted by another cascade need no update
+		var ids []int
+		for _, id := range update.ids {
+			if !plan.seen[update.table][id] {
+				ids = append(ids, id)
+			}
+		}
+		set := make(map[string]interface{}, len(update.columns))
+		for _, col := range update.columns {
+			set[col] = nil
+		}
+		resp, err := e.client.UpdateRows(update.table, ids, set)
+		if err != nil {
+			return resp, err
+		}
+		total += resp.Count
+	}
+
+	// Delete referencing rows before the rows they reference
+	var resp *client.Response
+	for i := len(plan.tables) - 1; i >= 0; i-- {
+		var err error
+		if resp, err = e.client.DeleteRows(plan.tables[i], plan.deletes[plan.tables[i]]); err != nil {
+			return resp, err
+		}
+		total += resp.Count
+	}
+
+	result := *resp
+	result.Table = table
+	result.Count = total
+	return &result, nil
+}
+
+// schedule adds rows of a table to the plan, skipping rows already in it,
+// and returns the newly added ones
+func (plan *deletePlan) schedule(table string, rows []client.Row) []client.Row {
+	if plan.seen[table] == nil {
+		plan.seen[table] = make(map[int]bool)
+		plan.tables = append(plan.tables, table)
+	}
+
+	var added []client.Row
+	for _, row := range rows {
+		if !plan.seen[table][row.ID] {
+			plan.seen[table][row.ID] = true
+			plan.deletes[table] = append(plan.deletes[table], row.ID)
+			added = append(added, row)
+		}
+	}
+	return added
+}
+
+// planDelete finds the rows referencing deleted rows of a table and
+// schedules their ON DELETE action, following cascades recursively. A
+// RESTRICT reference fails the whole DELETE.
+func (e *Executor) planDelete(plan *deletePlan, table string, deleted *client.Response) error {
+	refs, err := e.references(table)
+	if err != nil {
+		return err
+	}
+
+	for _, fk := range refs {
+		keys := keySet(deleted, fk.References.Columns)
+		if len(keys) == 0 {
+			continue
+		}
+
+		child, err := e.client.SelectAll(fk.Table)
+		if err != nil {
+			return err
+		}
+
+		var hits []client.Row
+		for _, row := range child.Rows {
+			if plan.seen[fk.Table][row.ID] {
+				continue
+			}
+			if key, ok := responseRowKey(child, row, fk.Columns); ok && keys[key] {
+				hits = append(hits, row)
+			}
+		}
+		if len(hits) == 0 {
+			continue
+		}
+
+		switch fk.References.OnDelete {
+		case client.CascadeAction:
+			added := plan.schedule(fk.Table, hits)
+			cascaded := *child
+			cascaded.Rows = added
+			if err := e.planDelete(plan, fk.Table, &cascaded); err != nil {
+				return err
+			}
+
+		case client.SetNullAction:
+			if err := e.checkSetNull(fk, child, hits); err != nil {
+				return err
+			}
+			plan.nulls = append(plan.nulls, nullUpdate{table: fk.Table, ids: rowIDs(&client.Response{Rows: hits}), columns: fk.Columns})
+
+		default:
+			return referenceError(fk, child, hits[0])
+		}
+	}
+	return nil
+}
+
+// checkSetNull validates the referencing rows of an ON DELETE SET NULL
+// foreign key as they will be once their key columns are NULL
+func (e *Executor) checkSetNull(fk client.Constraint, child *client.Response, rows []client.Row) error {
+	schema, err := e.client.Schema(fk.Table)
+	if err != nil {
+		return err
+	}
+	ts, err := newTableSchema(schema)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		values := make(map[string]interface{}, len(ts.columns))
+		for _, col := range ts.columns {
+			values[col] = cell(row, columnIndex(child.Columns, col))
+		}
+		for _, col := range fk.Columns {
+			values[col] = nil
+		}
+		if err := ts.checkRow(values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// referenceError reports a referencing row that blocks a DELETE or UPDATE
+func referenceError(fk client.Constraint, child *client.Response, row client.Row) error {
+	values := make([]interface{}, len(fk.Columns))
+	for i, col := range fk.Columns {
+		values[i] = cell(row, columnIndex(child.Columns, col))
+	}
+	return &ConstraintError{
+		Table:      fk.Table,
+		Constraint: fk.Name,
+		Type:       client.ForeignKeyConstraint,
+		Columns:    fk.Columns,
+		Values:     values,
+	}
+}
+
+// keySet returns the non-NULL keys formed by the given columns of the rows
+// of a response
+func keySet(resp *client.Response, columns []string) map[string]bool {
+	keys := make(map[string]bool, len(resp.Rows))
+	for _, row := range resp.Rows {
+		if key, ok := responseRowKey(resp, row, columns); ok {
+			keys[key] = true
+		}
+	}
+	return keys
+}
+
+// responseRowKey builds the key of a response row from the given columns,
+// reporting false when one of them is NULL
+func responseRowKey(resp *client.Response, row client.Row, columns []string) (string, bool) {
+	values := make([]interface{}, len(columns))
+	for i, col := range columns {
+		values[i] = cell(row, columnIndex(resp.Columns, col))
+	}
+	return uniqueKey(values)
+}
+
+// rowKey builds the key of a row map from the given columns, reporting
+// false when one of them is NULL
+func rowKey(row map[string]interface{}, columns []string) (string, bool) {
+	values := make([]interface{}, len(columns))
+	for i, col := range columns {
+		values[i] = row[col]
+	}
+	return uniqueKey(values)
+}
@@ -0,0 +1,103 @@
+package executor
+
+import (
+	"reflect"
+	"testing"
+)
+
+// library has authors, their books, and the loans and reviews of books
+var library = []string{
+	"CREATE TABLE authors (id INT PRIMARY KEY, name TEXT UNIQUE)",
+	"CREATE TABLE books (id INT PRIMARY KEY, author INT REFERENCES authors ON DELETE CASCADE, title TEXT)",
+	"CREATE TABLE loans (book INT REFERENCES books (id), who TEXT)",
+	"CREATE TABLE reviews (book INT REFERENCES books ON DELETE SET NULL, stars INT)",
+	"INSERT INTO authors VALUES (1, 'ann'), (2, 'bob'), (3, 'cid')",
+	"INSERT INTO books VALUES (10, 1, 'a'), (11, 1, 'b'), (20, 2, 'c'), (30, 3, 'd')",
+	"INSERT INTO loans VALUES (20, 'eve')",
+	"INSERT INTO reviews VALUES (10, 5), (11, 3), (30, 4)",
+}
+
+func TestForeignKeyInsert(t *testing.T) {
+	e := newExecutor(t, library...)
+	query(t, e, "INSERT INTO books VALUES (12, 1, 'e'), (40, NULL, 'f')")
+	query(t, e, "UPDATE books SET author = 2 WHERE id = 12")
+
+	for q, message := range map[string]string{
+		"INSERT INTO books VALUES (50, 9, 'x')":                  `FOREIGN KEY constraint "books_author_fkey" on table books violated by author = 9`,
+		"UPDATE books SET author = 9 WHERE id = 10":              `FOREIGN KEY constraint "books_author_fkey"`,
+		"UPDATE authors SET id = 7 WHERE id = 2":                 `FOREIGN KEY constraint "books_author_fkey" on table books violated by author = 2`,
+		"CREATE TABLE bad (a INT REFERENCES nowhere)":            `foreign key "bad_a_fkey"`,
+		"CREATE TABLE bad (a INT REFERENCES books (title))":      `columns (title) of table books are not a PRIMARY KEY or UNIQUE`,
+		"CREATE TABLE bad (a INT REFERENCES loans)":              "table loans has no primary key",
+		"CREATE TABLE bad (a INT REFERENCES authors (id, name))": "1 columns reference 2 columns",
+		"DROP TABLE authors":                                     "cannot drop table authors: it is referenced by foreign key",
+	} {
+		queryFails(t, e, q, message)
+	}
+
+	// Keys that stay the same, or that nothing references, may change
+	query(t, e, "UPDATE authors SET id = 1 WHERE id = 1")
+	query(t, e, "UPDATE authors SET name = 'dan' WHERE id = 3")
+	query(t, e, "INSERT INTO authors VALUES (4, 'eli')")
+	query(t, e, "UPDATE authors SET id = 5 WHERE id = 4")
+}
+
+func TestForeignKeyDelete(t *testing.T) {
+	e := newExecutor(t, library...)
+
+	// RESTRICT keeps a loaned book, and with it the whole DELETE
+	queryFails(t, e, "DELETE FROM authors WHERE id = 2", `FOREIGN KEY constraint "loans_book_fkey" on table loans violated by book = 20`)
+	if got := firstColumn(t, e, "SELECT id FROM books ORDER BY id"); len(got) != 4 {
+		t.Errorf("got books %v after a refused DELETE", got)
+	}
+
+	// Deleting an author cascades to their books, whose reviews lose them
+	resp := query(t, e, "DELETE FROM authors WHERE id = 1")
+	if resp.Count != 5 {
+		t.Errorf("got count %d, want 5: 1 author, 2 books and 2 reviews", resp.Count)
+	}
+	tests := []struct {
+		query string
+		want  [][]interface{}
+	}{
+		{"SELECT id FROM authors ORDER BY id", [][]interface{}{{int64(2)}, {int64(3)}}},
+		{"SELECT id, author FROM books ORDER BY id", [][]interface{}{{int64(20), int64(2)}, {int64(30), int64(3)}}},
+		{"SELECT book, stars FROM reviews ORDER BY stars", [][]interface{}{{nil, int64(3)}, {int64(30), int64(4)}, {nil, int64(5)}}},
+	}
+	for _, test := range tests {
+		if got := values(query(t, e, test.query)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.query, got, test.want)
+		}
+	}
+
+	query(t, e, "DELETE FROM loans")
+	if resp := query(t, e, "DELETE FROM authors"); resp.Count != 5 {
+		t.Errorf("got count %d, want 5: 2 authors, 2 books and 1 review", resp.Count)
+	}
+}
+
+func TestForeignKeySelfReference(t *testing.T) {
+	e := newExecutor(t,
+		"CREATE TABLE staff (id INT PRIMARY KEY, boss INT REFERENCES staff ON DELETE CASCADE)",
+		// Rows of one statement may reference each other
+		"INSERT INTO staff VALUES (1, NULL), (2, 1), (3, 2), (4, 1)",
+	)
+	queryFails(t, e, "INSERT INTO staff VALUES (5, 6)", "violated by boss = 6")
+
+	if resp := query(t, e, "DELETE FROM staff WHERE id = 2"); resp.Count != 2 {
+		t.Errorf("got count %d, want 2", resp.Count)
+	}
+	want := []interface{}{int64(1), int64(4)}
+	if got := firstColumn(t, e, "SELECT id FROM staff ORDER BY id"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// SET NULL may not break another constraint of the referencing row
+	e = newExecutor(t,
+		"CREATE TABLE a (id INT PRIMARY KEY)",
+		"CREATE TABLE b (a INT NOT NULL REFERENCES a ON DELETE SET NULL)",
+		"INSERT INTO a VALUES (1)",
+		"INSERT INTO b VALUES (1)",
+	)
+	queryFails(t, e, "DELETE FROM a", `NOT NULL constraint "b_a_not_null"`)
+}
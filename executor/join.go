@@ -0,0 +1,219 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+	"weird/db/engine/ast"
+	"weird/db/engine/client"
+	"weird/db/engine/token"
+)
+
+// fetchJoined selects the FROM table and every joined table and combines
+// them into one response whose columns are qualified with their table name
+// ("users.name"). Equality conditions between the two sides of an ON clause
+// are executed as a hash join; any remaining condition is checked on each
+// candidate pair. WHERE conditions on a single table ("users.age > 30") are
+// pushed down to that table's select, but the caller must still apply the
+// full WHERE clause to the result.
+func (e *Executor) fetchJoined(stmt *ast.SELECTQueryStatement) (*client.Response, error) {
+	left, err := e.fetchQualified(stmt.Table, tablePushdown(stmt.Where, stmt.Table))
+	if err != nil {
+		return left, err
+	}
+
+	for _, join := range stmt.Joins {
+		// Filtering the right side of a LEFT JOIN early would turn
+		// non-matching rows into NULL-extended ones, so it is never pushed
+		var where map[string]interface{}
+		if join.Type != ast.LeftJoin {
+			where = tablePushdown(stmt.Where, join.Table)
+		}
+
+		right, err := e.fetchQualified(join.Table, where)
+		if err != nil {
+			return right, err
+		}
+
+		if left, err = joinRows(left, right, join); err != nil {
+			return nil, err
+		}
+	}
+
+	return left, nil
+}
+
+// fetchQualified selects the rows of a table and qualifies its columns
+func (e *Executor) fetchQualified(table string, where map[string]interface{}) (*client.Response, error) {
+	var resp *client.Response
+	var err error
+	if where != nil {
+		resp, err = e.client.Select(table, where)
+	} else {
+		resp, err = e.client.SelectAll(table)
+	}
+	if err != nil {
+		return resp, err
+	}
+
+	columns := make([]string, len(resp.Columns))
+	for i, col := range resp.Columns {
+		columns[i] = table + "." + col
+	}
+
+	qualified := *resp
+	qualified.Table = table
+	qualified.Columns = columns
+	return &qualified, nil
+}
+
+// tablePushdown returns the conditions of a WHERE clause that only involve
+// columns qualified with the given table, keyed by unqualified column name
+func tablePushdown(predicate ast.Expression, table string) map[string]interface{} {
+	if predicate == nil {
+		return nil
+	}
+
+	all := make(map[string]interface{})
+	collectConditions(predicate, all)
+
+	prefix := table + "."
+	var where map[string]interface{}
+	for name, condition := range all {
+		if len(name) > len(prefix) && strings.EqualFold(name[:len(prefix)], prefix) {
+			if where == nil {
+				where = make(map[string]interface{})
+			}
+			where[name[len(prefix):]] = condition
+		}
+	}
+	return where
+}
+
+// joinRows joins two responses according to a join clause
+func joinRows(left, right *client.Response, join ast.JoinClause) (*client.Response, error) {
+	columns := make([]string, 0, len(left.Columns)+len(right.Columns))
+	columns = append(columns, left.Columns...)
+	columns = append(columns, right.Columns...)
+
+	var types []string
+	if typed(left) && typed(right) {
+		types = make([]string, 0, len(columns))
+		types = append(types, left.Types...)
+		types = append(types, right.Types...)
+	}
+
+	leftKeys, rightKeys := equiJoinKeys(join.On, left.Columns, right.Columns)
+
+	// Index the right side by its join key; without equality keys every
+	// right row is a candidate for every left row. A NULL key equals
+	// nothing, so such rows are never candidates.
+	buckets := make(map[string][]client.Row)
+	for _, row := range right.Rows {
+		if key, ok := joinKey(row, rightKeys); ok {
+			buckets[key] = append(buckets[key], row)
+		}
+	}
+
+	rows := make([]client.Row, 0)
+	for _, l := range left.Rows {
+		matched := false
+		var candidates []client.Row
+		if key, ok := joinKey(l, leftKeys); ok {
+			candidates = buckets[key]
+		}
+		for _, r := range candidates {
+			combined := client.Row{Data: concatData(l.Data, len(left.Columns), r.Data, len(right.Columns))}
+			if join.On != nil {
+				ok, err := matches(join.On, "", columns, combined)
+				if err != nil {
+					return nil, fmt.Errorf("in JOIN %s: %w", join.Table, err)
+				}
+				if !ok {
+					continue
+				}
+			}
+			matched = true
+			rows = append(rows, combined)
+		}
+
+		if !matched && join.Type == ast.LeftJoin {
+			// Unmatched left rows are kept with NULL right columns
+			rows = append(rows, client.Row{Data: concatData(l.Data, len(left.Columns), nil, len(right.Columns))})
+		}
+	}
+
+	for i := range rows {
+		rows[i].ID = i + 1
+	}
+
+	result := *left
+	result.Columns = columns
+	result.Types = types
+	result.Rows = rows
+	return &result, nil
+}
+
+// equiJoinKeys finds the column = column conditions in the top-level AND
+// chain of an ON clause that compare a left column with a right column,
+// returning the matching column indexes on each side
+func equiJoinKeys(on ast.Expression, leftColumns, rightColumns []string) ([]int, []int) {
+	var leftKeys, rightKeys []int
+
+	var walk func(expr ast.Expression)
+	walk = func(expr ast.Expression) {
+		b, ok := expr.(*ast.BinaryExpression)
+		if !ok {
+			return
+		}
+		if b.Operator == token.AND_TOKEN {
+			walk(b.Left)
+			walk(b.Right)
+			return
+		}
+		if b.Operator != token.EQUALS_TOKEN {
+			return
+		}
+
+		a, aok := b.Left.(*ast.Identifier)
+		c, cok := b.Right.(*ast.Identifier)
+		if !aok || !cok {
+			return
+		}
+
+		if li, ri := columnIndex(leftColumns, a.Name), columnIndex(rightColumns, c.Name); li >= 0 && ri >= 0 {
+			leftKeys = append(leftKeys, li)
+			rightKeys = append(rightKeys, ri)
+		} else if li, ri := columnIndex(leftColumns, c.Name), columnIndex(rightColumns, a.Name); li >= 0 && ri >= 0 {
+			leftKeys = append(leftKeys, li)
+			rightKeys = append(rightKeys, ri)
+		}
+	}
+
+	if on != nil {
+		walk(on)
+	}
+	return leftKeys, rightKeys
+}
+
+// joinKey builds the hash key of a row from its join columns, reporting
+// false when one of them is NULL. Numbers are normalized so that an INT 1
+// and a FLOAT 1.0 land in the same bucket.
+func joinKey(row client.Row, keys []int) (string, bool) {
+	parts := make([]string, len(keys))
+	for i, idx := range keys {
+		value := cell(row, idx)
+		if value == nil {
+			return "", false
+		}
+		parts[i] = keyString(value)
+	}
+	return strings.Join(parts, "\x00"), true
+}
+
+// concatData appends two rows' data, padding each to its schema width
+func concatData(left []interface{}, leftWidth int, right []interface{}, rightWidth int) []interface{} {
+	data := make([]interface{}, leftWidth+rightWidth)
+	copy(data[:leftWidth], left)
+	copy(data[leftWidth:], right)
+	return data
+}
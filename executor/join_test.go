@@ -0,0 +1,87 @@
+package executor
+
+import (
+	"reflect"
+	"testing"
+)
+
+// shop adds to people their orders; one order has no buyer and one a
+// buyer who doesn't exist
+var shop = append(append([]string(nil), people...),
+	"CREATE TABLE orders (buyer TEXT, item TEXT, qty INT)",
+	"INSERT INTO orders VALUES ('ann', 'pen', 2), ('cid', 'ink', 1), ('ann', 'cap', 5), (NULL, 'box', 1), ('eve', 'pad', 3)",
+)
+
+func TestJoin(t *testing.T) {
+	e := newExecutor(t, shop...)
+	tests := []struct {
+		query string
+		want  [][]interface{}
+	}{
+		{"SELECT people.name, orders.item FROM people JOIN orders ON people.name = orders.buyer", [][]interface{}{
+			{"ann", "pen"}, {"ann", "cap"}, {"cid", "ink"},
+		}},
+		{"SELECT name, item FROM people INNER JOIN orders ON buyer = name WHERE qty > 1", [][]interface{}{
+			{"ann", "pen"}, {"ann", "cap"},
+		}},
+		// Unmatched rows on the left are kept with NULLs on the right
+		{"SELECT name, item FROM people LEFT JOIN orders ON name = buyer", [][]interface{}{
+			{"ann", "pen"}, {"ann", "cap"}, {"bob", nil}, {"cid", "ink"}, {"dan", nil},
+		}},
+		{"SELECT name, item FROM people LEFT OUTER JOIN orders ON name = buyer AND qty > 2", [][]interface{}{
+			{"ann", "cap"}, {"bob", nil}, {"cid", nil}, {"dan", nil},
+		}},
+		// A condition on the right table of a LEFT JOIN in WHERE drops the
+		// NULL-extended rows
+		{"SELECT name FROM people LEFT JOIN orders ON name = buyer WHERE orders.qty = 1", [][]interface{}{{"cid"}}},
+		{"SELECT name, item FROM people, orders WHERE people.name = orders.buyer AND people.city = 'oslo'", [][]interface{}{
+			{"ann", "pen"}, {"ann", "cap"}, {"cid", "ink"},
+		}},
+		{"SELECT name, COUNT(*), SUM(qty) FROM people JOIN orders ON name = buyer GROUP BY name ORDER BY name", [][]interface{}{
+			{"ann", int64(2), int64(7)}, {"cid", int64(1), int64(1)},
+		}},
+	}
+	for _, test := range tests {
+		if got := values(query(t, e, test.query)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.query, got, test.want)
+		}
+	}
+
+	resp := query(t, e, "SELECT * FROM people JOIN orders ON name = buyer LIMIT 1")
+	want := []string{"people.name", "people.age", "people.city", "orders.buyer", "orders.item", "orders.qty"}
+	if !reflect.DeepEqual(resp.Columns, want) {
+		t.Errorf("got columns %v, want %v", resp.Columns, want)
+	}
+}
+
+func TestJoinNullKeys(t *testing.T) {
+	e := newExecutor(t,
+		"CREATE TABLE a (k INT, x TEXT)",
+		"CREATE TABLE b (k FLOAT, y TEXT)",
+		"INSERT INTO a VALUES (1, 'a1'), (NULL, 'a2'), (2, 'a3')",
+		"INSERT INTO b VALUES (1.0, 'b1'), (NULL, 'b2'), (2.5, 'b3')",
+	)
+	// NULL keys equal nothing, not even each other; 1 and 1.0 are equal
+	want := [][]interface{}{{"a1", "b1"}, {"a2", nil}, {"a3", nil}}
+	if got := values(query(t, e, "SELECT x, y FROM a LEFT JOIN b ON a.k = b.k")); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestQualifiedColumns(t *testing.T) {
+	e := newExecutor(t, shop...)
+	want := [][]interface{}{{"cid", int64(40)}}
+	if got := values(query(t, e, "SELECT people.name, PEOPLE.age FROM people WHERE people.age > 30 ORDER BY people.name")); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	for q, message := range map[string]string{
+		"SELECT other.name FROM people":                                            `unknown column "other.name"`,
+		"SELECT name FROM people WHERE other.age = 10":                             `unknown column "other.age"`,
+		"SELECT name FROM people ORDER BY other.age":                               `unknown column "other.age" in ORDER BY`,
+		"UPDATE people SET age = 1 WHERE orders.qty = 1":                           `unknown column "orders.qty"`,
+		"SELECT name FROM people JOIN orders ON name = buyer WHERE nobody.qty = 1": `unknown column "nobody.qty"`,
+	} {
+		queryFails(t, e, q, message)
+	}
+}
@@ -0,0 +1,84 @@
+package executor
+
+import (
+	"fmt"
+	"sort"
+	"weird/db/engine/ast"
+	"weird/db/engine/client"
+)
+
+// sortRows orders the rows of a response by the ORDER BY items. The sort is
+// stable, so rows that compare equal keep the order the backend returned.
+// Values are compared with compareValues.
+func sortRows(resp *client.Response, orderBy []ast.OrderByItem) (*client.Response, error) {
+	if len(orderBy) == 0 {
+		return resp, nil
+	}
+
+	indexes := make([]int, len(orderBy))
+	for i, item := range orderBy {
+		idx := tableColumnIndex(resp.Columns, resp.Table, item.Column)
+		if idx < 0 {
+			return nil, fmt.Errorf("unknown column %q in ORDER BY", item.Column)
+		}
+		indexes[i] = idx
+	}
+
+	rows := make([]client.Row, len(resp.Rows))
+	copy(rows, resp.Rows)
+
+	sort.SliceStable(rows, func(a, b int) bool {
+		for i, item := range orderBy {
+			cmp := compareValues(cell(rows[a], indexes[i]), cell(rows[b], indexes[i]))
+			if cmp == 0 {
+				continue
+			}
+			if item.Descending {
+				return cmp > 0
+			}
+			return cmp < 0
+		}
+		return false
+	})
+
+	sorted := *resp
+	sorted.Rows = rows
+	return &sorted, nil
+}
+
+// paginate applies OFFSET and LIMIT to the rows of a response
+func paginate(resp *client.Response, limit *int, offset *int) *client.Response {
+	if limit == nil && offset == nil {
+		return resp
+	}
+
+	rows := resp.Rows
+	if offset != nil {
+		if *offset >= len(rows) {
+			rows = nil
+		} else {
+			rows = rows[*offset:]
+		}
+	}
+	if limit != nil && *limit < len(rows) {
+		rows = rows[:*limit]
+	}
+
+	paged := *resp
+	paged.Rows = rows
+	return &paged
+}
+
+// cell returns the value of a row at a column index, or nil (NULL) when
+// the index is -1 or the row is shorter than the schema
+func cell(row client.Row, idx int) interface{} {
+	if idx >= 0 && idx < len(row.Data) {
+		return row.Data[idx]
+	}
+	return nil
+}
+
+// isNull reports whether a cell holds NULL
+func isNull(value interface{}) bool {
+	return value == nil
+}
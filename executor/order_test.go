@@ -0,0 +1,48 @@
+package executor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOrderBy(t *testing.T) {
+	e := newExecutor(t, people...)
+	tests := []struct {
+		query string
+		want  []interface{}
+	}{
+		{"SELECT name FROM people ORDER BY age", []interface{}{"dan", "bob", "ann", "cid"}},
+		{"SELECT name FROM people ORDER BY age DESC", []interface{}{"cid", "ann", "bob", "dan"}},
+		{"SELECT name FROM people ORDER BY city DESC, name", []interface{}{"bob", "dan", "ann", "cid"}},
+		{"SELECT name FROM people ORDER BY city, age DESC", []interface{}{"cid", "ann", "bob", "dan"}},
+		// Rows that compare equal keep the order they were stored in
+		{"SELECT name FROM people ORDER BY city", []interface{}{"ann", "cid", "bob", "dan"}},
+		{"SELECT name FROM people ORDER BY AGE LIMIT 2", []interface{}{"dan", "bob"}},
+		{"SELECT name FROM people ORDER BY age LIMIT 2 OFFSET 1", []interface{}{"bob", "ann"}},
+		{"SELECT name FROM people ORDER BY age OFFSET 3", []interface{}{"cid"}},
+		{"SELECT name FROM people ORDER BY age OFFSET 4", nil},
+		{"SELECT name FROM people ORDER BY age LIMIT 0", nil},
+		{"SELECT name FROM people LIMIT 10", []interface{}{"ann", "bob", "cid", "dan"}},
+		{"SELECT name FROM people WHERE city = 'oslo' ORDER BY people.age DESC LIMIT 1", []interface{}{"cid"}},
+	}
+	for _, test := range tests {
+		if got := firstColumn(t, e, test.query); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.query, got, test.want)
+		}
+	}
+
+	queryFails(t, e, "SELECT name FROM people ORDER BY height", `unknown column "height" in ORDER BY`)
+}
+
+func TestOrderByMixedValues(t *testing.T) {
+	e := newExecutor(t,
+		"CREATE TABLE v (x)",
+		"INSERT INTO v VALUES (10), ('9'), (2.5), (NULL), ('abc'), (-1)",
+	)
+	// Numbers compare as numbers, and by their text against strings; NULL
+	// sorts as the empty string
+	want := []interface{}{nil, int64(-1), 2.5, int64(10), "9", "abc"}
+	if got := firstColumn(t, e, "SELECT x FROM v ORDER BY x"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,114 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+	"weird/db/engine/client"
+)
+
+// project narrows a response down to the requested fields, in the order they
+// were written. "*" expands to every column of the table. Types are
+// narrowed along with the columns.
+func project(resp *client.Response, fields []string) (*client.Response, error) {
+	indexes := make([]int, 0, len(resp.Columns))
+	columns := make([]string, 0, len(resp.Columns))
+
+	for _, field := range fields {
+		if field == "*" {
+			for i, col := range resp.Columns {
+				indexes = append(indexes, i)
+				columns = append(columns, col)
+			}
+			continue
+		}
+
+		idx := tableColumnIndex(resp.Columns, resp.Table, field)
+		if idx < 0 {
+			return nil, fmt.Errorf("unknown column %q in table %s", field, resp.Table)
+		}
+		indexes = append(indexes, idx)
+		columns = append(columns, resp.Columns[idx])
+	}
+
+	var types []string
+	if typed(resp) {
+		types = make([]string, len(indexes))
+		for i, idx := range indexes {
+			types[i] = resp.Types[idx]
+		}
+	}
+
+	rows := make([]client.Row, len(resp.Rows))
+	for i, row := range resp.Rows {
+		data := make([]interface{}, len(indexes))
+		for j, idx := range indexes {
+			data[j] = cell(row, idx)
+		}
+		rows[i] = client.Row{ID: row.ID, Data: data}
+	}
+
+	projected := *resp
+	projected.Columns = columns
+	projected.Types = types
+	projected.Rows = rows
+	return &projected, nil
+}
+
+// typed reports whether a response has a type for each of its columns
+func typed(resp *client.Response) bool {
+	return resp.Types != nil && len(resp.Types) == len(resp.Columns)
+}
+
+// columnIndex finds a column by name, preferring an exact match and falling
+// back to a case-insensitive one. An unqualified name also finds a
+// qualified column (as produced by joins, "users.name") when exactly one
+// table has it. It returns -1 when the column is unknown or ambiguous.
+func columnIndex(columns []string, name string) int {
+	for i, col := range columns {
+		if col == name {
+			return i
+		}
+	}
+	for i, col := range columns {
+		if strings.EqualFold(col, name) {
+			return i
+		}
+	}
+
+	if strings.Contains(name, ".") {
+		return -1
+	}
+
+	// name against qualified columns
+	found := -1
+	for i, col := range columns {
+		if dot := strings.LastIndex(col, "."); dot >= 0 && strings.EqualFold(col[dot+1:], name) {
+			if found >= 0 {
+				return -1
+			}
+			found = i
+		}
+	}
+	return found
+}
+
+// tableColumnIndex is columnIndex for a name written in a query against the
+// columns of a result from table. A name qualified with that table finds
+// its unqualified column, as in "SELECT users.name FROM users"; qualified
+// with any other table, it finds nothing.
+func tableColumnIndex(columns []string, table, name string) int {
+	if idx := columnIndex(columns, name); idx >= 0 {
+		return idx
+	}
+
+	dot := strings.LastIndex(name, ".")
+	if dot < 0 || !strings.EqualFold(name[:dot], table) {
+		return -1
+	}
+	for i, col := range columns {
+		if !strings.Contains(col, ".") && strings.EqualFold(col, name[dot+1:]) {
+			return i
+		}
+	}
+	return -1
+}
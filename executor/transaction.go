@@ -0,0 +1,63 @@
+package executor
+
+import (
+	"fmt"
+	"weird/db/engine/ast"
+	"weird/db/engine/client"
+)
+
+// executeBegin starts a transaction; the statements up to COMMIT or
+// ROLLBACK run in it
+func (e *Executor) executeBegin(stmt *ast.BEGINStatement) (*client.Response, error) {
+	if e.tx != nil {
+		return nil, fmt.Errorf("transaction already in progress")
+	}
+	tx, err := e.base.Begin()
+	if err != nil {
+		return nil, err
+	}
+	e.tx = tx
+	e.client = tx
+	return &client.Response{Status: "success", Message: "Transaction started"}, nil
+}
+
+func (e *Executor) executeCommit(stmt *ast.COMMITStatement) (*client.Response, error) {
+	tx, err := e.endTx()
+	if err != nil {
+		return nil, err
+	}
+	return tx.Commit()
+}
+
+func (e *Executor) executeRollback(stmt *ast.ROLLBACKStatement) (*client.Response, error) {
+	tx, err := e.endTx()
+	if err != nil {
+		return nil, err
+	}
+	return tx.Rollback()
+}
+
+// endTx detaches the open transaction, so later statements run outside it
+// whether or not it ends cleanly
+func (e *Executor) endTx() (client.Tx, error) {
+	if e.tx == nil {
+		return nil, fmt.Errorf("no transaction in progress")
+	}
+	tx := e.tx
+	e.tx = nil
+	e.client = e.base
+	return tx, nil
+}
+
+// abort rolls back the open transaction after one of its statements failed,
+// so none of its changes are applied, and adds the outcome to err
+func (e *Executor) abort(err error) error {
+	if e.tx == nil {
+		return err
+	}
+	tx, _ := e.endTx()
+	if _, rerr := tx.Rollback(); rerr != nil {
+		return fmt.Errorf("%w (rollback failed: %v)", err, rerr)
+	}
+	return fmt.Errorf("%w (transaction rolled back)", err)
+}
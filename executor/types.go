@@ -0,0 +1,146 @@
+package executor
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"weird/db/engine/ast"
+	"weird/db/engine/client"
+)
+
+// timestampLayouts are the accepted spellings of a TIMESTAMP value. Values
+// are stored in the first (RFC 3339) form.
+var timestampLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+const dateLayout = "2006-01-02"
+
+// columnTypes maps each column of a schema response to its declared type.
+// Columns of tables created without types are untyped.
+func columnTypes(schema *client.Response) map[string]ast.ColumnType {
+	types := make(map[string]ast.ColumnType, len(schema.Columns))
+	for i, col := range schema.Columns {
+		if i < len(schema.Types) {
+			types[col] = ast.ColumnType(schema.Types[i])
+		} else {
+			types[col] = ast.UntypedColumn
+		}
+	}
+	return types
+}
+
+// coerceRow converts every value of a row to the type of its column
+func coerceRow(row map[string]interface{}, types map[string]ast.ColumnType) error {
+	for col, value := range row {
+		typ, ok := types[col]
+		if !ok {
+			continue
+		}
+		coerced, err := coerceValue(value, typ)
+		if err != nil {
+			return fmt.Errorf("column %q: %w", col, err)
+		}
+		row[col] = coerced
+	}
+	return nil
+}
+
+// coerceValue converts a value to a column type, rejecting values that
+// cannot represent it. Strings are parsed where the conversion is
+// unambiguous ('42' into an INT column); NULL and untyped columns pass
+// through unchanged. NaN and the infinities are refused in any column:
+// neither the JSON of the protocol nor a log can hold them.
+func coerceValue(value interface{}, typ ast.ColumnType) (interface{}, error) {
+	if f, ok := value.(float64); ok && !finite(f) {
+		return nil, fmt.Errorf("%v is not a finite number", f)
+	}
+	if value == nil || typ == ast.UntypedColumn {
+		return value, nil
+	}
+
+	switch typ {
+	case ast.IntColumn:
+		switch v := value.(type) {
+		case int64:
+			return v, nil
+		case float64:
+			if v != math.Trunc(v) {
+				return nil, fmt.Errorf("%v is not an integer", v)
+			}
+			return int64(v), nil
+		case string:
+			if n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64); err == nil {
+				return n, nil
+			}
+		}
+
+	case ast.FloatColumn:
+		switch v := value.(type) {
+		case int64:
+			return float64(v), nil
+		case float64:
+			return v, nil
+		case string:
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil && finite(f) {
+				return f, nil
+			}
+		}
+
+	case ast.BoolColumn:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case int64:
+			if v == 0 || v == 1 {
+				return v == 1, nil
+			}
+		case string:
+			if b, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+				return b, nil
+			}
+		}
+
+	case ast.TextColumn:
+		return valueString(value), nil
+
+	case ast.DateColumn:
+		if v, ok := value.(string); ok {
+			if t, err := time.Parse(dateLayout, strings.TrimSpace(v)); err == nil {
+				return t.Format(dateLayout), nil
+			}
+		}
+
+	case ast.TimestampColumn:
+		if v, ok := value.(string); ok {
+			for _, layout := range timestampLayouts {
+				if t, err := time.Parse(layout, strings.TrimSpace(v)); err == nil {
+					return t.Format(time.RFC3339), nil
+				}
+			}
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown column type %s", typ)
+	}
+
+	return nil, fmt.Errorf("cannot store %s in a column of type %s", describeValue(value), typ)
+}
+
+func finite(f float64) bool {
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
+}
+
+// describeValue formats a value for an error message, quoting strings
+func describeValue(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return strconv.Quote(s)
+	}
+	return valueString(value)
+}
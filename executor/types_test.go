@@ -0,0 +1,95 @@
+package executor
+
+import (
+	"reflect"
+	"testing"
+	"weird/db/engine/ast"
+)
+
+func TestCoerceValue(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		typ   ast.ColumnType
+		want  interface{}
+		ok    bool
+	}{
+		{int64(42), ast.IntColumn, int64(42), true},
+		{" 42 ", ast.IntColumn, int64(42), true},
+		{3.0, ast.IntColumn, int64(3), true},
+		{3.5, ast.IntColumn, nil, false},
+		{"4x", ast.IntColumn, nil, false},
+		{true, ast.IntColumn, nil, false},
+		{int64(2), ast.FloatColumn, 2.0, true},
+		{"2.5", ast.FloatColumn, 2.5, true},
+		{"NaN", ast.FloatColumn, nil, false},
+		{"yes", ast.FloatColumn, nil, false},
+		{int64(1), ast.BoolColumn, true, true},
+		{"false", ast.BoolColumn, false, true},
+		{int64(2), ast.BoolColumn, nil, false},
+		{2.5, ast.TextColumn, "2.5", true},
+		{true, ast.TextColumn, "true", true},
+		{"2024-02-29", ast.DateColumn, "2024-02-29", true},
+		{"2023-02-29", ast.DateColumn, nil, false},
+		{"2024-01-02 03:04", ast.TimestampColumn, "2024-01-02T03:04:00Z", true},
+		{"tomorrow", ast.TimestampColumn, nil, false},
+		{nil, ast.IntColumn, nil, true},
+		{"anything", ast.UntypedColumn, "anything", true},
+	}
+	for _, test := range tests {
+		got, err := coerceValue(test.value, test.typ)
+		if (err == nil) != test.ok || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%#v into %s: got %#v, %v", test.value, test.typ, got, err)
+		}
+	}
+}
+
+func TestTypedRows(t *testing.T) {
+	e := newExecutor(t,
+		"CREATE TABLE m (n INT, f FLOAT, s TEXT, b BOOL, d DATE, u)",
+		"INSERT INTO m VALUES ('7', 2, 3.5, 'true', '2024-01-02', 1), (-1, '0.5', 'x', 0, NULL, 'y')",
+	)
+	want := [][]interface{}{
+		{int64(7), 2.0, "3.5", true, "2024-01-02", int64(1)},
+		{int64(-1), 0.5, "x", false, nil, "y"},
+	}
+	if got := values(query(t, e, "SELECT * FROM m")); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+
+	query(t, e, "UPDATE m SET f = '1.25' WHERE n = 7")
+	if got := firstColumn(t, e, "SELECT f FROM m WHERE n = 7"); !reflect.DeepEqual(got, []interface{}{1.25}) {
+		t.Errorf("after UPDATE: got %#v", got)
+	}
+
+	queryFails(t, e, "INSERT INTO m (n) VALUES (1.5)", `column "n": 1.5 is not an integer`)
+	queryFails(t, e, "INSERT INTO m (d) VALUES ('soon')", `cannot store "soon" in a column of type DATE`)
+	queryFails(t, e, "UPDATE m SET b = 'maybe'", `column "b": cannot store "maybe" in a column of type BOOL`)
+}
+
+func TestResultTypes(t *testing.T) {
+	e := newExecutor(t,
+		"CREATE TABLE t (id INT, name TEXT, score FLOAT, u)",
+		"CREATE TABLE o (id INT, t_id INT)",
+		"INSERT INTO t VALUES (1, 'a', 1.5, 2)",
+		"INSERT INTO o VALUES (1, 1)",
+	)
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"SELECT * FROM t", []string{"INT", "TEXT", "FLOAT", ""}},
+		{"SELECT id FROM t", []string{"INT"}},
+		{"SELECT score, name FROM t", []string{"FLOAT", "TEXT"}},
+		{"SELECT name, COUNT(*), SUM(id), SUM(score), SUM(u), AVG(id), MIN(name), MAX(score) FROM t GROUP BY name",
+			[]string{"TEXT", "INT", "INT", "FLOAT", "", "FLOAT", "TEXT", "FLOAT"}},
+		{"SELECT COUNT(*) FROM t", []string{"INT"}},
+		{"SELECT t.name, o.id FROM t JOIN o ON t.id = o.t_id", []string{"TEXT", "INT"}},
+		{"SELECT * FROM t JOIN o ON t.id = o.t_id", []string{"INT", "TEXT", "FLOAT", "", "INT", "INT"}},
+	}
+	for _, test := range tests {
+		resp := query(t, e, test.query)
+		if !reflect.DeepEqual(resp.Types, test.want) || len(resp.Types) != len(resp.Columns) {
+			t.Errorf("%s: got types %q for columns %q, want %q", test.query, resp.Types, resp.Columns, test.want)
+		}
+	}
+}
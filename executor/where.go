@@ -0,0 +1,368 @@
+package executor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"weird/db/engine/ast"
+	"weird/db/engine/client"
+	"weird/db/engine/token"
+)
+
+// fetchRows selects the rows of a table matching a predicate. The part of
+// the predicate the backend understands (a conjunction of column/value
+// comparisons) is pushed down to it; anything else is evaluated here on the
+// returned candidate rows.
+func (e *Executor) fetchRows(table string, predicate ast.Expression) (*client.Response, error) {
+	where, exact := pushdownWhere(predicate, table)
+
+	var resp *client.Response
+	var err error
+	if where != nil {
+		resp, err = e.client.Select(table, where)
+	} else {
+		resp, err = e.client.SelectAll(table)
+	}
+	if err != nil {
+		return resp, err
+	}
+	if exact {
+		// The backend matches nothing on a column it doesn't have
+		return resp, checkColumns(predicate, table, resp.Columns)
+	}
+
+	return filterRows(resp, predicate)
+}
+
+// checkColumns reports the first column an expression refers to that is
+// not among columns. Rows are only evaluated one by one, so without it a
+// misspelt column goes unnoticed when no row is left to evaluate.
+func checkColumns(expr ast.Expression, table string, columns []string) error {
+	for _, name := range referencedColumns(expr) {
+		if tableColumnIndex(columns, table, name) < 0 {
+			return fmt.Errorf("unknown column %q", name)
+		}
+	}
+	return nil
+}
+
+// rowIDs returns the IDs of all rows in a response
+func rowIDs(resp *client.Response) []int {
+	ids := make([]int, len(resp.Rows))
+	for i, row := range resp.Rows {
+		ids[i] = row.ID
+	}
+	return ids
+}
+
+// pushdownWhere extracts the column/value comparisons of a predicate that
+// can be sent to the backend as a WHERE map. exact reports whether the map
+// expresses the whole predicate, in which case no client-side filtering is
+// needed. A nil predicate is trivially exact. Columns qualified with the
+// table name ("users.age") are sent unqualified.
+func pushdownWhere(predicate ast.Expression, table string) (where map[string]interface{}, exact bool) {
+	if predicate == nil {
+		return nil, true
+	}
+
+	all := make(map[string]interface{})
+	exact = collectConditions(predicate, all)
+
+	where = tablePushdown(predicate, table)
+	if where == nil {
+		where = make(map[string]interface{})
+	}
+	for name, condition := range all {
+		if !strings.Contains(name, ".") {
+			if existing, seen := where[name]; seen && existing != condition {
+				// The column is also named qualified with another
+				// condition; the map only holds one of them
+				exact = false
+				continue
+			}
+			where[name] = condition
+		} else if len(name) <= len(table) || !strings.EqualFold(name[:len(table)+1], table+".") {
+			// Qualified with another table; only client-side filtering
+			// can report it as an unknown column
+			exact = false
+		}
+	}
+
+	if len(where) == 0 {
+		return nil, false
+	}
+	return where, exact
+}
+
+// collectConditions walks the top-level AND chain of a predicate, adding
+// every column/literal comparison to where. Equality is sent as a plain
+// value, other operators as a client.Condition. It returns false if any
+// part of the predicate could not be represented. The backends keep a row
+// only when every condition holds, and a NULL cell satisfies none, which
+// for an AND chain is the rule evaluate() and matches() apply.
+func collectConditions(expr ast.Expression, where map[string]interface{}) bool {
+	b, ok := expr.(*ast.BinaryExpression)
+	if !ok {
+		return false
+	}
+
+	switch b.Operator {
+	case token.AND_TOKEN:
+		left := collectConditions(b.Left, where)
+		right := collectConditions(b.Right, where)
+		return left && right
+	case token.EQUALS_TOKEN, token.NOT_EQUALS_TOKEN,
+		token.LT_TOKEN, token.GT_TOKEN, token.LTE_TOKEN, token.GTE_TOKEN:
+		column, literal, operator, ok := columnAndLiteral(b)
+		if !ok {
+			return false
+		}
+
+		if literal.Kind == ast.NullLiteral {
+			// Comparisons with NULL are unknown; leave them to evaluate()
+			return false
+		}
+
+		var condition interface{} = literal.Value
+		if operator != token.EQUALS_TOKEN {
+			condition = client.Condition{Op: string(operator), Value: condition}
+		}
+
+		if existing, seen := where[column.Name]; seen {
+			// The map holds one condition per column; a second condition on
+			// the same column is left for client-side filtering
+			return existing == condition
+		}
+		where[column.Name] = condition
+		return true
+	default:
+		return false
+	}
+}
+
+// columnAndLiteral matches "column op literal" in either operand order. The
+// returned operator is flipped when the literal comes first, so that it
+// always reads as "column op literal".
+func columnAndLiteral(b *ast.BinaryExpression) (*ast.Identifier, *ast.Literal, token.TokenType, bool) {
+	if ident, ok := b.Left.(*ast.Identifier); ok {
+		if lit, ok := b.Right.(*ast.Literal); ok {
+			return ident, lit, b.Operator, true
+		}
+	}
+	if ident, ok := b.Right.(*ast.Identifier); ok {
+		if lit, ok := b.Left.(*ast.Literal); ok {
+			return ident, lit, flipComparison(b.Operator), true
+		}
+	}
+	return nil, nil, "", false
+}
+
+// flipComparison returns the operator that gives the same result with its
+// operands swapped
+func flipComparison(op token.TokenType) token.TokenType {
+	switch op {
+	case token.LT_TOKEN:
+		return token.GT_TOKEN
+	case token.GT_TOKEN:
+		return token.LT_TOKEN
+	case token.LTE_TOKEN:
+		return token.GTE_TOKEN
+	case token.GTE_TOKEN:
+		return token.LTE_TOKEN
+	default:
+		return op
+	}
+}
+
+// filterRows keeps only the rows of a response for which predicate holds
+func filterRows(resp *client.Response, predicate ast.Expression) (*client.Response, error) {
+	if err := checkColumns(predicate, resp.Table, resp.Columns); err != nil {
+		return nil, err
+	}
+
+	rows := make([]client.Row, 0, len(resp.Rows))
+	for _, row := range resp.Rows {
+		ok, err := matches(predicate, resp.Table, resp.Columns, row)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			rows = append(rows, row)
+		}
+	}
+
+	filtered := *resp
+	filtered.Rows = rows
+	return &filtered, nil
+}
+
+// matches reports whether a predicate holds for a row of a result from
+// table
+func matches(predicate ast.Expression, table string, columns []string, row client.Row) (bool, error) {
+	value, err := evaluate(predicate, table, columns, row)
+	if err != nil {
+		return false, err
+	}
+	return truthy(value)
+}
+
+// evaluate computes the value of an expression against a single row
+func evaluate(expr ast.Expression, table string, columns []string, row client.Row) (interface{}, error) {
+	switch ex := expr.(type) {
+	case *ast.Identifier:
+		idx := tableColumnIndex(columns, table, ex.Name)
+		if idx < 0 {
+			return nil, fmt.Errorf("unknown column %q", ex.Name)
+		}
+		return cell(row, idx), nil
+
+	case *ast.AggregateExpression:
+		// Aggregates are only available on rows produced by aggregate()
+		idx := columnIndex(columns, ex.String())
+		if idx < 0 {
+			return nil, fmt.Errorf("aggregate %s is not allowed here", ex.String())
+		}
+		return cell(row, idx), nil
+
+	case *ast.Literal:
+		return ex.Value, nil
+
+	case *ast.UnaryExpression:
+		operand, err := evaluate(ex.Operand, table, columns, row)
+		if err != nil {
+			return nil, err
+		}
+		if ex.Operator != token.NOT_TOKEN {
+			return nil, fmt.Errorf("unsupported unary operator: %s", ex.Operator)
+		}
+		b, err := logical(operand)
+		if err != nil || b == nil {
+			return nil, err
+		}
+		return !b.(bool), nil
+
+	case *ast.BinaryExpression:
+		return evaluateBinary(ex, table, columns, row)
+
+	default:
+		return nil, fmt.Errorf("unsupported expression: %T", expr)
+	}
+}
+
+// evaluateBinary evaluates AND/OR with short-circuiting and comparisons on
+// both operands. As in SQL, logic has three values: a comparison with NULL
+// is unknown (nil), which AND, OR and NOT carry on unless the other operand
+// decides the result, as false does for AND and true for OR.
+func evaluateBinary(ex *ast.BinaryExpression, table string, columns []string, row client.Row) (interface{}, error) {
+	left, err := evaluate(ex.Left, table, columns, row)
+	if err != nil {
+		return nil, err
+	}
+
+	switch ex.Operator {
+	case token.AND_TOKEN, token.OR_TOKEN:
+		// The value that decides the result whatever the other operand is
+		decisive := ex.Operator == token.OR_TOKEN
+
+		l, err := logical(left)
+		if err != nil {
+			return nil, err
+		}
+		if l == decisive {
+			return decisive, nil
+		}
+
+		right, err := evaluate(ex.Right, table, columns, row)
+		if err != nil {
+			return nil, err
+		}
+		r, err := logical(right)
+		if err != nil {
+			return nil, err
+		}
+		if r == decisive {
+			return decisive, nil
+		}
+		if l == nil || r == nil {
+			return nil, nil
+		}
+		return !decisive, nil
+	}
+
+	right, err := evaluate(ex.Right, table, columns, row)
+	if err != nil {
+		return nil, err
+	}
+
+	// A comparison involving NULL is unknown
+	if left == nil || right == nil {
+		return nil, nil
+	}
+
+	cmp := compareValues(left, right)
+	switch ex.Operator {
+	case token.EQUALS_TOKEN:
+		return cmp == 0, nil
+	case token.NOT_EQUALS_TOKEN:
+		return cmp != 0, nil
+	case token.LT_TOKEN:
+		return cmp < 0, nil
+	case token.GT_TOKEN:
+		return cmp > 0, nil
+	case token.LTE_TOKEN:
+		return cmp <= 0, nil
+	case token.GTE_TOKEN:
+		return cmp >= 0, nil
+	default:
+		return nil, fmt.Errorf("unsupported operator: %s", ex.Operator)
+	}
+}
+
+// compareValues orders two values, returning -1, 0 or 1. Numbers compare
+// numerically, so an INT column compares with a FLOAT literal, and TEXT
+// compares as text; see client.CompareValues.
+func compareValues(a, b interface{}) int {
+	return client.CompareValues(a, b)
+}
+
+// valueString formats a cell value as text, printing floats without an
+// exponent or trailing zeros
+func valueString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// keyString formats a value for use in a hash key: values compareValues
+// finds equal share a key, and NULL's differs from every other
+func keyString(value interface{}) string {
+	return client.ValueKey(value)
+}
+
+// truthy converts the result of a predicate to a bool. Unknown is false,
+// so a row is only accepted when the predicate is true.
+func truthy(value interface{}) (bool, error) {
+	b, err := logical(value)
+	if err != nil || b == nil {
+		return false, err
+	}
+	return b.(bool), nil
+}
+
+// logical checks that an operand of AND, OR or NOT is true, false or
+// unknown (nil)
+func logical(value interface{}) (interface{}, error) {
+	switch value.(type) {
+	case bool, nil:
+		return value, nil
+	default:
+		return nil, fmt.Errorf("expression does not evaluate to a boolean: %v", value)
+	}
+}
@@ -0,0 +1,181 @@
+package executor
+
+import (
+	"reflect"
+	"testing"
+	"weird/db/engine/client"
+	"weird/db/engine/parser"
+)
+
+// people is a table for WHERE tests; dan has no age
+var people = []string{
+	"CREATE TABLE people (name TEXT, age INT, city TEXT)",
+	"INSERT INTO people VALUES ('ann', 30, 'oslo'), ('bob', 20, 'rome'), ('cid', 40, 'oslo'), ('dan', NULL, 'rome')",
+}
+
+// firstColumn runs q and returns the first column of its rows
+func firstColumn(t *testing.T, e *Executor, q string) []interface{} {
+	t.Helper()
+	var got []interface{}
+	for _, row := range query(t, e, q).Rows {
+		got = append(got, row.Data[0])
+	}
+	return got
+}
+
+func TestWhere(t *testing.T) {
+	e := newExecutor(t, people...)
+	tests := []struct {
+		where string
+		want  []interface{}
+	}{
+		{"city = 'oslo'", []interface{}{"ann", "cid"}},
+		{"city = 'oslo' AND age > 30", []interface{}{"cid"}},
+		{"city = 'rome' OR age >= 40", []interface{}{"bob", "cid", "dan"}},
+		{"age = 20 OR age = 40 AND city = 'rome'", []interface{}{"bob"}},
+		{"(age = 20 OR age = 40) AND city = 'oslo'", []interface{}{"cid"}},
+		{"NOT city = 'oslo'", []interface{}{"bob", "dan"}},
+		{"NOT (city = 'oslo' OR name = 'bob')", []interface{}{"dan"}},
+		{"30 < age", []interface{}{"cid"}},
+		{"people.age <= 20", []interface{}{"bob"}},
+		{"name = city", nil},
+		{"age = 'abc'", nil},
+	}
+	for _, test := range tests {
+		if got := firstColumn(t, e, "SELECT name FROM people WHERE "+test.where); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("WHERE %s: got %v, want %v", test.where, got, test.want)
+		}
+	}
+}
+
+func TestWhereUnknownColumn(t *testing.T) {
+	e := newExecutor(t, people...)
+	for _, where := range []string{
+		"agee = 30",
+		"name = agee",
+		"city = 'oslo' OR name = ann",
+	} {
+		queryFails(t, e, "SELECT name FROM people WHERE "+where, "unknown column")
+		queryFails(t, e, "UPDATE people SET age = 1 WHERE "+where, "unknown column")
+		queryFails(t, e, "DELETE FROM people WHERE "+where, "unknown column")
+	}
+
+	// Even when no row is left to evaluate the condition on
+	query(t, e, "TRUNCATE TABLE people")
+	queryFails(t, e, "SELECT name FROM people WHERE age = 1 OR agee = 2", "unknown column")
+}
+
+func TestUpdateDeleteWhere(t *testing.T) {
+	e := newExecutor(t, people...)
+	query(t, e, "UPDATE people SET city = paris WHERE name = 'ann' OR age = 20")
+	if got := firstColumn(t, e, "SELECT name FROM people WHERE city = 'paris'"); !reflect.DeepEqual(got, []interface{}{"ann", "bob"}) {
+		t.Errorf("after UPDATE: got %v in paris", got)
+	}
+
+	query(t, e, "DELETE FROM people WHERE NOT (city = 'paris')")
+	if got := firstColumn(t, e, "SELECT name FROM people"); !reflect.DeepEqual(got, []interface{}{"ann", "bob"}) {
+		t.Errorf("after DELETE: got %v", got)
+	}
+}
+
+func TestWhereNull(t *testing.T) {
+	e := newExecutor(t, people...)
+	tests := []struct {
+		where string
+		want  []interface{}
+	}{
+		{"age > 20", []interface{}{"ann", "cid"}},
+		{"NOT (age > 20)", []interface{}{"bob"}},
+		{"NOT (NOT (age > 20))", []interface{}{"ann", "cid"}},
+		{"NOT (age = 30 AND city = 'rome')", []interface{}{"ann", "bob", "cid"}},
+		{"age > 20 OR city = 'rome'", []interface{}{"ann", "bob", "cid", "dan"}},
+		{"age > 20 OR city = 'oslo'", []interface{}{"ann", "cid"}},
+		{"NOT (age > 20 OR city = 'oslo')", []interface{}{"bob"}},
+		{"age = NULL", nil},
+		{"NOT (age = NULL)", nil},
+		{"age != NULL OR name = 'dan'", []interface{}{"dan"}},
+	}
+	for _, test := range tests {
+		if got := firstColumn(t, e, "SELECT name FROM people WHERE "+test.where); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("WHERE %s: got %v, want %v", test.where, got, test.want)
+		}
+	}
+
+	// Rows filtered here and rows filtered by the backend agree
+	query(t, e, "DELETE FROM people WHERE NOT (age > 20)")
+	query(t, e, "DELETE FROM people WHERE age != 30")
+	if got := firstColumn(t, e, "SELECT name FROM people"); !reflect.DeepEqual(got, []interface{}{"ann", "dan"}) {
+		t.Errorf("after DELETE: got %v", got)
+	}
+}
+
+func TestCheckUnknown(t *testing.T) {
+	e := newExecutor(t, "CREATE TABLE c (a INT, b INT, CHECK (NOT (a = 1) AND b > 0))")
+	query(t, e, "INSERT INTO c VALUES (2, 1), (NULL, 1), (2, NULL)")
+	queryFails(t, e, "INSERT INTO c VALUES (NULL, -1)", "CHECK constraint")
+	queryFails(t, e, "INSERT INTO c VALUES (1, NULL)", "CHECK constraint")
+}
+
+func TestComparisons(t *testing.T) {
+	e := newExecutor(t,
+		"CREATE TABLE m (n INT, f FLOAT, s TEXT, u)",
+		"INSERT INTO m VALUES (2, 2.5, '10', 10), (10, -1, '9', 'abc'), (-3, 10, 'b', 2.5)",
+	)
+	tests := []struct {
+		where string
+		want  []interface{}
+	}{
+		{"n > 2", []interface{}{int64(10)}},
+		{"n >= 2", []interface{}{int64(2), int64(10)}},
+		{"n < 2.5", []interface{}{int64(2), int64(-3)}},
+		{"n <= -3", []interface{}{int64(-3)}},
+		{"n != 10", []interface{}{int64(2), int64(-3)}},
+		{"n <> 10", []interface{}{int64(2), int64(-3)}},
+		{"f > n", []interface{}{int64(2), int64(-3)}},
+		{"f = 10", []interface{}{int64(-3)}},
+		// TEXT compares as text: '10' < '9' < 'b'
+		{"s < '9'", []interface{}{int64(2)}},
+		{"s > '10'", []interface{}{int64(10), int64(-3)}},
+		// Untyped values compare as what they hold, and by their text
+		// against a value of another kind
+		{"u > 3", []interface{}{int64(2), int64(10)}},
+		{"u < 3", []interface{}{int64(-3)}},
+		{"u = 'abc'", []interface{}{int64(10)}},
+		{"u = '10'", []interface{}{int64(2)}},
+	}
+	for _, test := range tests {
+		if got := firstColumn(t, e, "SELECT n FROM m WHERE "+test.where); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("WHERE %s: got %v, want %v", test.where, got, test.want)
+		}
+	}
+}
+
+func TestPushdownWhere(t *testing.T) {
+	tests := []struct {
+		where string
+		want  map[string]interface{}
+		exact bool
+	}{
+		{"a = 1", map[string]interface{}{"a": int64(1)}, true},
+		{"a > 1 AND b = 'x'", map[string]interface{}{"a": client.Condition{Op: ">", Value: int64(1)}, "b": "x"}, true},
+		{"1 < a", map[string]interface{}{"a": client.Condition{Op: ">", Value: int64(1)}}, true},
+		{"a <> 2", map[string]interface{}{"a": client.Condition{Op: "!=", Value: int64(2)}}, true},
+		{"t.a = 1", map[string]interface{}{"a": int64(1)}, true},
+		{"a = 1 AND (b = 2 OR c = 3)", map[string]interface{}{"a": int64(1)}, false},
+		{"a > 1 AND a < 5", map[string]interface{}{"a": client.Condition{Op: ">", Value: int64(1)}}, false},
+		{"a = 1 AND b = NULL", map[string]interface{}{"a": int64(1)}, false},
+		{"a = 1 AND other.b = 2", map[string]interface{}{"a": int64(1)}, false},
+		{"a = b", nil, false},
+		{"NOT a = 1", nil, false},
+	}
+	for _, test := range tests {
+		predicate, err := parser.ParseExpression(test.where)
+		if err != nil {
+			t.Fatal(err)
+		}
+		where, exact := pushdownWhere(predicate, "t")
+		if !reflect.DeepEqual(where, test.want) || exact != test.exact {
+			t.Errorf("%s: got %v, %v, want %v, %v", test.where, where, exact, test.want, test.exact)
+		}
+	}
+}
@@ -139,8 +139,8 @@ func (g *GUI) outputResponse(resps []*client.Response) fyne.CanvasObject {
 		// Add data rows
 		for _, row := range resp.Rows {
 			dataRow := container.NewHBox()
-			for _, cellData := range row.Data {
-				cellLabel := widget.NewLabel(cellData)
+			for i := range row.Data {
+				cellLabel := widget.NewLabel(row.Cell(i).String())
 				cellLabel.Resize(fyne.NewSize(150, 35))
 				dataRow.Add(cellLabel)
 			}
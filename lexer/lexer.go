@@ -61,6 +61,102 @@ func (l *Lexer) flushBuffer() {
 		tok.Token = token.SET_TOKEN
 	case "WHERE":
 		tok.Token = token.WHERE_TOKEN
+	case "CREATE":
+		tok.Token = token.CREATE_TOKEN
+	case "TABLE":
+		tok.Token = token.TABLE_TOKEN
+	case "DROP":
+		tok.Token = token.DROP_TOKEN
+	case "TRUNCATE":
+		tok.Token = token.TRUNCATE_TOKEN
+	case "IF":
+		tok.Token = token.IF_TOKEN
+	case "EXISTS":
+		tok.Token = token.EXISTS_TOKEN
+	case "AND":
+		tok.Token = token.AND_TOKEN
+	case "OR":
+		tok.Token = token.OR_TOKEN
+	case "NOT":
+		tok.Token = token.NOT_TOKEN
+	case "ORDER":
+		tok.Token = token.ORDER_TOKEN
+	case "BY":
+		tok.Token = token.BY_TOKEN
+	case "ASC":
+		tok.Token = token.ASC_TOKEN
+	case "DESC":
+		tok.Token = token.DESC_TOKEN
+	case "LIMIT":
+		tok.Token = token.LIMIT_TOKEN
+	case "OFFSET":
+		tok.Token = token.OFFSET_TOKEN
+	case "GROUP":
+		tok.Token = token.GROUP_TOKEN
+	case "HAVING":
+		tok.Token = token.HAVING_TOKEN
+	case "JOIN":
+		tok.Token = token.JOIN_TOKEN
+	case "INNER":
+		tok.Token = token.INNER_TOKEN
+	case "LEFT":
+		tok.Token = token.LEFT_TOKEN
+	case "OUTER":
+		tok.Token = token.OUTER_TOKEN
+	case "ON":
+		tok.Token = token.ON_TOKEN
+	case "TRUE":
+		tok.Token = token.TRUE_TOKEN
+	case "FALSE":
+		tok.Token = token.FALSE_TOKEN
+	case "NULL":
+		tok.Token = token.NULL_TOKEN
+	case "PRIMARY":
+		tok.Token = token.PRIMARY_TOKEN
+	case "KEY":
+		tok.Token = token.KEY_TOKEN
+	case "UNIQUE":
+		tok.Token = token.UNIQUE_TOKEN
+	case "DEFAULT":
+		tok.Token = token.DEFAULT_TOKEN
+	case "CHECK":
+		tok.Token = token.CHECK_TOKEN
+	case "CONSTRAINT":
+		tok.Token = token.CONSTRAINT_TOKEN
+	case "FOREIGN":
+		tok.Token = token.FOREIGN_TOKEN
+	case "REFERENCES":
+		tok.Token = token.REFERENCES_TOKEN
+	case "CASCADE":
+		tok.Token = token.CASCADE_TOKEN
+	case "RESTRICT":
+		tok.Token = token.RESTRICT_TOKEN
+	case "ALTER":
+		tok.Token = token.ALTER_TOKEN
+	case "ADD":
+		tok.Token = token.ADD_TOKEN
+	case "COLUMN":
+		tok.Token = token.COLUMN_TOKEN
+	case "RENAME":
+		tok.Token = token.RENAME_TOKEN
+	case "TO":
+		tok.Token = token.TO_TOKEN
+	case "SHOW":
+		tok.Token = token.SHOW_TOKEN
+	case "TABLES":
+		tok.Token = token.TABLES_TOKEN
+	case "DESCRIBE":
+		tok.Token = token.DESCRIBE_TOKEN
+	case "BEGIN":
+		tok.Token = token.BEGIN_TOKEN
+	case "COMMIT":
+		tok.Token = token.COMMIT_TOKEN
+	case "ROLLBACK":
+		tok.Token = token.ROLLBACK_TOKEN
+	case "TRANSACTION":
+		tok.Token = token.TRANSACTION_TOKEN
+	case "INDEX":
+		tok.Token = token.INDEX_TOKEN
 	default:
 		tok.Token = token.IDENT_TOKEN
 	}
@@ -75,13 +171,20 @@ func (l *Lexer) Tokenize(input string) []token.Token {
 	inString := false
 	var stringDelimiter rune
 
-	for _, char := range input {
+	runes := []rune(input)
+	for i := 0; i < len(runes); i++ {
+		char := runes[i]
+
 		if char == '\'' || char == '"' {
 			if !inString {
 				l.flushBuffer()
 				inString = true
 				stringDelimiter = char
 				l.ReadBuffer.WriteRune(char)
+			} else if char == stringDelimiter && peekRune(runes, i) == stringDelimiter {
+				// A doubled delimiter ('it''s') is an escaped quote
+				i++
+				l.ReadBuffer.WriteRune(char)
 			} else if char == stringDelimiter {
 				l.ReadBuffer.WriteRune(char)
 				tok := token.Token{
@@ -127,6 +230,53 @@ func (l *Lexer) Tokenize(input string) []token.Token {
 				Literal: "=",
 				Token:   token.EQUALS_TOKEN,
 			})
+		case '<':
+			l.flushBuffer()
+			switch peekRune(runes, i) {
+			case '=':
+				i++
+				l.tokens = append(l.tokens, token.Token{
+					Literal: "<=",
+					Token:   token.LTE_TOKEN,
+				})
+			case '>':
+				i++
+				l.tokens = append(l.tokens, token.Token{
+					Literal: "<>",
+					Token:   token.NOT_EQUALS_TOKEN,
+				})
+			default:
+				l.tokens = append(l.tokens, token.Token{
+					Literal: "<",
+					Token:   token.LT_TOKEN,
+				})
+			}
+		case '>':
+			l.flushBuffer()
+			if peekRune(runes, i) == '=' {
+				i++
+				l.tokens = append(l.tokens, token.Token{
+					Literal: ">=",
+					Token:   token.GTE_TOKEN,
+				})
+			} else {
+				l.tokens = append(l.tokens, token.Token{
+					Literal: ">",
+					Token:   token.GT_TOKEN,
+				})
+			}
+		case '!':
+			// A lone '!' is not an operator; keep it as part of the word
+			if peekRune(runes, i) != '=' {
+				l.ReadBuffer.WriteRune(char)
+				continue
+			}
+			l.flushBuffer()
+			i++
+			l.tokens = append(l.tokens, token.Token{
+				Literal: "!=",
+				Token:   token.NOT_EQUALS_TOKEN,
+			})
 		case ';':
 			l.flushBuffer()
 			l.tokens = append(l.tokens, token.Token{
@@ -155,6 +305,14 @@ func (l *Lexer) GetTokens() []token.Token {
 	return l.tokens
 }
 
+// peekRune returns the rune after position i, or 0 at the end of input
+func peekRune(runes []rune, i int) rune {
+	if i+1 < len(runes) {
+		return runes[i+1]
+	}
+	return 0
+}
+
 func isNumber(s string) bool {
 	if len(s) == 0 {
 		return false
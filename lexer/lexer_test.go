@@ -0,0 +1,70 @@
+package lexer
+
+import (
+	"reflect"
+	"testing"
+	"weird/db/engine/token"
+)
+
+// tokens returns the tokens of input as "TYPE literal" pairs
+func tokens(input string) []string {
+	var got []string
+	for _, tok := range New().Tokenize(input) {
+		got = append(got, string(tok.Token)+" "+tok.Literal)
+	}
+	return got
+}
+
+func TestTokenizeWhere(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"a = 1 AND b = 'x y'", []string{
+			"IDENT a", "= =", "NUMBER 1", "AND AND", "IDENT b", "= =", "STRING 'x y'",
+		}},
+		{"not (a=1 or b=2)", []string{
+			"NOT not", "( (", "IDENT a", "= =", "NUMBER 1", "OR or", "IDENT b", "= =", "NUMBER 2", ") )",
+		}},
+		{"(users.age=-2.5)", []string{"( (", "IDENT users.age", "= =", "NUMBER -2.5", ") )"}},
+		{"'it''s' AND\n\"a\"", []string{"STRING 'it's'", "AND AND", "ENDLINE \n", "STRING \"a\""}},
+		{"android ornate nothing", []string{"IDENT android", "IDENT ornate", "IDENT nothing"}},
+	}
+	for _, test := range tests {
+		if got := tokens(test.input); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: got %q, want %q", test.input, got, test.want)
+		}
+	}
+}
+
+func TestTokenizeKeywordCase(t *testing.T) {
+	for _, word := range []string{"and", "And", "AND"} {
+		if got := New().Tokenize(word); len(got) != 1 || got[0].Token != token.AND_TOKEN {
+			t.Errorf("%q: got %+v", word, got)
+		}
+	}
+}
+
+func TestTokenizeComparisons(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"a<1", []string{"IDENT a", "< <", "NUMBER 1"}},
+		{"a>1", []string{"IDENT a", "> >", "NUMBER 1"}},
+		{"a<=1", []string{"IDENT a", "<= <=", "NUMBER 1"}},
+		{"a>=1", []string{"IDENT a", ">= >=", "NUMBER 1"}},
+		{"a!=1", []string{"IDENT a", "!= !=", "NUMBER 1"}},
+		{"a<>1", []string{"IDENT a", "!= <>", "NUMBER 1"}},
+		{"a < = 1", []string{"IDENT a", "< <", "= =", "NUMBER 1"}},
+		{"a>='x'", []string{"IDENT a", ">= >=", "STRING 'x'"}},
+		{"'a<b' < b", []string{"STRING 'a<b'", "< <", "IDENT b"}},
+		{"wow! = 1", []string{"IDENT wow!", "= =", "NUMBER 1"}},
+		{"a >", []string{"IDENT a", "> >"}},
+	}
+	for _, test := range tests {
+		if got := tokens(test.input); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: got %q, want %q", test.input, got, test.want)
+		}
+	}
+}
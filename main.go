@@ -1,15 +1,37 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 	"weird/db/engine/gui"
+	"weird/db/engine/server"
+	"weird/db/engine/storage"
 	"weird/db/engine/stub"
 )
 
 const (
 	URL = "http://localhost:8080"
+
+	// shutdownTimeout bounds how long serve waits for open requests on exit
+	shutdownTimeout = 10 * time.Second
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		if err := serve(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	//c := client.NewClient(URL)
 	//e := executor.NewExecutor(c)
 	es := &stub.StubDbExecutor{}
@@ -19,3 +41,63 @@ func main() {
 	//	newCli := cli.NewCLI("http://localhost:8081")
 	//	newCli.Run()
 }
+
+// serve runs the Go database server in place of engine.pl:
+//
+//	engine serve [-port 8081] [-dir db_files] [-sync always|interval|never]
+//
+// Without -dir tables live in memory only. With it every change goes to a
+// write-ahead log in dir first; a db_files directory written by engine.pl
+// is imported on the first start.
+func serve(args []string) (err error) {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	port := flags.Int("port", server.DefaultPort, "port to listen on")
+	dir := flags.String("dir", "", "directory to keep tables in")
+	syncFlag := flags.String("sync", "always", "when to flush the log: always, interval or never")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	engine := storage.NewEngine()
+	if *dir != "" {
+		policy, err := storage.ParseSyncPolicy(*syncFlag)
+		if err != nil {
+			return err
+		}
+		if engine, err = storage.OpenDurable(*dir, storage.Options{Sync: policy}); err != nil {
+			return err
+		}
+	}
+	defer func() {
+		if closeErr := engine.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	httpServer := &http.Server{
+		Addr:    fmt.Sprintf(":%d", *port),
+		Handler: server.New(engine),
+	}
+	shutdown := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+		// Requests still running get a while to finish before the engine
+		// is closed under them
+		timeout, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		shutdown <- httpServer.Shutdown(timeout)
+	}()
+
+	fmt.Printf("Database server running on http://localhost:%d\n", *port)
+	fmt.Println("Send POST requests to /query with JSON body")
+	if err := httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	if err := <-shutdown; err != nil {
+		return fmt.Errorf("shutting down: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"weird/db/engine/ast"
+	"weird/db/engine/token"
+)
+
+// parseExpression parses an expression with a Pratt (precedence climbing)
+// parser. Operators binding tighter than precedence are folded into the
+// result; looser ones are left for the caller.
+func (p *Parser) parseExpression(precedence int) (ast.Expression, error) {
+	left, err := p.parsePrefixExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		p.skipWhitespace()
+
+		if p.pos >= len(p.tokens) {
+			return left, nil
+		}
+
+		operator := p.current.Token
+		opPrecedence := ast.Precedence(operator)
+		if opPrecedence <= precedence {
+			return left, nil
+		}
+
+		p.advance()
+		p.skipWhitespace()
+
+		right, err := p.parseExpression(opPrecedence)
+		if err != nil {
+			return nil, err
+		}
+
+		left = ast.NewBinaryExpression(left, operator, right)
+	}
+}
+
+// parsePrefixExpression parses an operand: a column, a literal, a NOT
+// expression or a parenthesized expression
+func (p *Parser) parsePrefixExpression() (ast.Expression, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of input in expression")
+	}
+
+	if p.atIdentifier() {
+		return p.parseColumnOrAggregate()
+	}
+
+	switch p.current.Token {
+	case token.STRING_TOKEN, token.NUMBER_TOKEN, token.TRUE_TOKEN, token.FALSE_TOKEN, token.NULL_TOKEN:
+		return p.parseLiteral()
+	case token.NOT_TOKEN:
+		p.advance()
+		p.skipWhitespace()
+
+		operand, err := p.parseExpression(ast.NotPrecedence)
+		if err != nil {
+			return nil, err
+		}
+		return ast.NewUnaryExpression(token.NOT_TOKEN, operand), nil
+	case token.LPAREN_TOKEN:
+		p.advance()
+		p.skipWhitespace()
+
+		expr, err := p.parseExpression(ast.LowestPrecedence)
+		if err != nil {
+			return nil, err
+		}
+
+		p.skipWhitespace()
+		if err := p.expect(token.RPAREN_TOKEN); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	default:
+		return nil, fmt.Errorf("unexpected token in expression: %s", p.current.Literal)
+	}
+}
+
+// parseColumnOrAggregate parses a column name, or an aggregate call such as
+// COUNT(*) or SUM(price) when the name is followed by a parenthesis
+func (p *Parser) parseColumnOrAggregate() (ast.Expression, error) {
+	name := p.current.Literal
+	p.advance()
+
+	if p.current.Token != token.LPAREN_TOKEN {
+		return ast.NewIdentifier(name), nil
+	}
+
+	function := strings.ToUpper(name)
+	if !ast.IsAggregateFunction(function) {
+		return nil, fmt.Errorf("unknown function: %s", name)
+	}
+
+	p.advance()
+	p.skipWhitespace()
+
+	if !p.atIdentifier() {
+		return nil, fmt.Errorf("expected column name in %s, got %s", function, p.current.Token)
+	}
+
+	argument := ast.NewIdentifier(p.current.Literal)
+	if argument.Name == "*" && function != "COUNT" {
+		return nil, fmt.Errorf("%s(*) is not supported", function)
+	}
+	p.advance()
+	p.skipWhitespace()
+
+	if err := p.expect(token.RPAREN_TOKEN); err != nil {
+		return nil, err
+	}
+
+	return ast.NewAggregateExpression(function, argument), nil
+}
+
+// parseLiteral parses a typed constant: a quoted string, an integer or
+// floating point number, TRUE, FALSE or NULL
+func (p *Parser) parseLiteral() (*ast.Literal, error) {
+	var lit *ast.Literal
+
+	switch p.current.Token {
+	case token.STRING_TOKEN:
+		raw := p.current.Literal
+		lit = ast.NewStringLiteral(raw[1 : len(raw)-1])
+	case token.NUMBER_TOKEN:
+		if i, err := strconv.ParseInt(p.current.Literal, 10, 64); err == nil {
+			lit = ast.NewIntegerLiteral(i)
+		} else if f, err := strconv.ParseFloat(p.current.Literal, 64); err == nil {
+			lit = ast.NewFloatLiteral(f)
+		} else {
+			return nil, fmt.Errorf("invalid number: %s", p.current.Literal)
+		}
+	case token.TRUE_TOKEN:
+		lit = ast.NewBooleanLiteral(true)
+	case token.FALSE_TOKEN:
+		lit = ast.NewBooleanLiteral(false)
+	case token.NULL_TOKEN:
+		lit = ast.NewNullLiteral()
+	default:
+		return nil, fmt.Errorf("expected value, got %s", p.current.Token)
+	}
+
+	p.advance()
+	return lit, nil
+}
+
+// parseValue parses a value in an INSERT or UPDATE statement. Besides
+// literals, a bare word is taken as a string, as the dialect always has;
+// in an expression, such as a WHERE clause, a word always names a column.
+func (p *Parser) parseValue() (*ast.Literal, error) {
+	if p.atIdentifier() {
+		lit := ast.NewStringLiteral(p.current.Literal)
+		p.advance()
+		return lit, nil
+	}
+	return p.parseLiteral()
+}
@@ -2,6 +2,8 @@ package parser
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"weird/db/engine/ast"
 	"weird/db/engine/lexer"
 	"weird/db/engine/token"
@@ -31,6 +33,10 @@ func (p *Parser) advance() {
 	p.pos++
 	if p.pos < len(p.tokens) {
 		p.current = p.tokens[p.pos]
+	} else {
+		// Past the last token; don't leave a stale token that could
+		// satisfy a later expect()
+		p.current = token.Token{}
 	}
 }
 
@@ -41,6 +47,23 @@ func (p *Parser) peek() *token.Token {
 	return nil
 }
 
+// nextIs reports whether the token after the current one, past line
+// breaks, is one of types
+func (p *Parser) nextIs(types ...token.TokenType) bool {
+	for i := p.pos + 1; i < len(p.tokens); i++ {
+		if p.tokens[i].Token == token.ENDLINE_TOKEN {
+			continue
+		}
+		for _, t := range types {
+			if p.tokens[i].Token == t {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
 func (p *Parser) expect(tokenType token.TokenType) error {
 	if p.current.Token != tokenType {
 		return fmt.Errorf("expected %s, got %s", tokenType, p.current.Token)
@@ -49,6 +72,21 @@ func (p *Parser) expect(tokenType token.TokenType) error {
 	return nil
 }
 
+// nonReserved holds the keywords that also serve as identifiers. They only
+// mean something within ALTER TABLE, so a column may be named "to".
+var nonReserved = map[token.TokenType]bool{
+	token.ALTER_TOKEN:  true,
+	token.ADD_TOKEN:    true,
+	token.COLUMN_TOKEN: true,
+	token.RENAME_TOKEN: true,
+	token.TO_TOKEN:     true,
+}
+
+// atIdentifier reports whether the current token reads as an identifier
+func (p *Parser) atIdentifier() bool {
+	return p.current.Token == token.IDENT_TOKEN || nonReserved[p.current.Token]
+}
+
 func (p *Parser) skipWhitespace() {
 	for p.pos < len(p.tokens) && (p.current.Token == token.ENDLINE_TOKEN || p.current.Token == token.SEMICOLON_TOKEN) {
 		p.advance()
@@ -92,6 +130,26 @@ func (p *Parser) parseStatement() (ast.Statement, error) {
 		return p.parseUPDATEStatement()
 	case token.DELETE_TOKEN:
 		return p.parseDELETEStatement()
+	case token.CREATE_TOKEN:
+		if p.nextIs(token.INDEX_TOKEN, token.UNIQUE_TOKEN) {
+			return p.parseCREATEIndexStatement()
+		}
+		return p.parseCREATETableStatement()
+	case token.ALTER_TOKEN:
+		return p.parseALTERTableStatement()
+	case token.SHOW_TOKEN:
+		return p.parseSHOWTablesStatement()
+	case token.DESCRIBE_TOKEN, token.DESC_TOKEN:
+		return p.parseDESCRIBETableStatement()
+	case token.DROP_TOKEN:
+		if p.nextIs(token.INDEX_TOKEN) {
+			return p.parseDROPIndexStatement()
+		}
+		return p.parseDROPTableStatement()
+	case token.TRUNCATE_TOKEN:
+		return p.parseTRUNCATETableStatement()
+	case token.BEGIN_TOKEN, token.COMMIT_TOKEN, token.ROLLBACK_TOKEN:
+		return p.parseTransactionStatement()
 	default:
 		return nil, fmt.Errorf("unexpected token: %s", p.current.Literal)
 	}
@@ -102,17 +160,20 @@ func (p *Parser) parseSELECTStatement() (*ast.SELECTQueryStatement, error) {
 		return nil, err
 	}
 
-	fields := make([]string, 0)
+	fields := make([]ast.Expression, 0)
 
 	for {
 		p.skipWhitespace()
 
-		if p.current.Token != token.IDENT_TOKEN {
+		if !p.atIdentifier() {
 			return nil, fmt.Errorf("expected field name, got %s", p.current.Token)
 		}
 
-		fields = append(fields, p.current.Literal)
-		p.advance()
+		field, err := p.parseColumnOrAggregate()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
 
 		p.skipWhitespace()
 
@@ -132,86 +193,214 @@ func (p *Parser) parseSELECTStatement() (*ast.SELECTQueryStatement, error) {
 
 	p.skipWhitespace()
 
-	if p.current.Token != token.IDENT_TOKEN {
+	if !p.atIdentifier() {
 		return nil, fmt.Errorf("expected table name, got %s", p.current.Token)
 	}
 
 	tableName := p.current.Literal
 	p.advance()
+	p.skipWhitespace()
 
-	return ast.NewSELECTQueryStatement(fields, tableName), nil
-}
+	joins, err := p.parseJoinClauses()
+	if err != nil {
+		return nil, err
+	}
 
-func (p *Parser) parseINSERTStatement() (*ast.INSERTStatement, error) {
-	if err := p.expect(token.INSERT_TOKEN); err != nil {
+	// Optional WHERE clause
+	where, err := p.parseWhereClause()
+	if err != nil {
 		return nil, err
 	}
 
+	stmt := ast.NewSELECTQueryStatement(fields, tableName, where)
+	stmt.Joins = joins
+
 	p.skipWhitespace()
 
-	if err := p.expect(token.INTO_TOKEN); err != nil {
+	if stmt.GroupBy, err = p.parseGroupByClause(); err != nil {
 		return nil, err
 	}
 
 	p.skipWhitespace()
 
-	if p.current.Token != token.IDENT_TOKEN {
-		return nil, fmt.Errorf("expected table name, got %s", p.current.Token)
+	if p.current.Token == token.HAVING_TOKEN {
+		p.advance()
+		p.skipWhitespace()
+
+		if stmt.Having, err = p.parseExpression(ast.LowestPrecedence); err != nil {
+			return nil, err
+		}
+		p.skipWhitespace()
+	}
+
+	if stmt.OrderBy, err = p.parseOrderByClause(); err != nil {
+		return nil, err
 	}
 
-	tableName := p.current.Literal
-	p.advance()
 	p.skipWhitespace()
 
-	columns := make([]string, 0)
-	if p.current.Token == token.LPAREN_TOKEN {
+	if p.current.Token == token.LIMIT_TOKEN {
 		p.advance()
 		p.skipWhitespace()
 
-		for {
-			if p.current.Token != token.IDENT_TOKEN {
-				return nil, fmt.Errorf("expected column name, got %s", p.current.Token)
-			}
+		if stmt.Limit, err = p.parseCount("LIMIT"); err != nil {
+			return nil, err
+		}
+		p.skipWhitespace()
+	}
+
+	if p.current.Token == token.OFFSET_TOKEN {
+		p.advance()
+		p.skipWhitespace()
+
+		if stmt.Offset, err = p.parseCount("OFFSET"); err != nil {
+			return nil, err
+		}
+	}
+
+	return stmt, nil
+}
 
-			columns = append(columns, p.current.Literal)
+// parseJoinClauses parses any number of ", table", "[INNER] JOIN table ON
+// expr" and "LEFT [OUTER] JOIN table ON expr" clauses after the FROM table
+func (p *Parser) parseJoinClauses() ([]ast.JoinClause, error) {
+	joins := make([]ast.JoinClause, 0)
+
+	for {
+		var joinType ast.JoinType
+
+		switch p.current.Token {
+		case token.COMMA_TOKEN:
+			joinType = ast.CrossJoin
+			p.advance()
+		case token.JOIN_TOKEN:
+			joinType = ast.InnerJoin
+			p.advance()
+		case token.INNER_TOKEN:
+			joinType = ast.InnerJoin
 			p.advance()
 			p.skipWhitespace()
-
-			if p.current.Token == token.COMMA_TOKEN {
+			if err := p.expect(token.JOIN_TOKEN); err != nil {
+				return nil, err
+			}
+		case token.LEFT_TOKEN:
+			joinType = ast.LeftJoin
+			p.advance()
+			p.skipWhitespace()
+			if p.current.Token == token.OUTER_TOKEN {
 				p.advance()
 				p.skipWhitespace()
-				continue
 			}
+			if err := p.expect(token.JOIN_TOKEN); err != nil {
+				return nil, err
+			}
+		default:
+			return joins, nil
+		}
 
-			break
+		p.skipWhitespace()
+
+		if !p.atIdentifier() {
+			return nil, fmt.Errorf("expected table name, got %s", p.current.Token)
+		}
+
+		join := ast.JoinClause{Type: joinType, Table: p.current.Literal}
+		p.advance()
+		p.skipWhitespace()
+
+		if joinType != ast.CrossJoin {
+			if err := p.expect(token.ON_TOKEN); err != nil {
+				return nil, err
+			}
+			p.skipWhitespace()
+
+			on, err := p.parseExpression(ast.LowestPrecedence)
+			if err != nil {
+				return nil, err
+			}
+			join.On = on
+			p.skipWhitespace()
+		}
+
+		joins = append(joins, join)
+	}
+}
+
+// parseOrderByClause parses an optional "ORDER BY col [ASC|DESC], ..." clause
+func (p *Parser) parseOrderByClause() ([]ast.OrderByItem, error) {
+	if p.current.Token != token.ORDER_TOKEN {
+		return nil, nil
+	}
+
+	p.advance()
+	p.skipWhitespace()
+
+	if err := p.expect(token.BY_TOKEN); err != nil {
+		return nil, err
+	}
+
+	p.skipWhitespace()
+
+	items := make([]ast.OrderByItem, 0)
+	for {
+		if !p.atIdentifier() {
+			return nil, fmt.Errorf("expected column name in ORDER BY clause, got %s", p.current.Token)
 		}
 
-		if err := p.expect(token.RPAREN_TOKEN); err != nil {
+		column, err := p.parseColumnOrAggregate()
+		if err != nil {
 			return nil, err
 		}
+
+		item := ast.OrderByItem{Column: column.String()}
 		p.skipWhitespace()
+
+		switch p.current.Token {
+		case token.DESC_TOKEN:
+			item.Descending = true
+			p.advance()
+			p.skipWhitespace()
+		case token.ASC_TOKEN:
+			p.advance()
+			p.skipWhitespace()
+		}
+
+		items = append(items, item)
+
+		if p.current.Token == token.COMMA_TOKEN {
+			p.advance()
+			p.skipWhitespace()
+			continue
+		}
+
+		break
 	}
 
-	if err := p.expect(token.VALUES_TOKEN); err != nil {
-		return nil, err
+	return items, nil
+}
+
+// parseGroupByClause parses an optional "GROUP BY col, ..." clause
+func (p *Parser) parseGroupByClause() ([]string, error) {
+	if p.current.Token != token.GROUP_TOKEN {
+		return nil, nil
 	}
 
+	p.advance()
 	p.skipWhitespace()
 
-	if err := p.expect(token.LPAREN_TOKEN); err != nil {
+	if err := p.expect(token.BY_TOKEN); err != nil {
 		return nil, err
 	}
 
 	p.skipWhitespace()
 
-	// Parse values
-	values := make([]string, 0)
+	columns := make([]string, 0)
 	for {
-		if p.current.Token != token.STRING_TOKEN && p.current.Token != token.NUMBER_TOKEN && p.current.Token != token.IDENT_TOKEN {
-			return nil, fmt.Errorf("expected value, got %s", p.current.Token)
+		if !p.atIdentifier() {
+			return nil, fmt.Errorf("expected column name in GROUP BY clause, got %s", p.current.Token)
 		}
 
-		values = append(values, p.current.Literal)
+		columns = append(columns, p.current.Literal)
 		p.advance()
 		p.skipWhitespace()
 
@@ -224,57 +413,77 @@ func (p *Parser) parseINSERTStatement() (*ast.INSERTStatement, error) {
 		break
 	}
 
-	if err := p.expect(token.RPAREN_TOKEN); err != nil {
-		return nil, err
+	return columns, nil
+}
+
+// parseCount parses the non-negative row count following LIMIT or OFFSET
+func (p *Parser) parseCount(clause string) (*int, error) {
+	if p.current.Token != token.NUMBER_TOKEN {
+		return nil, fmt.Errorf("expected row count after %s, got %s", clause, p.current.Token)
 	}
 
-	return ast.NewINSERTStatement(tableName, columns, values), nil
+	n, err := strconv.Atoi(p.current.Literal)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid %s row count: %s", clause, p.current.Literal)
+	}
+
+	p.advance()
+	return &n, nil
 }
 
-func (p *Parser) parseUPDATEStatement() (*ast.UPDATEStatement, error) {
-	if err := p.expect(token.UPDATE_TOKEN); err != nil {
+func (p *Parser) parseINSERTStatement() (*ast.INSERTStatement, error) {
+	if err := p.expect(token.INSERT_TOKEN); err != nil {
 		return nil, err
 	}
 
 	p.skipWhitespace()
 
-	if p.current.Token != token.IDENT_TOKEN {
-		return nil, fmt.Errorf("expected table name, got %s", p.current.Token)
+	if err := p.expect(token.INTO_TOKEN); err != nil {
+		return nil, err
 	}
 
-	tableName := p.current.Literal
-	p.advance()
 	p.skipWhitespace()
 
-	if err := p.expect(token.SET_TOKEN); err != nil {
-		return nil, err
+	if !p.atIdentifier() {
+		return nil, fmt.Errorf("expected table name, got %s", p.current.Token)
 	}
 
+	tableName := p.current.Literal
+	p.advance()
 	p.skipWhitespace()
 
-	assignments := make(map[string]string)
-	for {
-		if p.current.Token != token.IDENT_TOKEN {
-			return nil, fmt.Errorf("expected column name, got %s", p.current.Token)
+	columns := make([]string, 0)
+	if p.current.Token == token.LPAREN_TOKEN {
+		var err error
+		if columns, err = p.parseColumnList(); err != nil {
+			return nil, err
 		}
-
-		colName := p.current.Literal
-		p.advance()
 		p.skipWhitespace()
+	}
 
-		if err := p.expect(token.EQUALS_TOKEN); err != nil {
+	if p.current.Token == token.SELECT_TOKEN {
+		query, err := p.parseSELECTStatement()
+		if err != nil {
 			return nil, err
 		}
+		return ast.NewINSERTSelectStatement(tableName, columns, query), nil
+	}
 
-		p.skipWhitespace()
+	if err := p.expect(token.VALUES_TOKEN); err != nil {
+		return nil, err
+	}
+
+	p.skipWhitespace()
 
-		if p.current.Token != token.STRING_TOKEN && p.current.Token != token.NUMBER_TOKEN && p.current.Token != token.IDENT_TOKEN {
-			return nil, fmt.Errorf("expected value, got %s", p.current.Token)
+	// Parse one or more value tuples
+	rows := make([][]*ast.Literal, 0)
+	for {
+		values, err := p.parseValueTuple()
+		if err != nil {
+			return nil, err
 		}
+		rows = append(rows, values)
 
-		value := p.current.Literal
-		assignments[colName] = value
-		p.advance()
 		p.skipWhitespace()
 
 		if p.current.Token == token.COMMA_TOKEN {
@@ -286,50 +495,51 @@ func (p *Parser) parseUPDATEStatement() (*ast.UPDATEStatement, error) {
 		break
 	}
 
-	whereCol := ""
-	whereVal := ""
-	if p.current.Token == token.WHERE_TOKEN {
-		p.advance()
-		p.skipWhitespace()
+	return ast.NewINSERTStatement(tableName, columns, rows), nil
+}
 
-		if p.current.Token != token.IDENT_TOKEN {
-			return nil, fmt.Errorf("expected column name in WHERE clause, got %s", p.current.Token)
-		}
+// parseValueTuple parses a parenthesized, comma separated list of values
+func (p *Parser) parseValueTuple() ([]*ast.Literal, error) {
+	if err := p.expect(token.LPAREN_TOKEN); err != nil {
+		return nil, err
+	}
 
-		whereCol = p.current.Literal
-		p.advance()
-		p.skipWhitespace()
+	p.skipWhitespace()
 
-		if err := p.expect(token.EQUALS_TOKEN); err != nil {
+	values := make([]*ast.Literal, 0)
+	for {
+		value, err := p.parseValue()
+		if err != nil {
 			return nil, err
 		}
 
+		values = append(values, value)
 		p.skipWhitespace()
 
-		if p.current.Token != token.STRING_TOKEN && p.current.Token != token.NUMBER_TOKEN && p.current.Token != token.IDENT_TOKEN {
-			return nil, fmt.Errorf("expected value in WHERE clause, got %s", p.current.Token)
+		if p.current.Token == token.COMMA_TOKEN {
+			p.advance()
+			p.skipWhitespace()
+			continue
 		}
 
-		whereVal = p.current.Literal
-		p.advance()
+		break
 	}
 
-	return ast.NewUPDATEStatement(tableName, assignments, whereCol, whereVal), nil
-}
-func (p *Parser) parseDELETEStatement() (*ast.DELETEStatement, error) {
-	if err := p.expect(token.DELETE_TOKEN); err != nil {
+	if err := p.expect(token.RPAREN_TOKEN); err != nil {
 		return nil, err
 	}
 
-	p.skipWhitespace()
+	return values, nil
+}
 
-	if err := p.expect(token.FROM_TOKEN); err != nil {
+func (p *Parser) parseUPDATEStatement() (*ast.UPDATEStatement, error) {
+	if err := p.expect(token.UPDATE_TOKEN); err != nil {
 		return nil, err
 	}
 
 	p.skipWhitespace()
 
-	if p.current.Token != token.IDENT_TOKEN {
+	if !p.atIdentifier() {
 		return nil, fmt.Errorf("expected table name, got %s", p.current.Token)
 	}
 
@@ -337,18 +547,24 @@ func (p *Parser) parseDELETEStatement() (*ast.DELETEStatement, error) {
 	p.advance()
 	p.skipWhitespace()
 
-	// Optional WHERE clause
-	whereCol := ""
-	whereVal := ""
-	if p.current.Token == token.WHERE_TOKEN {
-		p.advance()
-		p.skipWhitespace()
+	if err := p.expect(token.SET_TOKEN); err != nil {
+		return nil, err
+	}
+
+	p.skipWhitespace()
 
-		if p.current.Token != token.IDENT_TOKEN {
-			return nil, fmt.Errorf("expected column name in WHERE clause, got %s", p.current.Token)
+	assignments := make(map[string]*ast.Literal)
+	for {
+		if !p.atIdentifier() {
+			return nil, fmt.Errorf("expected column name, got %s", p.current.Token)
 		}
 
-		whereCol = p.current.Literal
+		colName := p.current.Literal
+		for assigned := range assignments {
+			if strings.EqualFold(assigned, colName) {
+				return nil, fmt.Errorf("column %s assigned more than once in SET", colName)
+			}
+		}
 		p.advance()
 		p.skipWhitespace()
 
@@ -358,34 +574,701 @@ func (p *Parser) parseDELETEStatement() (*ast.DELETEStatement, error) {
 
 		p.skipWhitespace()
 
-		if p.current.Token != token.STRING_TOKEN && p.current.Token != token.NUMBER_TOKEN && p.current.Token != token.IDENT_TOKEN {
-			return nil, fmt.Errorf("expected value in WHERE clause, got %s", p.current.Token)
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
 		}
 
-		whereVal = p.current.Literal
-		p.advance()
+		assignments[colName] = value
+		p.skipWhitespace()
+
+		if p.current.Token == token.COMMA_TOKEN {
+			p.advance()
+			p.skipWhitespace()
+			continue
+		}
+
+		break
+	}
+
+	where, err := p.parseWhereClause()
+	if err != nil {
+		return nil, err
 	}
 
-	return ast.NewDELETEStatement(tableName, whereCol, whereVal), nil
+	return ast.NewUPDATEStatement(tableName, assignments, where), nil
 }
+func (p *Parser) parseDELETEStatement() (*ast.DELETEStatement, error) {
+	if err := p.expect(token.DELETE_TOKEN); err != nil {
+		return nil, err
+	}
 
-func ParseSingle(tokens []token.Token) (ast.Statement, error) {
-	p := New(tokens)
-	program, err := p.Parse()
+	p.skipWhitespace()
+
+	if err := p.expect(token.FROM_TOKEN); err != nil {
+		return nil, err
+	}
+
+	p.skipWhitespace()
+
+	if !p.atIdentifier() {
+		return nil, fmt.Errorf("expected table name, got %s", p.current.Token)
+	}
+
+	tableName := p.current.Literal
+	p.advance()
+	p.skipWhitespace()
+
+	// Optional WHERE clause
+	where, err := p.parseWhereClause()
 	if err != nil {
 		return nil, err
 	}
 
-	if len(program.Statements) == 0 {
-		return nil, fmt.Errorf("no statements found")
+	return ast.NewDELETEStatement(tableName, where), nil
+}
+
+// parseWhereClause parses an optional "WHERE <expression>" clause. The
+// result is nil when the statement has no WHERE clause.
+func (p *Parser) parseWhereClause() (ast.Expression, error) {
+	if p.current.Token != token.WHERE_TOKEN {
+		return nil, nil
 	}
 
-	return program.Statements[0], nil
+	p.advance()
+	p.skipWhitespace()
+
+	return p.parseExpression(ast.LowestPrecedence)
 }
 
-func ParseString(q string) (*ast.Program, error) {
-	l := lexer.Lexer{}
-	t := l.Tokenize(q)
+func (p *Parser) parseCREATETableStatement() (*ast.CREATETableStatement, error) {
+	if err := p.expect(token.CREATE_TOKEN); err != nil {
+		return nil, err
+	}
+
+	p.skipWhitespace()
+
+	if err := p.expect(token.TABLE_TOKEN); err != nil {
+		return nil, err
+	}
+
+	p.skipWhitespace()
+
+	if !p.atIdentifier() {
+		return nil, fmt.Errorf("expected table name, got %s", p.current.Token)
+	}
+
+	tableName := p.current.Literal
+	p.advance()
+	p.skipWhitespace()
+
+	if err := p.expect(token.LPAREN_TOKEN); err != nil {
+		return nil, err
+	}
+
+	p.skipWhitespace()
+
+	columns := make([]ast.ColumnDefinition, 0)
+	var constraints []ast.TableConstraint
+	for {
+		switch p.current.Token {
+		case token.CONSTRAINT_TOKEN, token.PRIMARY_TOKEN, token.UNIQUE_TOKEN, token.CHECK_TOKEN, token.FOREIGN_TOKEN:
+			constraint, err := p.parseTableConstraint()
+			if err != nil {
+				return nil, err
+			}
+			constraints = append(constraints, constraint)
+		default:
+			column, err := p.parseColumnDefinition()
+			if err != nil {
+				return nil, err
+			}
+			columns = append(columns, column)
+		}
+
+		if p.current.Token == token.COMMA_TOKEN {
+			p.advance()
+			p.skipWhitespace()
+			continue
+		}
+
+		break
+	}
+
+	if err := p.expect(token.RPAREN_TOKEN); err != nil {
+		return nil, err
+	}
+
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table %s must have at least one column", tableName)
+	}
+
+	return ast.NewCREATETableStatement(tableName, columns, constraints), nil
+}
+
+// parseColumnDefinition parses a column name, an optional type and any
+// inline constraints: PRIMARY KEY, UNIQUE, NOT NULL, NULL, DEFAULT value,
+// CHECK (condition) and REFERENCES table (column)
+func (p *Parser) parseColumnDefinition() (ast.ColumnDefinition, error) {
+	if !p.atIdentifier() {
+		return ast.ColumnDefinition{}, fmt.Errorf("expected column name, got %s", p.current.Token)
+	}
+
+	column := ast.ColumnDefinition{Name: p.current.Literal}
+	p.advance()
+	p.skipWhitespace()
+
+	// Optional column type
+	if p.current.Token == token.IDENT_TOKEN {
+		columnType, ok := ast.ParseColumnType(p.current.Literal)
+		if !ok {
+			return column, fmt.Errorf("unknown type %s for column %s", p.current.Literal, column.Name)
+		}
+		column.Type = columnType
+		p.advance()
+		p.skipWhitespace()
+	}
+
+	for {
+		switch p.current.Token {
+		case token.PRIMARY_TOKEN:
+			p.advance()
+			p.skipWhitespace()
+			if err := p.expect(token.KEY_TOKEN); err != nil {
+				return column, err
+			}
+			column.PrimaryKey = true
+		case token.UNIQUE_TOKEN:
+			p.advance()
+			column.Unique = true
+		case token.NOT_TOKEN:
+			p.advance()
+			p.skipWhitespace()
+			if err := p.expect(token.NULL_TOKEN); err != nil {
+				return column, err
+			}
+			column.NotNull = true
+		case token.NULL_TOKEN:
+			// Explicitly nullable, which every column already is
+			p.advance()
+		case token.DEFAULT_TOKEN:
+			p.advance()
+			p.skipWhitespace()
+			value, err := p.parseLiteral()
+			if err != nil {
+				return column, fmt.Errorf("in DEFAULT for column %s: %w", column.Name, err)
+			}
+			column.Default = value
+		case token.CHECK_TOKEN:
+			check, err := p.parseCheck()
+			if err != nil {
+				return column, err
+			}
+			column.Check = check
+		case token.REFERENCES_TOKEN:
+			references, err := p.parseReferences()
+			if err != nil {
+				return column, err
+			}
+			column.References = references
+		default:
+			return column, nil
+		}
+		p.skipWhitespace()
+	}
+}
+
+// parseTableConstraint parses a table-level constraint, optionally named
+// with CONSTRAINT name: PRIMARY KEY (cols), UNIQUE (cols), CHECK (condition)
+// or FOREIGN KEY (cols) REFERENCES table (cols)
+func (p *Parser) parseTableConstraint() (ast.TableConstraint, error) {
+	var constraint ast.TableConstraint
+
+	if p.current.Token == token.CONSTRAINT_TOKEN {
+		p.advance()
+		p.skipWhitespace()
+		if !p.atIdentifier() {
+			return constraint, fmt.Errorf("expected constraint name, got %s", p.current.Token)
+		}
+		constraint.Name = p.current.Literal
+		p.advance()
+		p.skipWhitespace()
+	}
+
+	var err error
+	switch p.current.Token {
+	case token.PRIMARY_TOKEN:
+		p.advance()
+		p.skipWhitespace()
+		if err := p.expect(token.KEY_TOKEN); err != nil {
+			return constraint, err
+		}
+		p.skipWhitespace()
+		constraint.Kind = ast.PrimaryKeyConstraint
+		constraint.Columns, err = p.parseColumnList()
+	case token.UNIQUE_TOKEN:
+		p.advance()
+		p.skipWhitespace()
+		constraint.Kind = ast.UniqueConstraint
+		constraint.Columns, err = p.parseColumnList()
+	case token.CHECK_TOKEN:
+		constraint.Kind = ast.CheckConstraint
+		constraint.Check, err = p.parseCheck()
+	case token.FOREIGN_TOKEN:
+		p.advance()
+		p.skipWhitespace()
+		if err := p.expect(token.KEY_TOKEN); err != nil {
+			return constraint, err
+		}
+		p.skipWhitespace()
+		constraint.Kind = ast.ForeignKeyConstraint
+		if constraint.Columns, err = p.parseColumnList(); err != nil {
+			return constraint, err
+		}
+		p.skipWhitespace()
+		constraint.References, err = p.parseReferences()
+	default:
+		return constraint, fmt.Errorf("expected PRIMARY KEY, UNIQUE, CHECK or FOREIGN KEY, got %s", p.current.Token)
+	}
+	if err != nil {
+		return constraint, err
+	}
+
+	p.skipWhitespace()
+	return constraint, nil
+}
+
+// parseReferences parses REFERENCES table, an optional parenthesized column
+// list and an optional ON DELETE CASCADE | SET NULL | RESTRICT, which is the
+// default
+func (p *Parser) parseReferences() (*ast.ForeignKey, error) {
+	if err := p.expect(token.REFERENCES_TOKEN); err != nil {
+		return nil, err
+	}
+	p.skipWhitespace()
+
+	if !p.atIdentifier() {
+		return nil, fmt.Errorf("expected table name after REFERENCES, got %s", p.current.Token)
+	}
+	references := &ast.ForeignKey{Table: p.current.Literal, OnDelete: ast.RestrictAction}
+	p.advance()
+	p.skipWhitespace()
+
+	if p.current.Token == token.LPAREN_TOKEN {
+		columns, err := p.parseColumnList()
+		if err != nil {
+			return nil, err
+		}
+		references.Columns = columns
+		p.skipWhitespace()
+	}
+
+	if p.current.Token != token.ON_TOKEN {
+		return references, nil
+	}
+	p.advance()
+	p.skipWhitespace()
+	if err := p.expect(token.DELETE_TOKEN); err != nil {
+		return nil, err
+	}
+	p.skipWhitespace()
+
+	switch p.current.Token {
+	case token.CASCADE_TOKEN:
+		references.OnDelete = ast.CascadeAction
+	case token.RESTRICT_TOKEN:
+		references.OnDelete = ast.RestrictAction
+	case token.SET_TOKEN:
+		p.advance()
+		p.skipWhitespace()
+		if p.current.Token != token.NULL_TOKEN {
+			return nil, fmt.Errorf("expected NULL after ON DELETE SET, got %s", p.current.Token)
+		}
+		references.OnDelete = ast.SetNullAction
+	default:
+		return nil, fmt.Errorf("expected CASCADE, SET NULL or RESTRICT after ON DELETE, got %s", p.current.Token)
+	}
+	p.advance()
+	return references, nil
+}
+
+// parseCheck parses CHECK followed by a parenthesized condition
+func (p *Parser) parseCheck() (ast.Expression, error) {
+	if err := p.expect(token.CHECK_TOKEN); err != nil {
+		return nil, err
+	}
+	p.skipWhitespace()
+
+	if err := p.expect(token.LPAREN_TOKEN); err != nil {
+		return nil, err
+	}
+	p.skipWhitespace()
+
+	check, err := p.parseExpression(ast.LowestPrecedence)
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipWhitespace()
+	if err := p.expect(token.RPAREN_TOKEN); err != nil {
+		return nil, err
+	}
+	return check, nil
+}
+
+// parseColumnList parses a parenthesized, comma separated list of column
+// names
+func (p *Parser) parseColumnList() ([]string, error) {
+	if err := p.expect(token.LPAREN_TOKEN); err != nil {
+		return nil, err
+	}
+	p.skipWhitespace()
+
+	var columns []string
+	for {
+		if !p.atIdentifier() {
+			return nil, fmt.Errorf("expected column name, got %s", p.current.Token)
+		}
+		columns = append(columns, p.current.Literal)
+		p.advance()
+		p.skipWhitespace()
+
+		if p.current.Token != token.COMMA_TOKEN {
+			break
+		}
+		p.advance()
+		p.skipWhitespace()
+	}
+
+	if err := p.expect(token.RPAREN_TOKEN); err != nil {
+		return nil, err
+	}
+	return columns, nil
+}
+
+// parseALTERTableStatement parses ALTER TABLE t followed by ADD [COLUMN]
+// definition, DROP [COLUMN] c, RENAME [COLUMN] a TO b or RENAME TO name
+func (p *Parser) parseALTERTableStatement() (*ast.ALTERTableStatement, error) {
+	if err := p.expect(token.ALTER_TOKEN); err != nil {
+		return nil, err
+	}
+	p.skipWhitespace()
+
+	if err := p.expect(token.TABLE_TOKEN); err != nil {
+		return nil, err
+	}
+	p.skipWhitespace()
+
+	if !p.atIdentifier() {
+		return nil, fmt.Errorf("expected table name, got %s", p.current.Token)
+	}
+	tableName := p.current.Literal
+	p.advance()
+	p.skipWhitespace()
+
+	var action ast.AlterAction
+	switch p.current.Token {
+	case token.ADD_TOKEN:
+		p.advance()
+		p.skipColumnKeyword()
+
+		column, err := p.parseColumnDefinition()
+		if err != nil {
+			return nil, err
+		}
+		action = &ast.AddColumnAction{Column: column}
+
+	case token.DROP_TOKEN:
+		p.advance()
+		p.skipColumnKeyword()
+
+		if !p.atIdentifier() {
+			return nil, fmt.Errorf("expected column name, got %s", p.current.Token)
+		}
+		action = &ast.DropColumnAction{Column: p.current.Literal}
+		p.advance()
+
+	case token.RENAME_TOKEN:
+		p.advance()
+		p.skipWhitespace()
+
+		if p.current.Token == token.TO_TOKEN {
+			p.advance()
+			p.skipWhitespace()
+			if !p.atIdentifier() {
+				return nil, fmt.Errorf("expected new table name, got %s", p.current.Token)
+			}
+			action = &ast.RenameTableAction{NewName: p.current.Literal}
+			p.advance()
+			break
+		}
+
+		p.skipColumnKeyword()
+		if !p.atIdentifier() {
+			return nil, fmt.Errorf("expected column name, got %s", p.current.Token)
+		}
+		column := p.current.Literal
+		p.advance()
+		p.skipWhitespace()
+
+		if err := p.expect(token.TO_TOKEN); err != nil {
+			return nil, err
+		}
+		p.skipWhitespace()
+		if !p.atIdentifier() {
+			return nil, fmt.Errorf("expected new column name, got %s", p.current.Token)
+		}
+		action = &ast.RenameColumnAction{Column: column, NewName: p.current.Literal}
+		p.advance()
+
+	default:
+		return nil, fmt.Errorf("expected ADD, DROP or RENAME after ALTER TABLE %s, got %s", tableName, p.current.Token)
+	}
+
+	return ast.NewALTERTableStatement(tableName, action), nil
+}
+
+// skipColumnKeyword skips the optional COLUMN keyword of ALTER TABLE
+func (p *Parser) skipColumnKeyword() {
+	p.skipWhitespace()
+	if p.current.Token == token.COLUMN_TOKEN {
+		p.advance()
+		p.skipWhitespace()
+	}
+}
+
+func (p *Parser) parseSHOWTablesStatement() (*ast.SHOWTablesStatement, error) {
+	if err := p.expect(token.SHOW_TOKEN); err != nil {
+		return nil, err
+	}
+
+	p.skipWhitespace()
+
+	if err := p.expect(token.TABLES_TOKEN); err != nil {
+		return nil, err
+	}
+
+	return ast.NewSHOWTablesStatement(), nil
+}
+
+// parseDESCRIBETableStatement parses DESCRIBE t, or its short form DESC t
+func (p *Parser) parseDESCRIBETableStatement() (*ast.DESCRIBETableStatement, error) {
+	p.advance()
+	p.skipWhitespace()
+
+	// DESCRIBE TABLE t is accepted too
+	if p.current.Token == token.TABLE_TOKEN {
+		p.advance()
+		p.skipWhitespace()
+	}
+
+	if !p.atIdentifier() {
+		return nil, fmt.Errorf("expected table name, got %s", p.current.Token)
+	}
+
+	tableName := p.current.Literal
+	p.advance()
+
+	return ast.NewDESCRIBETableStatement(tableName), nil
+}
+
+// parseTransactionStatement parses BEGIN, COMMIT and ROLLBACK, each
+// optionally followed by TRANSACTION
+func (p *Parser) parseTransactionStatement() (ast.TransactionStatement, error) {
+	var stmt ast.TransactionStatement
+	switch p.current.Token {
+	case token.BEGIN_TOKEN:
+		stmt = ast.NewBEGINStatement()
+	case token.COMMIT_TOKEN:
+		stmt = ast.NewCOMMITStatement()
+	default:
+		stmt = ast.NewROLLBACKStatement()
+	}
+	p.advance()
+	p.skipWhitespace()
+
+	if p.current.Token == token.TRANSACTION_TOKEN {
+		p.advance()
+	}
+
+	return stmt, nil
+}
+
+func (p *Parser) parseDROPTableStatement() (*ast.DROPTableStatement, error) {
+	if err := p.expect(token.DROP_TOKEN); err != nil {
+		return nil, err
+	}
+
+	p.skipWhitespace()
+
+	if err := p.expect(token.TABLE_TOKEN); err != nil {
+		return nil, err
+	}
+
+	p.skipWhitespace()
+
+	ifExists := false
+	if p.current.Token == token.IF_TOKEN {
+		p.advance()
+		p.skipWhitespace()
+
+		if err := p.expect(token.EXISTS_TOKEN); err != nil {
+			return nil, err
+		}
+
+		p.skipWhitespace()
+		ifExists = true
+	}
+
+	if !p.atIdentifier() {
+		return nil, fmt.Errorf("expected table name, got %s", p.current.Token)
+	}
+
+	tableName := p.current.Literal
+	p.advance()
+
+	return ast.NewDROPTableStatement(tableName, ifExists), nil
+}
+
+// parseCREATEIndexStatement parses CREATE [UNIQUE] INDEX name ON t (c, ...)
+func (p *Parser) parseCREATEIndexStatement() (*ast.CREATEIndexStatement, error) {
+	if err := p.expect(token.CREATE_TOKEN); err != nil {
+		return nil, err
+	}
+	p.skipWhitespace()
+
+	unique := false
+	if p.current.Token == token.UNIQUE_TOKEN {
+		unique = true
+		p.advance()
+		p.skipWhitespace()
+	}
+
+	if err := p.expect(token.INDEX_TOKEN); err != nil {
+		return nil, err
+	}
+	p.skipWhitespace()
+
+	if !p.atIdentifier() {
+		return nil, fmt.Errorf("expected index name, got %s", p.current.Token)
+	}
+	name := p.current.Literal
+	p.advance()
+	p.skipWhitespace()
+
+	if err := p.expect(token.ON_TOKEN); err != nil {
+		return nil, err
+	}
+	p.skipWhitespace()
+
+	if !p.atIdentifier() {
+		return nil, fmt.Errorf("expected table name, got %s", p.current.Token)
+	}
+	tableName := p.current.Literal
+	p.advance()
+	p.skipWhitespace()
+
+	columns, err := p.parseColumnList()
+	if err != nil {
+		return nil, err
+	}
+
+	return ast.NewCREATEIndexStatement(name, tableName, columns, unique), nil
+}
+
+// parseDROPIndexStatement parses DROP INDEX [IF EXISTS] name
+func (p *Parser) parseDROPIndexStatement() (*ast.DROPIndexStatement, error) {
+	if err := p.expect(token.DROP_TOKEN); err != nil {
+		return nil, err
+	}
+	p.skipWhitespace()
+
+	if err := p.expect(token.INDEX_TOKEN); err != nil {
+		return nil, err
+	}
+	p.skipWhitespace()
+
+	ifExists := false
+	if p.current.Token == token.IF_TOKEN {
+		p.advance()
+		p.skipWhitespace()
+
+		if err := p.expect(token.EXISTS_TOKEN); err != nil {
+			return nil, err
+		}
+
+		p.skipWhitespace()
+		ifExists = true
+	}
+
+	if !p.atIdentifier() {
+		return nil, fmt.Errorf("expected index name, got %s", p.current.Token)
+	}
+	name := p.current.Literal
+	p.advance()
+
+	return ast.NewDROPIndexStatement(name, ifExists), nil
+}
+
+func (p *Parser) parseTRUNCATETableStatement() (*ast.TRUNCATETableStatement, error) {
+	if err := p.expect(token.TRUNCATE_TOKEN); err != nil {
+		return nil, err
+	}
+
+	p.skipWhitespace()
+
+	// TABLE keyword is optional, as in most SQL dialects
+	if p.current.Token == token.TABLE_TOKEN {
+		p.advance()
+		p.skipWhitespace()
+	}
+
+	if !p.atIdentifier() {
+		return nil, fmt.Errorf("expected table name, got %s", p.current.Token)
+	}
+
+	tableName := p.current.Literal
+	p.advance()
+
+	return ast.NewTRUNCATETableStatement(tableName), nil
+}
+
+func ParseSingle(tokens []token.Token) (ast.Statement, error) {
+	p := New(tokens)
+	program, err := p.Parse()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(program.Statements) == 0 {
+		return nil, fmt.Errorf("no statements found")
+	}
+
+	return program.Statements[0], nil
+}
+
+func ParseString(q string) (*ast.Program, error) {
+	l := lexer.Lexer{}
+	t := l.Tokenize(q)
 	p := New(t)
 	return p.Parse()
 }
+
+// ParseExpression parses a standalone expression, such as a stored CHECK
+// condition
+func ParseExpression(q string) (ast.Expression, error) {
+	l := lexer.Lexer{}
+	p := New(l.Tokenize(q))
+	p.skipWhitespace()
+
+	expr, err := p.parseExpression(ast.LowestPrecedence)
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipWhitespace()
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %s after expression", p.current.Literal)
+	}
+	return expr, nil
+}
@@ -0,0 +1,385 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"weird/db/engine/ast"
+)
+
+// parse parses a single statement, failing the test on an error
+func parse(t *testing.T, q string) ast.Statement {
+	t.Helper()
+	program, err := ParseString(q)
+	if err != nil {
+		t.Fatalf("%s: %v", q, err)
+	}
+	if len(program.Statements) != 1 {
+		t.Fatalf("%s: got %d statements", q, len(program.Statements))
+	}
+	return program.Statements[0]
+}
+
+// parseFails fails the test unless q is refused with an error containing
+// message
+func parseFails(t *testing.T, q string, message string) {
+	t.Helper()
+	_, err := ParseString(q)
+	if err == nil {
+		t.Fatalf("%s: expected an error containing %q, got success", q, message)
+	}
+	if !strings.Contains(err.Error(), message) {
+		t.Fatalf("%s: expected an error containing %q, got %v", q, message, err)
+	}
+}
+
+// tree renders an expression with every operation parenthesized, so that
+// tests see how it was grouped
+func tree(expr ast.Expression) string {
+	switch ex := expr.(type) {
+	case *ast.BinaryExpression:
+		return "(" + tree(ex.Left) + " " + string(ex.Operator) + " " + tree(ex.Right) + ")"
+	case *ast.UnaryExpression:
+		return "(" + string(ex.Operator) + " " + tree(ex.Operand) + ")"
+	case nil:
+		return "<nil>"
+	default:
+		return ex.String()
+	}
+}
+
+func TestParseWhere(t *testing.T) {
+	tests := []struct {
+		where string
+		want  string
+	}{
+		{"a = 1", "(a = 1)"},
+		{"a = 1 AND b = 'x' OR c = 2", "(((a = 1) AND (b = 'x')) OR (c = 2))"},
+		{"a = 1 OR b = 2 AND c = 3", "((a = 1) OR ((b = 2) AND (c = 3)))"},
+		{"(a = 1 OR b = 2) AND c = 3", "(((a = 1) OR (b = 2)) AND (c = 3))"},
+		{"NOT a = 1 AND b = 2", "((NOT (a = 1)) AND (b = 2))"},
+		{"NOT (a = 1 OR b = 2)", "(NOT ((a = 1) OR (b = 2)))"},
+		{"a = 1 AND b = 2 AND c = 3", "(((a = 1) AND (b = 2)) AND (c = 3))"},
+		{"users.age = 2.5", "(users.age = 2.5)"},
+		{"a = TRUE OR b = NULL", "((a = TRUE) OR (b = NULL))"},
+		{"a = 'it''s'", "(a = 'it''s')"},
+		{"name = john", "(name = john)"},
+	}
+	for _, test := range tests {
+		stmt := parse(t, "DELETE FROM t WHERE "+test.where).(*ast.DELETEStatement)
+		if got := tree(stmt.Where); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.where, got, test.want)
+		}
+	}
+}
+
+func TestParseWhereErrors(t *testing.T) {
+	for _, where := range []string{
+		"",
+		"a = 1 AND",
+		"(a = 1",
+		"a = 1)",
+		"NOT",
+		"a = ,",
+	} {
+		if _, err := ParseString("SELECT * FROM t WHERE " + where); err == nil {
+			t.Errorf("WHERE %s: expected an error", where)
+		}
+	}
+}
+
+func TestParseUpdate(t *testing.T) {
+	stmt := parse(t, "UPDATE t SET a = 1, b = word, c = NULL WHERE a = 2 OR b = 'x'").(*ast.UPDATEStatement)
+	want := map[string]string{"a": "1", "b": "'word'", "c": "NULL"}
+	if len(stmt.Assignments) != len(want) {
+		t.Fatalf("got %d assignments, want %d", len(stmt.Assignments), len(want))
+	}
+	for col, value := range want {
+		if lit := stmt.Assignments[col]; lit == nil || lit.String() != value {
+			t.Errorf("SET %s: got %v, want %s", col, lit, value)
+		}
+	}
+	if got := tree(stmt.Where); got != "((a = 2) OR (b = 'x'))" {
+		t.Errorf("got WHERE %s", got)
+	}
+
+	parseFails(t, "UPDATE t SET a = 1,", "expected column name")
+	parseFails(t, "UPDATE t SET a = 1, a = 2", "column a assigned more than once")
+	parseFails(t, "UPDATE t SET a = 1, b = 2, A = 3", "column A assigned more than once")
+}
+
+func TestParseComparisons(t *testing.T) {
+	tests := []struct {
+		where string
+		want  string
+	}{
+		{"a < 1", "(a < 1)"},
+		{"a > 1", "(a > 1)"},
+		{"a <= 1", "(a <= 1)"},
+		{"a >= -1.5", "(a >= -1.5)"},
+		{"a != 'x'", "(a != 'x')"},
+		{"a <> 'x'", "(a != 'x')"},
+		{"1 < a", "(1 < a)"},
+		{"a > 1 AND b <= 2 OR NOT c <> 3", "(((a > 1) AND (b <= 2)) OR (NOT (c != 3)))"},
+	}
+	for _, test := range tests {
+		stmt := parse(t, "SELECT * FROM t WHERE "+test.where).(*ast.SELECTQueryStatement)
+		if got := tree(stmt.Where); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.where, got, test.want)
+		}
+	}
+}
+
+func TestParseOrderByLimit(t *testing.T) {
+	stmt := parse(t, "SELECT a FROM t WHERE a > 1 ORDER BY a DESC, b, c ASC LIMIT 10 OFFSET 5").(*ast.SELECTQueryStatement)
+	want := []ast.OrderByItem{{Column: "a", Descending: true}, {Column: "b"}, {Column: "c"}}
+	if !reflect.DeepEqual(stmt.OrderBy, want) {
+		t.Errorf("got ORDER BY %+v, want %+v", stmt.OrderBy, want)
+	}
+	if stmt.Limit == nil || *stmt.Limit != 10 || stmt.Offset == nil || *stmt.Offset != 5 {
+		t.Errorf("got LIMIT %v OFFSET %v", stmt.Limit, stmt.Offset)
+	}
+
+	stmt = parse(t, "SELECT a FROM t OFFSET 0").(*ast.SELECTQueryStatement)
+	if stmt.OrderBy != nil || stmt.Limit != nil || stmt.Offset == nil || *stmt.Offset != 0 {
+		t.Errorf("got ORDER BY %v LIMIT %v OFFSET %v", stmt.OrderBy, stmt.Limit, stmt.Offset)
+	}
+
+	for q, message := range map[string]string{
+		"SELECT a FROM t ORDER a":          "expected BY",
+		"SELECT a FROM t ORDER BY":         "expected column name in ORDER BY",
+		"SELECT a FROM t ORDER BY a,":      "expected column name in ORDER BY",
+		"SELECT a FROM t LIMIT x":          "expected row count after LIMIT",
+		"SELECT a FROM t LIMIT -1":         "invalid LIMIT row count",
+		"SELECT a FROM t LIMIT 1 OFFSET":   "expected row count after OFFSET",
+		"SELECT a FROM t LIMIT 1.5":        "invalid LIMIT row count",
+		"SELECT a FROM t OFFSET 1 LIMIT 1": "unexpected",
+	} {
+		parseFails(t, q, message)
+	}
+}
+
+func TestParseInsert(t *testing.T) {
+	stmt := parse(t, "INSERT INTO t (a, b) VALUES (1, 'x'), (2.5, word), (NULL, TRUE)").(*ast.INSERTStatement)
+	if !reflect.DeepEqual(stmt.Columns, []string{"a", "b"}) {
+		t.Errorf("got columns %v", stmt.Columns)
+	}
+	want := [][]string{{"1", "'x'"}, {"2.5", "'word'"}, {"NULL", "TRUE"}}
+	var got [][]string
+	for _, row := range stmt.Rows {
+		var values []string
+		for _, lit := range row {
+			values = append(values, lit.String())
+		}
+		got = append(got, values)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got rows %v, want %v", got, want)
+	}
+
+	stmt = parse(t, "INSERT INTO t SELECT a, b FROM u WHERE a > 1").(*ast.INSERTStatement)
+	if stmt.Select == nil || stmt.Select.Table != "u" || tree(stmt.Select.Where) != "(a > 1)" || stmt.Rows != nil {
+		t.Errorf("got %+v", stmt)
+	}
+
+	for q, message := range map[string]string{
+		"INSERT INTO t VALUES":         "expected (",
+		"INSERT INTO t VALUES (1),":    "expected (",
+		"INSERT INTO t VALUES (1, 2":   "expected )",
+		"INSERT INTO t VALUES (1) (2)": "unexpected",
+		"INSERT INTO t (a, b) SELECT":  "expected field name",
+		"INSERT INTO t VALUES (a = 1)": "expected )",
+	} {
+		parseFails(t, q, message)
+	}
+}
+
+func TestParseLiterals(t *testing.T) {
+	tests := []struct {
+		value string
+		kind  ast.LiteralKind
+		want  interface{}
+	}{
+		{"42", ast.IntegerLiteral, int64(42)},
+		{"-7", ast.IntegerLiteral, int64(-7)},
+		{"2.5", ast.FloatLiteral, 2.5},
+		{"'42'", ast.StringLiteral, "42"},
+		{`"it's"`, ast.StringLiteral, "it's"},
+		{"''", ast.StringLiteral, ""},
+		{"word", ast.StringLiteral, "word"},
+		{"true", ast.BooleanLiteral, true},
+		{"FALSE", ast.BooleanLiteral, false},
+		{"null", ast.NullLiteral, nil},
+	}
+	for _, test := range tests {
+		stmt := parse(t, "INSERT INTO t VALUES ("+test.value+")").(*ast.INSERTStatement)
+		lit := stmt.Rows[0][0]
+		if lit.Kind != test.kind || !reflect.DeepEqual(lit.Value, test.want) {
+			t.Errorf("%s: got %s %#v, want %s %#v", test.value, lit.Kind, lit.Value, test.kind, test.want)
+		}
+	}
+
+	parseFails(t, "INSERT INTO t VALUES (1.2.3)", "invalid number")
+}
+
+func TestParseConstraints(t *testing.T) {
+	stmt, ok := parse(t, `CREATE TABLE users (
+		id INT PRIMARY KEY,
+		email TEXT NOT NULL UNIQUE,
+		name TEXT NULL DEFAULT 'anon',
+		age INT CHECK (age >= 0 AND age < 200) DEFAULT 18,
+		CONSTRAINT one_name UNIQUE (name, email),
+		CHECK (age > 0 OR name = 'root')
+	)`).(*ast.CREATETableStatement)
+	if !ok {
+		t.Fatal("not a CREATE TABLE statement")
+	}
+
+	var columns []string
+	for _, col := range stmt.Columns {
+		columns = append(columns, col.String())
+	}
+	wantColumns := []string{
+		"id INT PRIMARY KEY",
+		"email TEXT UNIQUE NOT NULL",
+		"name TEXT DEFAULT 'anon'",
+		"age INT DEFAULT 18 CHECK (age >= 0 AND age < 200)",
+	}
+	if !reflect.DeepEqual(columns, wantColumns) {
+		t.Errorf("got columns %q, want %q", columns, wantColumns)
+	}
+
+	var constraints []string
+	for _, c := range stmt.Constraints {
+		constraints = append(constraints, c.String())
+	}
+	wantConstraints := []string{
+		"CONSTRAINT one_name UNIQUE (name, email)",
+		"CHECK (age > 0 OR name = 'root')",
+	}
+	if !reflect.DeepEqual(constraints, wantConstraints) {
+		t.Errorf("got constraints %q, want %q", constraints, wantConstraints)
+	}
+
+	stmt = parse(t, "CREATE TABLE pairs (a INT, b INT, PRIMARY KEY (a, b))").(*ast.CREATETableStatement)
+	if len(stmt.Constraints) != 1 || stmt.Constraints[0].Kind != ast.PrimaryKeyConstraint ||
+		!reflect.DeepEqual(stmt.Constraints[0].Columns, []string{"a", "b"}) {
+		t.Errorf("got constraints %v", stmt.Constraints)
+	}
+}
+
+func TestParseConstraintErrors(t *testing.T) {
+	for q, message := range map[string]string{
+		"CREATE TABLE t (a INT PRIMARY)":         "expected KEY",
+		"CREATE TABLE t (a INT NOT)":             "expected NULL",
+		"CREATE TABLE t (a INT DEFAULT)":         "in DEFAULT for column a",
+		"CREATE TABLE t (a INT CHECK a > 0)":     "expected (",
+		"CREATE TABLE t (a INT, CONSTRAINT)":     "expected constraint name",
+		"CREATE TABLE t (a INT, CONSTRAINT c a)": "expected PRIMARY KEY, UNIQUE, CHECK or FOREIGN KEY",
+		"CREATE TABLE t (a INT, UNIQUE a)":       "expected (",
+		"CREATE TABLE t (a BIGNUM)":              "unknown type BIGNUM for column a",
+		"CREATE TABLE t (PRIMARY KEY (a))":       "table t must have at least one column",
+	} {
+		parseFails(t, q, message)
+	}
+}
+
+func TestParseReferences(t *testing.T) {
+	stmt := parse(t, `CREATE TABLE orders (
+		id INT PRIMARY KEY,
+		user_id INT REFERENCES users,
+		item_id INT REFERENCES items (id) ON DELETE CASCADE,
+		parent INT,
+		CONSTRAINT up FOREIGN KEY (parent, user_id) REFERENCES orders (id, user_id) ON DELETE SET NULL
+	)`).(*ast.CREATETableStatement)
+
+	var got []string
+	for _, col := range stmt.Columns {
+		got = append(got, col.String())
+	}
+	for _, c := range stmt.Constraints {
+		got = append(got, c.String())
+	}
+	want := []string{
+		"id INT PRIMARY KEY",
+		"user_id INT REFERENCES users ON DELETE RESTRICT",
+		"item_id INT REFERENCES items (id) ON DELETE CASCADE",
+		"parent INT",
+		"CONSTRAINT up FOREIGN KEY (parent, user_id) REFERENCES orders (id, user_id) ON DELETE SET NULL",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	for q, message := range map[string]string{
+		"CREATE TABLE t (a INT REFERENCES)":                     "expected table name after REFERENCES",
+		"CREATE TABLE t (a INT REFERENCES u ON UPDATE CASCADE)": "expected DELETE",
+		"CREATE TABLE t (a INT REFERENCES u ON DELETE SET a)":   "expected NULL after ON DELETE SET",
+		"CREATE TABLE t (a INT REFERENCES u ON DELETE NOTHING)": "expected CASCADE, SET NULL or RESTRICT after ON DELETE",
+		"CREATE TABLE t (a INT, FOREIGN (a) REFERENCES u)":      "expected KEY",
+		"CREATE TABLE t (a INT, FOREIGN KEY (a) u)":             "expected REFERENCES",
+	} {
+		parseFails(t, q, message)
+	}
+}
+
+func TestParseAlterTable(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"ALTER TABLE t ADD COLUMN c INT DEFAULT 0", "ALTER TABLE t ADD COLUMN c INT DEFAULT 0"},
+		{"alter table t add c TEXT NOT NULL UNIQUE", "ALTER TABLE t ADD COLUMN c TEXT UNIQUE NOT NULL"},
+		{"ALTER TABLE t ADD c REFERENCES u ON DELETE CASCADE", "ALTER TABLE t ADD COLUMN c REFERENCES u ON DELETE CASCADE"},
+		{"ALTER TABLE t DROP COLUMN c", "ALTER TABLE t DROP COLUMN c"},
+		{"ALTER TABLE t DROP c", "ALTER TABLE t DROP COLUMN c"},
+		{"ALTER TABLE t RENAME COLUMN a TO b", "ALTER TABLE t RENAME COLUMN a TO b"},
+		{"ALTER TABLE t RENAME a TO b", "ALTER TABLE t RENAME COLUMN a TO b"},
+		{"ALTER TABLE t RENAME TO u", "ALTER TABLE t RENAME TO u"},
+	}
+	for _, test := range tests {
+		stmt, ok := parse(t, test.query).(*ast.ALTERTableStatement)
+		if !ok {
+			t.Errorf("%s: not an ALTER TABLE statement", test.query)
+			continue
+		}
+		if got := stmt.String(); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.query, got, test.want)
+		}
+	}
+
+	for q, message := range map[string]string{
+		"ALTER t ADD c":              "expected TABLE",
+		"ALTER TABLE":                "expected table name",
+		"ALTER TABLE t MODIFY c INT": "expected ADD, DROP or RENAME after ALTER TABLE t",
+		"ALTER TABLE t ADD COLUMN":   "expected column name",
+		"ALTER TABLE t ADD c BIGNUM": "unknown type BIGNUM for column c",
+		"ALTER TABLE t DROP COLUMN":  "expected column name",
+		"ALTER TABLE t RENAME TO":    "expected new table name",
+		"ALTER TABLE t RENAME a b":   "expected TO",
+		"ALTER TABLE t RENAME a TO":  "expected new column name",
+	} {
+		parseFails(t, q, message)
+	}
+}
+
+func TestParseIntrospection(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"SHOW TABLES", "SHOW TABLES"},
+		{"show tables;", "SHOW TABLES"},
+		{"DESCRIBE users", "DESCRIBE users"},
+		{"DESCRIBE TABLE users", "DESCRIBE users"},
+		{"desc users", "DESCRIBE users"},
+	}
+	for _, test := range tests {
+		if got := parse(t, test.query).String(); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.query, got, test.want)
+		}
+	}
+
+	parseFails(t, "SHOW users", "expected TABLES")
+	parseFails(t, "DESCRIBE", "expected table name")
+}
@@ -0,0 +1,340 @@
+// Package server answers the JSON requests of the /query protocol that
+// db-engine/engine.pl serves, so client.Client can talk to it unchanged.
+// Requests are carried out by any Storage, typically an in-process
+// storage.Engine.
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+	"weird/db/engine/client"
+)
+
+// DefaultPort is the port engine.pl listens on
+const DefaultPort = 8081
+
+// MaxRequestSize is the largest request body the server reads, in bytes
+const MaxRequestSize = 32 << 20
+
+// DefaultTxTimeout is how long a transaction may go without a request
+// before the server rolls it back, as engine.pl's tx_timeout
+const DefaultTxTimeout = 5 * time.Minute
+
+// Storage is what the server needs of a database: one method per request
+// of the protocol. Any client.DbClient is one.
+type Storage interface {
+	CreateTable(table string, columns []client.Column, constraints []client.Constraint) (*client.Response, error)
+	DropTable(table string, ifExists bool) (*client.Response, error)
+	Truncate(table string) (*client.Response, error)
+	AddColumn(table string, column client.Column, constraints []client.Constraint) (*client.Response, error)
+	DropColumn(table string, column string, constraints []client.Constraint) (*client.Response, error)
+	RenameColumn(table string, column string, newName string, constraints []client.Constraint) (*client.Response, error)
+	RenameTable(table string, newName string) (*client.Response, error)
+	CreateIndex(table string, index client.Index) (*client.Response, error)
+	DropIndex(name string, ifExists bool) (*client.Response, error)
+	Schema(table string) (*client.Response, error)
+	ListTables() (*client.Response, error)
+	DescribeTable(table string) (*client.Response, error)
+	References(table string) (*client.Response, error)
+	Insert(table string, values map[string]interface{}) (*client.Response, error)
+	InsertMany(table string, rows []map[string]interface{}) (*client.Response, error)
+	InsertRows(table string, ids []int, rows []map[string]interface{}) (*client.Response, error)
+	Select(table string, where map[string]interface{}) (*client.Response, error)
+	SelectRows(table string, ids []int) (*client.Response, error)
+	Update(table string, set map[string]interface{}, where map[string]interface{}) (*client.Response, error)
+	UpdateRows(table string, ids []int, set map[string]interface{}) (*client.Response, error)
+	Delete(table string, where map[string]interface{}) (*client.Response, error)
+	DeleteRows(table string, ids []int) (*client.Response, error)
+	Begin() (client.Tx, error)
+}
+
+// Request is any request of the protocol; which fields are used depends on
+// Type. Columns of create_table and Column of add_column may be plain
+// strings, as older clients send, or {name, type} objects. Tx names the
+// transaction a request belongs to.
+type Request struct {
+	Type        string                   `json:"type"`
+	Tx          string                   `json:"tx"`
+	Table       string                   `json:"table"`
+	Columns     []json.RawMessage        `json:"columns"`
+	Constraints []client.Constraint      `json:"constraints"`
+	IfExists    bool                     `json:"if_exists"`
+	Column      json.RawMessage          `json:"column"`
+	NewName     string                   `json:"new_name"`
+	Values      map[string]interface{}   `json:"values"`
+	Rows        []map[string]interface{} `json:"rows"`
+	Set         map[string]interface{}   `json:"set"`
+	Where       map[string]interface{}   `json:"where"`
+	IDs         []int                    `json:"ids"`
+	Index       *client.Index            `json:"index"`
+	Name        string                   `json:"name"`
+}
+
+type Server struct {
+	db  Storage
+	mux *http.ServeMux
+	// TxTimeout is how long an open transaction may go without a request
+	// before it is rolled back. Abandoned ones are found when the next
+	// request comes in.
+	TxTimeout time.Duration
+
+	mu sync.Mutex
+	// txs are the open transactions by ID
+	txs    map[string]*openTx
+	nextTx int
+	// now tells the time transactions are last used at
+	now func() time.Time
+}
+
+// openTx is a transaction and the time of its last request
+type openTx struct {
+	client.Tx
+	used time.Time
+}
+
+func New(db Storage) *Server {
+	s := &Server{
+		db:        db,
+		mux:       http.NewServeMux(),
+		TxTimeout: DefaultTxTimeout,
+		txs:       make(map[string]*openTx),
+		now:       time.Now,
+	}
+	s.mux.HandleFunc("/query", s.handleQuery)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// ListenAndServe serves the protocol on addr, e.g. ":8081"
+func (s *Server) ListenAndServe(addr string) error {
+	return http.ListenAndServe(addr, s)
+}
+
+func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req Request
+	var resp *client.Response
+	status := http.StatusOK
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxRequestSize))
+	// Keep integers exact instead of rounding them through float64
+	decoder.UseNumber()
+	if err := decoder.Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+			resp = errorResponse(fmt.Sprintf("Request larger than %d bytes", tooLarge.Limit))
+		} else {
+			resp = errorResponse(fmt.Sprintf("Invalid request: %v", err))
+		}
+	} else {
+		resp = s.Process(req)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Process carries out one request. Failures are reported in the response
+// like engine.pl does, with status "error" and a message.
+func (s *Server) Process(req Request) *client.Response {
+	s.expireTxs()
+
+	var resp *client.Response
+	var err error
+	switch req.Type {
+	case "capabilities":
+		resp = &client.Response{Status: "success", Capabilities: []string{client.TransactionsCapability}}
+	case "begin":
+		resp, err = s.begin()
+	case "commit", "rollback":
+		resp, err = s.endTx(req)
+	default:
+		db := s.db
+		if req.Tx != "" {
+			tx := s.lookupTx(req.Tx)
+			if tx == nil {
+				return errorResponse("Unknown transaction")
+			}
+			db = tx
+		}
+		resp, err = s.dispatch(db, req)
+	}
+	if err != nil {
+		if resp != nil && resp.Status == "error" {
+			return resp
+		}
+		return errorResponse(err.Error())
+	}
+	return resp
+}
+
+func (s *Server) begin() (*client.Response, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextTx++
+	id := fmt.Sprintf("tx%d", s.nextTx)
+	s.txs[id] = &openTx{Tx: tx, used: s.now()}
+	return &client.Response{Status: "success", Message: "Transaction started", Tx: id}, nil
+}
+
+// endTx commits or rolls back a transaction. It ends either way: a commit
+// that failed has nothing left to retry.
+func (s *Server) endTx(req Request) (*client.Response, error) {
+	s.mu.Lock()
+	tx, ok := s.txs[req.Tx]
+	delete(s.txs, req.Tx)
+	s.mu.Unlock()
+
+	if !ok {
+		return errorResponse("Unknown transaction"), fmt.Errorf("unknown transaction %q", req.Tx)
+	}
+	if req.Type == "commit" {
+		return tx.Commit()
+	}
+	return tx.Rollback()
+}
+
+// lookupTx returns the open transaction id, noting that it was used now
+func (s *Server) lookupTx(id string) client.Tx {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	open, ok := s.txs[id]
+	if !ok {
+		return nil
+	}
+	open.used = s.now()
+	return open.Tx
+}
+
+// expireTxs rolls back the transactions that went unused for longer than
+// TxTimeout
+func (s *Server) expireTxs() {
+	s.mu.Lock()
+	var expired []client.Tx
+	for id, open := range s.txs {
+		if s.now().Sub(open.used) > s.TxTimeout {
+			expired = append(expired, open.Tx)
+			delete(s.txs, id)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, tx := range expired {
+		tx.Rollback()
+	}
+}
+
+func (s *Server) dispatch(db Storage, req Request) (*client.Response, error) {
+	switch req.Type {
+	case "create_table":
+		columns := make([]client.Column, len(req.Columns))
+		for i, raw := range req.Columns {
+			col, err := columnSpec(raw)
+			if err != nil {
+				return nil, err
+			}
+			columns[i] = col
+		}
+		return db.CreateTable(req.Table, columns, req.Constraints)
+	case "schema":
+		return db.Schema(req.Table)
+	case "references":
+		return db.References(req.Table)
+	case "list_tables":
+		return db.ListTables()
+	case "describe_table":
+		return db.DescribeTable(req.Table)
+	case "insert":
+		return db.Insert(req.Table, req.Values)
+	case "insert_many":
+		if req.IDs != nil {
+			return db.InsertRows(req.Table, req.IDs, req.Rows)
+		}
+		return db.InsertMany(req.Table, req.Rows)
+	case "select":
+		if req.IDs != nil {
+			return db.SelectRows(req.Table, req.IDs)
+		}
+		return db.Select(req.Table, req.Where)
+	case "update":
+		// An ids list names the rows to touch; an empty one touches none
+		if req.IDs != nil {
+			return db.UpdateRows(req.Table, req.IDs, req.Set)
+		}
+		return db.Update(req.Table, req.Set, req.Where)
+	case "delete":
+		if req.IDs != nil {
+			return db.DeleteRows(req.Table, req.IDs)
+		}
+		return db.Delete(req.Table, req.Where)
+	case "drop_table":
+		return db.DropTable(req.Table, req.IfExists)
+	case "truncate":
+		return db.Truncate(req.Table)
+	case "add_column":
+		col, err := columnSpec(req.Column)
+		if err != nil {
+			return nil, err
+		}
+		return db.AddColumn(req.Table, col, req.Constraints)
+	case "drop_column":
+		col, err := columnSpec(req.Column)
+		if err != nil {
+			return nil, err
+		}
+		return db.DropColumn(req.Table, col.Name, req.Constraints)
+	case "rename_column":
+		col, err := columnSpec(req.Column)
+		if err != nil {
+			return nil, err
+		}
+		return db.RenameColumn(req.Table, col.Name, req.NewName, req.Constraints)
+	case "rename_table":
+		return db.RenameTable(req.Table, req.NewName)
+	case "create_index":
+		if req.Index == nil {
+			return nil, fmt.Errorf("Invalid index: missing")
+		}
+		return db.CreateIndex(req.Table, *req.Index)
+	case "drop_index":
+		return db.DropIndex(req.Name, req.IfExists)
+	default:
+		return errorResponse("Unknown query type"), fmt.Errorf("unknown query type %q", req.Type)
+	}
+}
+
+// columnSpec decodes a column given either as its name or as a
+// {name, type} object
+func columnSpec(raw json.RawMessage) (client.Column, error) {
+	var name string
+	if err := json.Unmarshal(raw, &name); err == nil {
+		return client.Column{Name: name}, nil
+	}
+	var col client.Column
+	if err := json.Unmarshal(raw, &col); err != nil {
+		return client.Column{}, fmt.Errorf("Invalid column: %s", raw)
+	}
+	return col, nil
+}
+
+func errorResponse(message string) *client.Response {
+	return &client.Response{Status: "error", Message: message}
+}
@@ -0,0 +1,223 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"weird/db/engine/client"
+	"weird/db/engine/storage"
+)
+
+// post sends body to the /query handler of s and decodes the response
+func post(t *testing.T, s *Server, body string) (int, *client.Response) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(body)))
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("got content type %q", ct)
+	}
+	var resp client.Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding %s: %v", rec.Body, err)
+	}
+	return rec.Code, &resp
+}
+
+// mustPost is post for a request that has to succeed
+func mustPost(t *testing.T, s *Server, body string) *client.Response {
+	t.Helper()
+	code, resp := post(t, s, body)
+	if code != http.StatusOK || resp.Status != "success" {
+		t.Fatalf("%s: got %d %+v", body, code, resp)
+	}
+	return resp
+}
+
+func newServer(t *testing.T) *Server {
+	t.Helper()
+	s := New(storage.NewEngine())
+	mustPost(t, s, `{"type":"create_table","table":"users","columns":["name",{"name":"age","type":"INT"}]}`)
+	return s
+}
+
+func TestQuery(t *testing.T) {
+	s := newServer(t)
+	mustPost(t, s, `{"type":"insert_many","table":"users","rows":[{"name":"ann","age":30},{"name":"bob","age":9007199254740993}]}`)
+
+	resp := mustPost(t, s, `{"type":"select","table":"users","where":{"age":{"op":">","value":40}}}`)
+	if len(resp.Rows) != 1 || resp.Rows[0].ID != 2 {
+		t.Fatalf("got rows %+v", resp.Rows)
+	}
+	// Integers beyond float64's precision come back exact
+	if got := resp.Rows[0].Data[1]; got != int64(9007199254740993) {
+		t.Errorf("got age %#v", got)
+	}
+
+	resp = mustPost(t, s, `{"type":"update","table":"users","ids":[],"set":{"age":1}}`)
+	if resp.Count != 0 {
+		t.Errorf("update of no ids: got count %d", resp.Count)
+	}
+	mustPost(t, s, `{"type":"delete","table":"users","where":{"name":"ann"}}`)
+	// ids put a row back where it was
+	resp = mustPost(t, s, `{"type":"insert_many","table":"users","rows":[{"name":"ann"}],"ids":[1]}`)
+	if !reflect.DeepEqual(resp.IDs, []int{1}) {
+		t.Errorf("insert under ids: got ids %v", resp.IDs)
+	}
+	resp = mustPost(t, s, `{"type":"select","table":"users","ids":[1,5]}`)
+	if len(resp.Rows) != 1 || resp.Rows[0].Data[0] != "ann" {
+		t.Errorf("select by ids: got rows %+v", resp.Rows)
+	}
+	resp = mustPost(t, s, `{"type":"schema","table":"users"}`)
+	if !reflect.DeepEqual(resp.Columns, []string{"name", "age"}) || !reflect.DeepEqual(resp.Types, []string{"", "INT"}) {
+		t.Errorf("got schema %+v", resp)
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	s := newServer(t)
+	tests := []struct {
+		name    string
+		body    string
+		code    int
+		message string
+	}{
+		{"unknown type", `{"type":"vacuum"}`, http.StatusOK, "Unknown query type"},
+		{"storage error", `{"type":"insert","table":"missing","values":{}}`, http.StatusOK, "Table does not exist"},
+		{"bad column", `{"type":"add_column","table":"users","column":7}`, http.StatusOK, "Invalid column: 7"},
+		{"missing index", `{"type":"create_index","table":"users"}`, http.StatusOK, "Invalid index: missing"},
+		{"repeated id", `{"type":"insert_many","table":"users","rows":[{},{}],"ids":[1,1]}`, http.StatusOK, "Record already exists"},
+		{"unknown tx", `{"type":"select","table":"users","tx":"tx9"}`, http.StatusOK, "Unknown transaction"},
+		{"invalid json", `{"type":`, http.StatusOK, "Invalid request: unexpected EOF"},
+		{"too large", `{"type":"insert","table":"users","values":{"name":"` + strings.Repeat("x", MaxRequestSize) + `"}}`,
+			http.StatusRequestEntityTooLarge, "Request larger than 33554432 bytes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, resp := post(t, s, tt.body)
+			if code != tt.code || resp.Status != "error" || resp.Message != tt.message {
+				t.Errorf("got %d %+v, want %d %q", code, resp, tt.code, tt.message)
+			}
+		})
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	rec := httptest.NewRecorder()
+	New(storage.NewEngine()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/query", nil))
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != http.MethodPost {
+		t.Errorf("got %d, Allow %q", rec.Code, rec.Header().Get("Allow"))
+	}
+}
+
+func TestTransactions(t *testing.T) {
+	s := newServer(t)
+
+	tx := mustPost(t, s, `{"type":"begin"}`).Tx
+	if tx == "" {
+		t.Fatal("begin returned no transaction")
+	}
+	mustPost(t, s, `{"type":"insert","table":"users","tx":"`+tx+`","values":{"name":"ann"}}`)
+	if resp := mustPost(t, s, `{"type":"select","table":"users","tx":"`+tx+`"}`); len(resp.Rows) != 1 {
+		t.Errorf("in the transaction: got %d rows", len(resp.Rows))
+	}
+	if resp := mustPost(t, s, `{"type":"select","table":"users"}`); len(resp.Rows) != 0 {
+		t.Errorf("outside the transaction: got %d rows", len(resp.Rows))
+	}
+	mustPost(t, s, `{"type":"commit","tx":"`+tx+`"}`)
+	if resp := mustPost(t, s, `{"type":"select","table":"users"}`); len(resp.Rows) != 1 {
+		t.Errorf("after commit: got %d rows", len(resp.Rows))
+	}
+
+	// A transaction ends with its commit or rollback
+	if _, resp := post(t, s, `{"type":"rollback","tx":"`+tx+`"}`); resp.Message != "Unknown transaction" {
+		t.Errorf("rollback after commit: got %+v", resp)
+	}
+
+	tx = mustPost(t, s, `{"type":"begin"}`).Tx
+	mustPost(t, s, `{"type":"delete","table":"users","tx":"`+tx+`"}`)
+	mustPost(t, s, `{"type":"rollback","tx":"`+tx+`"}`)
+	if resp := mustPost(t, s, `{"type":"select","table":"users"}`); len(resp.Rows) != 1 {
+		t.Errorf("after rollback: got %d rows", len(resp.Rows))
+	}
+}
+
+func TestTxTimeout(t *testing.T) {
+	s := newServer(t)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	idle := mustPost(t, s, `{"type":"begin"}`).Tx
+	mustPost(t, s, `{"type":"insert","table":"users","tx":"`+idle+`","values":{"name":"ann"}}`)
+	busy := mustPost(t, s, `{"type":"begin"}`).Tx
+
+	now = now.Add(DefaultTxTimeout / 2)
+	mustPost(t, s, `{"type":"select","table":"users","tx":"`+busy+`"}`)
+	now = now.Add(DefaultTxTimeout/2 + time.Second)
+
+	// The idle transaction was rolled back; the one used since is open
+	if _, resp := post(t, s, `{"type":"commit","tx":"`+idle+`"}`); resp.Message != "Unknown transaction" {
+		t.Errorf("commit after the timeout: got %+v", resp)
+	}
+	if resp := mustPost(t, s, `{"type":"select","table":"users"}`); len(resp.Rows) != 0 {
+		t.Errorf("got %d rows of an expired transaction", len(resp.Rows))
+	}
+	mustPost(t, s, `{"type":"commit","tx":"`+busy+`"}`)
+}
+
+func TestCapabilities(t *testing.T) {
+	resp := mustPost(t, newServer(t), `{"type":"capabilities"}`)
+	if !reflect.DeepEqual(resp.Capabilities, []string{client.TransactionsCapability}) {
+		t.Errorf("got capabilities %v", resp.Capabilities)
+	}
+
+	// A server without the request has no transactions
+	old := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(errorResponse("Unknown query type"))
+	}))
+	defer old.Close()
+	if _, err := client.NewClient(old.URL).Begin(); err != client.ErrTxUnsupported {
+		t.Errorf("begin on an older server: got %v", err)
+	}
+}
+
+func TestClient(t *testing.T) {
+	ts := httptest.NewServer(New(storage.NewEngine()))
+	defer ts.Close()
+
+	c := client.NewClient(ts.URL)
+	if _, err := c.CreateTable("t", []client.Column{{Name: "x", Type: "FLOAT"}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Insert("t", map[string]interface{}{"x": 1.5}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.SelectAll("t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Rows) != 1 || resp.Rows[0].Data[0] != 1.5 {
+		t.Errorf("got rows %+v", resp.Rows)
+	}
+
+	// Requests made through a Tx name it
+	tx, err := c.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.DeleteAll("t"); err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := c.SelectAll("t"); err != nil || len(resp.Rows) != 1 {
+		t.Errorf("outside the transaction: got %+v (%v)", resp, err)
+	}
+	if _, err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := c.SelectAll("t"); err != nil || len(resp.Rows) != 0 {
+		t.Errorf("after commit: got %+v (%v)", resp, err)
+	}
+}
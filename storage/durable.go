@@ -0,0 +1,324 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"weird/db/engine/dbfile"
+)
+
+// SyncPolicy decides when the write-ahead log is flushed to disk
+type SyncPolicy int
+
+const (
+	// SyncAlways flushes the log before a change is acknowledged
+	SyncAlways SyncPolicy = iota
+	// SyncInterval flushes it every Options.SyncInterval; a crash loses
+	// at most the changes of the last interval
+	SyncInterval
+	// SyncNever leaves flushing to the operating system
+	SyncNever
+)
+
+func (p SyncPolicy) String() string {
+	switch p {
+	case SyncAlways:
+		return "always"
+	case SyncInterval:
+		return "interval"
+	case SyncNever:
+		return "never"
+	default:
+		return fmt.Sprintf("SyncPolicy(%d)", int(p))
+	}
+}
+
+// ParseSyncPolicy reads a policy written as "always", "interval" or "never"
+func ParseSyncPolicy(s string) (SyncPolicy, error) {
+	for _, p := range []SyncPolicy{SyncAlways, SyncInterval, SyncNever} {
+		if strings.EqualFold(s, p.String()) {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown sync policy %q", s)
+}
+
+const (
+	DefaultSyncInterval   = time.Second
+	DefaultCheckpointSize = 16 << 20
+)
+
+// Options configure a durable Engine. The zero value flushes every change
+// and checkpoints whenever the log grows past DefaultCheckpointSize.
+type Options struct {
+	Sync SyncPolicy
+	// SyncInterval is the flush period of SyncInterval, DefaultSyncInterval
+	// if zero
+	SyncInterval time.Duration
+	// CheckpointSize is the log size in bytes that triggers a checkpoint,
+	// DefaultCheckpointSize if zero; a negative size disables the trigger
+	CheckpointSize int64
+	// CheckpointInterval, if set, also checkpoints periodically
+	CheckpointInterval time.Duration
+}
+
+// Layout of a durable Engine's directory
+const (
+	walFile        = "wal.log"
+	snapshotDir    = "snapshot"
+	checkpointFile = "checkpoint"
+)
+
+// OpenDurable opens the Engine kept in dir, creating it if needed. Changes
+// are appended to a write-ahead log, and checkpoints write every table to
+// a snapshot directory in the Prolog server's file format and empty the
+// log. On start the snapshot is loaded and the log replayed on top of it.
+// A directory written by engine.pl, without a snapshot, is imported.
+func OpenDurable(dir string, opts Options) (*Engine, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	snapshot := filepath.Join(dir, snapshotDir)
+	if err := recoverSnapshot(snapshot); err != nil {
+		return nil, err
+	}
+
+	e := NewEngine()
+	var lsn uint64
+	source := dir
+	if _, err := os.Stat(snapshot); err == nil {
+		source = snapshot
+		if lsn, err = readCheckpoint(snapshot); err != nil {
+			return nil, err
+		}
+	}
+	tables, err := dbfile.ReadDir(source)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tables {
+		e.tables[t.Name] = tableFrom(t)
+	}
+
+	path := filepath.Join(dir, walFile)
+	records, end, err := readLog(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, rec := range records {
+		if rec.LSN <= lsn {
+			// Already part of the snapshot
+			continue
+		}
+		if err := e.apply(rec); err != nil {
+			return nil, fmt.Errorf("replaying log record %d: %w", rec.LSN, err)
+		}
+		lsn = rec.LSN
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	// Drop a record a crash cut short, so new ones follow intact ones
+	if err := file.Truncate(end); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	e.wal = &wal{
+		file:           file,
+		size:           end,
+		lsn:            lsn,
+		policy:         opts.Sync,
+		checkpointSize: opts.CheckpointSize,
+	}
+	if e.wal.checkpointSize == 0 {
+		e.wal.checkpointSize = DefaultCheckpointSize
+	}
+
+	// Start from a fresh snapshot and an empty log
+	if err := e.Checkpoint(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	syncEvery := time.Duration(0)
+	if opts.Sync == SyncInterval {
+		syncEvery = opts.SyncInterval
+		if syncEvery <= 0 {
+			syncEvery = DefaultSyncInterval
+		}
+	}
+	if syncEvery > 0 || opts.CheckpointInterval > 0 {
+		e.wal.stop = make(chan struct{})
+		e.wal.done = make(chan struct{})
+		go e.background(syncEvery, opts.CheckpointInterval)
+	}
+	return e, nil
+}
+
+// Checkpoint writes every table to the snapshot directory and empties the
+// write-ahead log. It does nothing for an in-memory Engine.
+func (e *Engine) Checkpoint() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.wal == nil {
+		return nil
+	}
+	return e.checkpointLocked()
+}
+
+// checkpointLocked replaces the snapshot as a whole: the new one is
+// written next to it and renamed into place, so a crash leaves either the
+// old snapshot and its log or the new one
+func (e *Engine) checkpointLocked() error {
+	if err := e.wal.sync(); err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(e.wal.file.Name())
+	snapshot := filepath.Join(dir, snapshotDir)
+	tmp := snapshot + ".tmp"
+	old := snapshot + ".old"
+
+	if err := os.RemoveAll(tmp); err != nil {
+		return err
+	}
+	if err := os.Mkdir(tmp, 0755); err != nil {
+		return err
+	}
+	for _, t := range e.snapshot() {
+		if err := dbfile.SaveTable(tmp, t); err != nil {
+			return err
+		}
+	}
+	if err := writeCheckpoint(tmp, e.wal.lsn); err != nil {
+		return err
+	}
+	if err := syncDir(tmp); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(snapshot); err == nil {
+		if err := os.Rename(snapshot, old); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(tmp, snapshot); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(old); err != nil {
+		return err
+	}
+	if err := syncDir(dir); err != nil {
+		return err
+	}
+	return e.wal.reset()
+}
+
+// background flushes the log and takes checkpoints on their schedules
+// until the Engine is closed. A zero period disables its task.
+func (e *Engine) background(syncEvery, checkpointEvery time.Duration) {
+	defer close(e.wal.done)
+
+	var syncTick, checkpointTick <-chan time.Time
+	if syncEvery > 0 {
+		ticker := time.NewTicker(syncEvery)
+		defer ticker.Stop()
+		syncTick = ticker.C
+	}
+	if checkpointEvery > 0 {
+		ticker := time.NewTicker(checkpointEvery)
+		defer ticker.Stop()
+		checkpointTick = ticker.C
+	}
+
+	for {
+		select {
+		case <-e.wal.stop:
+			return
+		case <-syncTick:
+			e.mu.Lock()
+			e.wal.sync()
+			e.mu.Unlock()
+		case <-checkpointTick:
+			e.Checkpoint()
+		}
+	}
+}
+
+func (e *Engine) closeDurable() error {
+	if e.wal.stop != nil {
+		close(e.wal.stop)
+		<-e.wal.done
+		e.wal.stop = nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.wal.file == nil {
+		return nil
+	}
+	err := e.checkpointLocked()
+	if cerr := e.wal.file.Close(); err == nil {
+		err = cerr
+	}
+	e.wal.file = nil
+	e.wal.err = fmt.Errorf("write-ahead log: engine is closed")
+	return err
+}
+
+// recoverSnapshot finishes or undoes a checkpoint a crash interrupted
+func recoverSnapshot(snapshot string) error {
+	old := snapshot + ".old"
+	if _, err := os.Stat(snapshot); os.IsNotExist(err) {
+		if _, err := os.Stat(old); err == nil {
+			if err := os.Rename(old, snapshot); err != nil {
+				return err
+			}
+		}
+	}
+	if err := os.RemoveAll(old); err != nil {
+		return err
+	}
+	return os.RemoveAll(snapshot + ".tmp")
+}
+
+func readCheckpoint(snapshot string) (uint64, error) {
+	data, err := os.ReadFile(filepath.Join(snapshot, checkpointFile))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+func writeCheckpoint(snapshot string, lsn uint64) error {
+	f, err := os.Create(filepath.Join(snapshot, checkpointFile))
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(f, "%d\n", lsn); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func syncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
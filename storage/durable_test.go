@@ -0,0 +1,252 @@
+package storage
+
+import (
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"weird/db/engine/client"
+)
+
+// openDurable opens the Engine kept in dir; it is closed with the test
+func openDurable(t *testing.T, dir string, opts Options) *Engine {
+	t.Helper()
+	e, err := OpenDurable(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { e.Close() })
+	return e
+}
+
+// crash returns a copy of the files of a durable Engine as they are now,
+// what a machine that lost power at this point would find on restart
+func crash(t *testing.T, dir string) string {
+	t.Helper()
+	copied := t.TempDir()
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		target := filepath.Join(copied, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, 0644)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return copied
+}
+
+// rows returns the data of every row of a table, by ID
+func rows(t *testing.T, e *Engine, name string) map[int][]interface{} {
+	t.Helper()
+	data := make(map[int][]interface{})
+	for _, row := range mustSucceed(t)(e.SelectAll(name)).Rows {
+		data[row.ID] = row.Data
+	}
+	return data
+}
+
+// fill makes the same changes on a new table of any Engine
+func fill(t *testing.T, e *Engine) {
+	t.Helper()
+	mustSucceed(t)(e.CreateTable("users", []client.Column{
+		{Name: "name", Type: "TEXT"},
+		{Name: "score", Type: "FLOAT"},
+	}, nil))
+	mustSucceed(t)(e.CreateIndex("users", client.Index{Name: "users_name", Columns: []string{"name"}, Unique: true}))
+	mustSucceed(t)(e.InsertMany("users", []map[string]interface{}{
+		{"name": "ann", "score": 1.5},
+		{"name": "bob", "score": int64(1) << 60},
+		{"name": "cid"},
+	}))
+	mustSucceed(t)(e.Update("users", map[string]interface{}{"score": 2}, map[string]interface{}{"name": "cid"}))
+	mustSucceed(t)(e.DeleteRows("users", []int{1}))
+	mustSucceed(t)(e.Insert("users", map[string]interface{}{"name": "dee"}))
+	mustSucceed(t)(e.AddColumn("users", client.Column{Name: "active", Type: "BOOL"}, nil))
+}
+
+func TestReplay(t *testing.T) {
+	dir := t.TempDir()
+	e := openDurable(t, dir, Options{CheckpointSize: -1})
+	fill(t, e)
+	tx, err := e.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustSucceed(t)(tx.Insert("users", map[string]interface{}{"name": "eve"}))
+	mustSucceed(t)(tx.Commit())
+	// Never committed, so never logged
+	tx, _ = e.Begin()
+	mustSucceed(t)(tx.DeleteAll("users"))
+
+	want := rows(t, e, "users")
+	recovered := openDurable(t, crash(t, dir), Options{})
+	if got := rows(t, recovered, "users"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	// The index came back with the table
+	mustFail(t, "Duplicate key for unique index")(recovered.Insert("users", map[string]interface{}{"name": "eve"}))
+}
+
+func TestCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	e := openDurable(t, dir, Options{CheckpointSize: 200})
+	fill(t, e)
+
+	info, err := os.Stat(filepath.Join(dir, walFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() >= 200 {
+		t.Errorf("log is %d bytes after checkpoints", info.Size())
+	}
+	if _, err := os.Stat(filepath.Join(dir, snapshotDir, "users_data.pl")); err != nil {
+		t.Errorf("no snapshot of users: %v", err)
+	}
+
+	want := rows(t, e, "users")
+	recovered := openDurable(t, crash(t, dir), Options{})
+	if got := rows(t, recovered, "users"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestCheckpointInterrupted(t *testing.T) {
+	dir := t.TempDir()
+	e := openDurable(t, dir, Options{CheckpointSize: -1})
+	fill(t, e)
+	want := rows(t, e, "users")
+
+	// A checkpoint that got as far as moving the old snapshot aside, with
+	// a half written new one
+	copied := crash(t, dir)
+	snapshot := filepath.Join(copied, snapshotDir)
+	if err := os.Rename(snapshot, snapshot+".old"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(snapshot+".tmp", 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	recovered := openDurable(t, copied, Options{})
+	if got := rows(t, recovered, "users"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestTornRecord(t *testing.T) {
+	dir := t.TempDir()
+	e := openDurable(t, dir, Options{CheckpointSize: -1})
+	fill(t, e)
+	want := rows(t, e, "users")
+
+	copied := crash(t, dir)
+	appendLog(t, copied, `0badf00d {"lsn":99,"type":"delete","table":"us`)
+	recovered := openDurable(t, copied, Options{CheckpointSize: -1})
+	if got := rows(t, recovered, "users"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Records written after recovery follow the intact ones
+	mustSucceed(t)(recovered.Insert("users", map[string]interface{}{"name": "fay"}))
+	want = rows(t, recovered, "users")
+	again := openDurable(t, crash(t, copied), Options{})
+	if got := rows(t, again, "users"); !reflect.DeepEqual(got, want) {
+		t.Errorf("after a second restart: got %v, want %v", got, want)
+	}
+}
+
+func TestDamagedLog(t *testing.T) {
+	dir := t.TempDir()
+	e := openDurable(t, dir, Options{CheckpointSize: -1})
+	fill(t, e)
+
+	copied := crash(t, dir)
+	path := filepath.Join(copied, walFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Change the insert of ann, with more records after it
+	lines := strings.SplitAfter(string(data), "\n")
+	for i, line := range lines {
+		if strings.Contains(line, `"ann"`) {
+			lines[i] = strings.Replace(line, `"ann"`, `"ANN"`, 1)
+			break
+		}
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := OpenDurable(copied, Options{}); err == nil || !strings.Contains(err.Error(), "damaged record") {
+		t.Fatalf("got %v, want a damaged record", err)
+	}
+	// The records after the damaged one are still there
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(after) != strings.Join(lines, "") {
+		t.Error("recovery changed a damaged log")
+	}
+}
+
+func TestFailedLogLeavesTablesUnchanged(t *testing.T) {
+	dir := t.TempDir()
+	e := openDurable(t, dir, Options{CheckpointSize: -1})
+	fill(t, e)
+	want := rows(t, e, "users")
+
+	// Writes to a closed file fail
+	e.wal.file.Close()
+	if _, err := e.Insert("users", map[string]interface{}{"name": "gus"}); err == nil {
+		t.Fatal("insert succeeded without its log record")
+	}
+	if got := rows(t, e, "users"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	// The log refuses anything after a failed write
+	e.wal.file, _ = os.OpenFile(filepath.Join(dir, walFile), os.O_RDWR|os.O_APPEND, 0644)
+	if _, err := e.DeleteAll("users"); err == nil {
+		t.Error("delete succeeded after a failed log write")
+	}
+	if got := rows(t, e, "users"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestNonFiniteValues(t *testing.T) {
+	e := openDurable(t, t.TempDir(), Options{})
+	fill(t, e)
+	for _, f := range []float64{math.NaN(), math.Inf(1)} {
+		mustFail(t, "Invalid values for table schema")(e.Insert("users", map[string]interface{}{"score": f}))
+		mustFail(t, "Invalid values for table schema")(e.Update("users", map[string]interface{}{"score": f}, nil))
+	}
+	// The log still takes changes
+	mustSucceed(t)(e.Insert("users", map[string]interface{}{"score": 1}))
+}
+
+func appendLog(t *testing.T, dir, text string) {
+	t.Helper()
+	f, err := os.OpenFile(filepath.Join(dir, walFile), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := io.WriteString(f, text); err != nil {
+		t.Fatal(err)
+	}
+}
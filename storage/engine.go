@@ -0,0 +1,877 @@
+// Package storage is an in-process implementation of client.DbClient. It
+// keeps every table in memory and follows the semantics of the Prolog
+// server in db-engine/engine.pl: IDs are one more than the highest existing
+// ID, WHERE maps match the same way, and responses carry the same messages
+// and counts. An Executor built on an Engine needs no server at all.
+// Unlike engine.pl an Engine runs any number of transactions at once, each
+// on a snapshot of its own; see Tx. It also keeps the secondary indexes
+// that engine.pl only records, and answers lookups through them.
+package storage
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+	"weird/db/engine/client"
+)
+
+type table struct {
+	name        string
+	columns     []string
+	types       []string
+	constraints []client.Constraint
+	rows        []client.Row
+	// positions maps row IDs to their place in rows
+	positions map[int]int
+	// lastID is the highest ID in rows, 0 when there are none
+	lastID  int
+	indexes []*index
+	// shared is set while a snapshot holds the table: writers change a
+	// copy instead. It is cleared when the last transaction ends. Snapshots
+	// read it under locks of their own, so it is atomic.
+	shared atomic.Bool
+}
+
+// Engine holds a set of tables in memory. It is safe for concurrent use.
+// An Engine made by OpenDurable also logs every change to disk.
+type Engine struct {
+	mu     sync.Mutex
+	tables map[string]*table
+	// wal is nil for a purely in-memory Engine
+	wal *wal
+	// version counts the changes made to the tables
+	version uint64
+	// txs are the open transactions
+	txs map[*Tx]bool
+	// history holds what each change made while transactions are open
+	// wrote, to check them for conflicts on commit
+	history []change
+	// ids hands out row IDs while transactions are open; a transaction's
+	// copy shares it with the Engine
+	ids *sequences
+	// staged collects the changes made to a transaction's private copy
+	staged *[]record
+	// ended is set on the private copy once its transaction is over
+	ended bool
+}
+
+var _ client.DbClient = (*Engine)(nil)
+
+func NewEngine() *Engine {
+	return &Engine{
+		tables: make(map[string]*table),
+		txs:    make(map[*Tx]bool),
+		ids:    &sequences{},
+	}
+}
+
+// failure builds an error response the way client.Client reports a
+// request the server rejected
+func failure(message string) (*client.Response, error) {
+	return &client.Response{Status: "error", Message: message}, fmt.Errorf("query failed: %s", message)
+}
+
+func success(message string) *client.Response {
+	return &client.Response{Status: "success", Message: message}
+}
+
+func (e *Engine) CreateTable(name string, columns []client.Column, constraints []client.Constraint) (*client.Response, error) {
+	if resp, err := e.lockForWrite(); err != nil {
+		return resp, err
+	}
+	defer e.unlock()
+
+	if _, ok := e.tables[name]; ok {
+		return failure("Table already exists")
+	}
+	t := &table{
+		name:        name,
+		columns:     make([]string, len(columns)),
+		types:       make([]string, len(columns)),
+		constraints: copyConstraints(constraints),
+	}
+	for i, col := range columns {
+		t.columns[i] = col.Name
+		t.types[i] = col.Type
+	}
+	if err := e.log(&record{Type: "create_table", Table: name, Columns: columns, Constraints: constraints}); err != nil {
+		return nil, err
+	}
+	e.tables[name] = t
+
+	resp := success("Table created")
+	resp.Table = name
+	return resp, nil
+}
+
+func (e *Engine) DropTable(name string, ifExists bool) (*client.Response, error) {
+	if resp, err := e.lockForWrite(); err != nil {
+		return resp, err
+	}
+	defer e.unlock()
+
+	if _, ok := e.tables[name]; !ok {
+		if ifExists {
+			resp := success("Table does not exist, skipped")
+			resp.Table = name
+			return resp, nil
+		}
+		return failure("Table does not exist")
+	}
+	if err := e.log(&record{Type: "drop_table", Table: name, IfExists: ifExists}); err != nil {
+		return nil, err
+	}
+	delete(e.tables, name)
+
+	resp := success("Table dropped")
+	resp.Table = name
+	return resp, nil
+}
+
+func (e *Engine) Truncate(name string) (*client.Response, error) {
+	if resp, err := e.lockForWrite(); err != nil {
+		return resp, err
+	}
+	defer e.unlock()
+
+	if _, ok := e.tables[name]; !ok {
+		return failure("Table does not exist")
+	}
+	if err := e.log(&record{Type: "truncate", Table: name}); err != nil {
+		return nil, err
+	}
+
+	t, _ := e.writable(name)
+	count := len(t.rows)
+	t.rows = nil
+	t.positions = nil
+	t.lastID = 0
+	for i, ix := range t.indexes {
+		t.indexes[i] = newIndex(ix.Index, t)
+	}
+
+	resp := success("Table truncated")
+	resp.Table = name
+	resp.Count = count
+	return resp, nil
+}
+
+// AddColumn appends a column, filling existing rows with its DEFAULT value
+// from constraints or NULL
+func (e *Engine) AddColumn(name string, column client.Column, constraints []client.Constraint) (*client.Response, error) {
+	if resp, err := e.lockForWrite(); err != nil {
+		return resp, err
+	}
+	defer e.unlock()
+
+	t, ok := e.tables[name]
+	if !ok {
+		return failure("Table does not exist")
+	}
+	if t.columnIndex(column.Name) >= 0 {
+		return failure("Column already exists")
+	}
+	if err := e.log(&record{Type: "add_column", Table: name, Column: &column, Constraints: constraints}); err != nil {
+		return nil, err
+	}
+
+	t, _ = e.writable(name)
+
+	def := client.NormalizeValue(columnDefault(constraints, column.Name), column.Type)
+	for i := range t.rows {
+		t.rows[i].Data = append(t.rows[i].Data, def)
+	}
+	t.columns = append(t.columns, column.Name)
+	t.types = append(t.types, column.Type)
+	t.constraints = copyConstraints(constraints)
+
+	resp := success("Column added")
+	resp.Table = name
+	resp.Count = len(t.rows)
+	return resp, nil
+}
+
+func (e *Engine) DropColumn(name string, column string, constraints []client.Constraint) (*client.Response, error) {
+	if resp, err := e.lockForWrite(); err != nil {
+		return resp, err
+	}
+	defer e.unlock()
+
+	t, ok := e.tables[name]
+	if !ok || t.columnIndex(column) < 0 {
+		return failure("Table or column does not exist")
+	}
+	if err := e.log(&record{Type: "drop_column", Table: name, Column: &client.Column{Name: column}, Constraints: constraints}); err != nil {
+		return nil, err
+	}
+
+	t, _ = e.writable(name)
+	idx := t.columnIndex(column)
+	for i := range t.rows {
+		t.rows[i].Data = removeAt(t.rows[i].Data, idx)
+	}
+	// Indexes on the column go with it
+	var kept []*index
+	for _, ix := range t.indexes {
+		if !contains(ix.Columns, column) {
+			kept = append(kept, ix)
+		}
+	}
+	t.indexes = kept
+	t.columns = removeAt(t.columns, idx)
+	t.types = removeAt(t.types, idx)
+	t.constraints = copyConstraints(constraints)
+
+	resp := success("Column dropped")
+	resp.Table = name
+	resp.Count = len(t.rows)
+	return resp, nil
+}
+
+// RenameColumn renames a column. Rows are positional and keep their data;
+// foreign keys of any table that reference the column are updated.
+func (e *Engine) RenameColumn(name string, column string, newName string, constraints []client.Constraint) (*client.Response, error) {
+	if resp, err := e.lockForWrite(); err != nil {
+		return resp, err
+	}
+	defer e.unlock()
+
+	t, ok := e.tables[name]
+	if !ok || t.columnIndex(column) < 0 {
+		return failure("Table or column does not exist")
+	}
+	idx := t.columnIndex(column)
+	if other := t.columnIndex(newName); other >= 0 && other != idx {
+		return failure("Column already exists")
+	}
+	if err := e.log(&record{Type: "rename_column", Table: name, Column: &client.Column{Name: column}, NewName: newName, Constraints: constraints}); err != nil {
+		return nil, err
+	}
+
+	t, _ = e.writable(name)
+	t.columns[idx] = newName
+	for _, ix := range t.indexes {
+		for i, col := range ix.Columns {
+			if col == column {
+				ix.Columns[i] = newName
+			}
+		}
+	}
+	t.constraints = copyConstraints(constraints)
+	e.renameReferences(name, name, column, newName)
+
+	resp := success("Column renamed")
+	resp.Table = name
+	return resp, nil
+}
+
+// RenameTable renames a table along with the foreign keys that reference it
+func (e *Engine) RenameTable(name string, newName string) (*client.Response, error) {
+	if resp, err := e.lockForWrite(); err != nil {
+		return resp, err
+	}
+	defer e.unlock()
+
+	t, ok := e.tables[name]
+	if !ok {
+		return failure("Table does not exist")
+	}
+	if _, exists := e.tables[newName]; exists {
+		return failure("Table already exists")
+	}
+	if err := e.log(&record{Type: "rename_table", Table: name, NewName: newName}); err != nil {
+		return nil, err
+	}
+
+	t, _ = e.writable(name)
+	delete(e.tables, name)
+	t.name = newName
+	e.tables[newName] = t
+	e.renameReferences(name, newName, "", "")
+
+	resp := success("Table renamed")
+	resp.Table = newName
+	return resp, nil
+}
+
+func (e *Engine) Schema(name string) (*client.Response, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	t, ok := e.tables[name]
+	if !ok {
+		return failure("Table does not exist")
+	}
+	return &client.Response{
+		Status:      "success",
+		Table:       name,
+		Columns:     copyStrings(t.columns),
+		Types:       copyStrings(t.types),
+		Constraints: copyConstraints(t.constraints),
+		Indexes:     t.indexDefs(),
+	}, nil
+}
+
+// ListTables returns every table, sorted by name, with its columns, their
+// types and its row count
+func (e *Engine) ListTables() (*client.Response, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	infos := make([]client.TableInfo, 0, len(e.tables))
+	for _, name := range e.tableNames() {
+		t := e.tables[name]
+		infos = append(infos, client.TableInfo{
+			Name:    name,
+			Columns: copyStrings(t.columns),
+			Types:   copyStrings(t.types),
+			Count:   len(t.rows),
+		})
+	}
+	return &client.Response{Status: "success", Tables: infos}, nil
+}
+
+func (e *Engine) DescribeTable(name string) (*client.Response, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	t, ok := e.tables[name]
+	if !ok {
+		return failure("Table does not exist")
+	}
+	return &client.Response{
+		Status:      "success",
+		Table:       name,
+		Columns:     copyStrings(t.columns),
+		Types:       copyStrings(t.types),
+		Constraints: copyConstraints(t.constraints),
+		Indexes:     t.indexDefs(),
+		RowCount:    len(t.rows),
+	}, nil
+}
+
+// References returns the FOREIGN KEY constraints of all tables that point
+// at the given one, each tagged with the table it belongs to
+func (e *Engine) References(name string) (*client.Response, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var refs []client.Constraint
+	for _, child := range e.tableNames() {
+		for _, c := range e.tables[child].constraints {
+			if !isReference(c, name) {
+				continue
+			}
+			c = copyConstraint(c)
+			c.Table = child
+			refs = append(refs, c)
+		}
+	}
+	return &client.Response{Status: "success", Table: name, Constraints: refs}, nil
+}
+
+func (e *Engine) Insert(name string, values map[string]interface{}) (*client.Response, error) {
+	return e.insert(name, values, 0)
+}
+
+// insert adds a row under id, or under the next free ID if id is 0
+func (e *Engine) insert(name string, values map[string]interface{}, id int) (*client.Response, error) {
+	if resp, err := e.lockForWrite(); err != nil {
+		return resp, err
+	}
+	defer e.unlock()
+
+	t, ok := e.tables[name]
+	if !ok {
+		return failure("Table does not exist")
+	}
+	if !t.validValues(values) {
+		return failure("Invalid values for table schema")
+	}
+	if id != 0 && t.hasRow(id) {
+		return failure("Record already exists")
+	}
+	data := t.rowData(values)
+	if !t.unique([][]interface{}{data}, nil) {
+		return failure("Duplicate key for unique index")
+	}
+
+	if id == 0 {
+		id = e.nextID(t)
+	}
+	if err := e.log(&record{Type: "insert", Table: name, Values: values, IDs: []int{id}}); err != nil {
+		return nil, err
+	}
+
+	t, _ = e.writable(name)
+	t.insert(id, data)
+
+	resp := success("Record inserted")
+	resp.ID = id
+	return resp, nil
+}
+
+// InsertMany inserts a batch of rows. Nothing is inserted unless every row
+// is valid.
+func (e *Engine) InsertMany(name string, rows []map[string]interface{}) (*client.Response, error) {
+	return e.insertMany(name, rows, nil)
+}
+
+// InsertRows inserts a batch of rows under the given IDs, one for each row
+// in order, where InsertMany would choose them. It fails if a row already
+// has one of the IDs.
+func (e *Engine) InsertRows(name string, ids []int, rows []map[string]interface{}) (*client.Response, error) {
+	if len(ids) != len(rows) {
+		return failure("Invalid ids")
+	}
+	for _, id := range ids {
+		if id <= 0 {
+			return failure("Invalid ids")
+		}
+	}
+	return e.insertMany(name, rows, append([]int{}, ids...))
+}
+
+// insertMany adds rows under ids, or under the next free IDs if ids is nil
+func (e *Engine) insertMany(name string, rows []map[string]interface{}, ids []int) (*client.Response, error) {
+	if resp, err := e.lockForWrite(); err != nil {
+		return resp, err
+	}
+	defer e.unlock()
+
+	t, ok := e.tables[name]
+	if !ok {
+		return failure("Table does not exist")
+	}
+	if len(rows) == 0 {
+		return &client.Response{Status: "success", Message: "Records inserted", Table: name}, nil
+	}
+	for _, values := range rows {
+		if !t.validValues(values) {
+			return failure("Invalid values for table schema")
+		}
+	}
+	taken := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if t.hasRow(id) || taken[id] {
+			return failure("Record already exists")
+		}
+		taken[id] = true
+	}
+	data := make([][]interface{}, len(rows))
+	for i, values := range rows {
+		data[i] = t.rowData(values)
+	}
+	if !t.unique(data, nil) {
+		return failure("Duplicate key for unique index")
+	}
+
+	assigned := ids
+	if assigned == nil {
+		// The IDs nextID would hand out one insert at a time
+		assigned = make([]int, len(rows))
+		last := t.lastID
+		for i := range rows {
+			assigned[i] = e.ids.take(name, last+1)
+			last = assigned[i]
+		}
+	}
+	if err := e.log(&record{Type: "insert_many", Table: name, Rows: rows, IDs: assigned}); err != nil {
+		return nil, err
+	}
+
+	t, _ = e.writable(name)
+	for i, id := range assigned {
+		t.insert(id, data[i])
+	}
+
+	resp := success("Records inserted")
+	resp.Count = len(rows)
+	resp.IDs = assigned
+	return resp, nil
+}
+
+func (e *Engine) Select(name string, where map[string]interface{}) (*client.Response, error) {
+	return e.selectRows(name, nil, where)
+}
+
+// SelectRows returns the rows with the given IDs. An empty ID list selects
+// nothing.
+func (e *Engine) SelectRows(name string, ids []int) (*client.Response, error) {
+	if ids == nil {
+		ids = []int{}
+	}
+	return e.selectRows(name, ids, nil)
+}
+
+// selectRows returns the rows that are selected by ids (all rows when ids
+// is nil) and match where
+func (e *Engine) selectRows(name string, ids []int, where map[string]interface{}) (*client.Response, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	t, ok := e.tables[name]
+	if !ok {
+		return failure("Table does not exist")
+	}
+
+	var rows []client.Row
+	for _, i := range t.filter(ids, where) {
+		rows = append(rows, copyRow(t.rows[i]))
+	}
+	return &client.Response{
+		Status:  "success",
+		Table:   name,
+		Columns: copyStrings(t.columns),
+		Types:   copyStrings(t.types),
+		Rows:    rows,
+	}, nil
+}
+
+func (e *Engine) SelectAll(name string) (*client.Response, error) {
+	return e.Select(name, nil)
+}
+
+func (e *Engine) Update(name string, set map[string]interface{}, where map[string]interface{}) (*client.Response, error) {
+	return e.update(name, nil, set, where)
+}
+
+// UpdateRows updates the rows with the given IDs. An empty ID list updates
+// nothing.
+func (e *Engine) UpdateRows(name string, ids []int, set map[string]interface{}) (*client.Response, error) {
+	if len(ids) == 0 {
+		return &client.Response{Status: "success", Message: "Records updated", Table: name}, nil
+	}
+	return e.update(name, ids, set, nil)
+}
+
+func (e *Engine) Delete(name string, where map[string]interface{}) (*client.Response, error) {
+	return e.delete(name, nil, where)
+}
+
+// DeleteRows deletes the rows with the given IDs. An empty ID list deletes
+// nothing.
+func (e *Engine) DeleteRows(name string, ids []int) (*client.Response, error) {
+	if len(ids) == 0 {
+		return &client.Response{Status: "success", Message: "Records deleted", Table: name}, nil
+	}
+	return e.delete(name, ids, nil)
+}
+
+func (e *Engine) DeleteAll(name string) (*client.Response, error) {
+	return e.Delete(name, nil)
+}
+
+// SetTimeout does nothing; requests to an Engine never wait on a network
+func (e *Engine) SetTimeout(timeout time.Duration) {}
+
+// Close stops a durable Engine after a final checkpoint; for an in-memory
+// Engine it does nothing
+func (e *Engine) Close() error {
+	if e.wal == nil {
+		return nil
+	}
+	return e.closeDurable()
+}
+
+// update sets columns of the rows that are selected by ids (all rows when
+// ids is nil) and match where
+func (e *Engine) update(name string, ids []int, set map[string]interface{}, where map[string]interface{}) (*client.Response, error) {
+	if resp, err := e.lockForWrite(); err != nil {
+		return resp, err
+	}
+	defer e.unlock()
+
+	t, ok := e.tables[name]
+	if !ok {
+		return failure("Table does not exist")
+	}
+	if !t.validValues(set) {
+		return failure("Invalid values for table schema")
+	}
+
+	matched := t.filter(ids, where)
+	resp := success("Records updated")
+	resp.Count = len(matched)
+	if len(matched) == 0 {
+		return resp, nil
+	}
+
+	// Check the unique indexes against the rows as they will be
+	touched := make([]int, len(matched))
+	updated := make([][]interface{}, len(matched))
+	for k, i := range matched {
+		touched[k] = t.rows[i].ID
+		updated[k] = append([]interface{}(nil), t.rows[i].Data...)
+		for col, value := range set {
+			idx := t.columnIndex(col)
+			updated[k][idx] = client.NormalizeValue(value, t.types[idx])
+		}
+	}
+	if !t.unique(updated, idSet(touched)) {
+		return failure("Duplicate key for unique index")
+	}
+
+	// The log names the rows by ID, so replaying it touches the same rows
+	// even on top of other changes
+	if err := e.log(&record{Type: "update", Table: name, Set: set, IDs: touched}); err != nil {
+		return nil, err
+	}
+
+	t, _ = e.writable(name)
+	for k, i := range matched {
+		t.reindex(t.rows[i], updated[k])
+		t.rows[i].Data = updated[k]
+	}
+	return resp, nil
+}
+
+func (e *Engine) delete(name string, ids []int, where map[string]interface{}) (*client.Response, error) {
+	if resp, err := e.lockForWrite(); err != nil {
+		return resp, err
+	}
+	defer e.unlock()
+
+	t, ok := e.tables[name]
+	if !ok {
+		return failure("Table does not exist")
+	}
+
+	var touched []int
+	for _, i := range t.filter(ids, where) {
+		touched = append(touched, t.rows[i].ID)
+	}
+
+	resp := success("Records deleted")
+	resp.Count = len(touched)
+	if len(touched) == 0 {
+		return resp, nil
+	}
+
+	if err := e.log(&record{Type: "delete", Table: name, IDs: touched}); err != nil {
+		return nil, err
+	}
+
+	t, _ = e.writable(name)
+	deleted := idSet(touched)
+	kept := t.rows[:0]
+	t.lastID = 0
+	for _, row := range t.rows {
+		if !deleted[row.ID] {
+			kept = append(kept, row)
+			t.lastID = max(t.lastID, row.ID)
+		}
+	}
+	t.rows = kept
+	t.locate()
+	for _, ix := range t.indexes {
+		ix.drop(deleted)
+	}
+	return resp, nil
+}
+
+// lockForWrite takes e.mu for a change, which is refused once the
+// transaction of a private copy has ended
+func (e *Engine) lockForWrite() (*client.Response, error) {
+	e.mu.Lock()
+	if e.ended {
+		e.mu.Unlock()
+		return failure("No transaction in progress")
+	}
+	return nil, nil
+}
+
+func (e *Engine) tableNames() []string {
+	names := make([]string, 0, len(e.tables))
+	for name := range e.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// renameReferences updates the foreign keys that reference table after it
+// was renamed to newTable, or after its column was renamed to newColumn
+// (both empty for a table rename)
+func (e *Engine) renameReferences(table, newTable, column, newColumn string) {
+	for _, name := range e.tableNames() {
+		if !e.tables[name].references(table) {
+			continue
+		}
+		t, _ := e.writable(name)
+		for i, c := range t.constraints {
+			if !isReference(c, table) {
+				continue
+			}
+			ref := *c.References
+			if column == "" {
+				ref.Table = newTable
+			} else {
+				ref.Columns = copyStrings(ref.Columns)
+				for j, col := range ref.Columns {
+					if col == column {
+						ref.Columns[j] = newColumn
+					}
+				}
+			}
+			t.constraints[i].References = &ref
+		}
+	}
+}
+
+// writable returns the table to change in place, first swapping in a copy
+// if a snapshot holds it
+func (e *Engine) writable(name string) (*table, bool) {
+	t, ok := e.tables[name]
+	if ok && t.shared.Load() {
+		t = t.clone()
+		e.tables[name] = t
+	}
+	return t, ok
+}
+
+// references reports whether a foreign key of t points at table
+func (t *table) references(table string) bool {
+	for _, c := range t.constraints {
+		if isReference(c, table) {
+			return true
+		}
+	}
+	return false
+}
+
+func isReference(c client.Constraint, table string) bool {
+	return c.Type == client.ForeignKeyConstraint && c.References != nil && c.References.Table == table
+}
+
+func (t *table) columnIndex(name string) int {
+	for i, col := range t.columns {
+		if col == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// validValues reports whether every key of values names a column and no
+// value is NaN or infinite, which neither JSON nor the log can hold
+func (t *table) validValues(values map[string]interface{}) bool {
+	for key, value := range values {
+		if t.columnIndex(key) < 0 {
+			return false
+		}
+		if f, ok := client.NormalizeValue(value, "").(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			return false
+		}
+	}
+	return true
+}
+
+// nextID returns the ID of a new row of t: one more than the highest
+// existing ID, unless a transaction already took that one
+func (e *Engine) nextID(t *table) int {
+	return e.ids.take(t.name, t.lastID+1)
+}
+
+func (t *table) hasRow(id int) bool {
+	_, ok := t.position(id)
+	return ok
+}
+
+// rowData returns the data of a row holding values; columns missing from
+// values are NULL
+func (t *table) rowData(values map[string]interface{}) []interface{} {
+	data := make([]interface{}, len(t.columns))
+	for i, col := range t.columns {
+		data[i] = client.NormalizeValue(values[col], t.types[i])
+	}
+	return data
+}
+
+// insert stores a row under id
+func (t *table) insert(id int, data []interface{}) {
+	if t.positions == nil {
+		t.locate()
+	}
+	t.positions[id] = len(t.rows)
+	t.rows = append(t.rows, client.Row{ID: id, Data: data})
+	t.lastID = max(t.lastID, id)
+	for _, ix := range t.indexes {
+		ix.add(data[t.columnIndex(ix.Columns[0])], id)
+	}
+}
+
+// columnDefault returns the DEFAULT value constraints give column, or nil
+func columnDefault(constraints []client.Constraint, column string) interface{} {
+	for _, c := range constraints {
+		if c.Type == client.DefaultConstraint && len(c.Columns) == 1 && c.Columns[0] == column {
+			return c.Default
+		}
+	}
+	return nil
+}
+
+func idSet(ids []int) map[int]bool {
+	if ids == nil {
+		return nil
+	}
+	set := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
+
+func selected(id int, ids map[int]bool) bool {
+	return ids == nil || ids[id]
+}
+
+func contains(s []string, value string) bool {
+	for _, v := range s {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func removeAt[T any](s []T, i int) []T {
+	out := make([]T, 0, len(s)-1)
+	out = append(out, s[:i]...)
+	return append(out, s[i+1:]...)
+}
+
+func copyStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append([]string(nil), s...)
+}
+
+func copyRow(row client.Row) client.Row {
+	return client.Row{ID: row.ID, Data: append([]interface{}(nil), row.Data...)}
+}
+
+func copyConstraint(c client.Constraint) client.Constraint {
+	c.Columns = copyStrings(c.Columns)
+	if c.References != nil {
+		ref := *c.References
+		ref.Columns = copyStrings(ref.Columns)
+		c.References = &ref
+	}
+	return c
+}
+
+func copyConstraints(constraints []client.Constraint) []client.Constraint {
+	if constraints == nil {
+		return nil
+	}
+	out := make([]client.Constraint, len(constraints))
+	for i, c := range constraints {
+		out[i] = copyConstraint(c)
+	}
+	return out
+}
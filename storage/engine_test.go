@@ -0,0 +1,310 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+	"weird/db/engine/client"
+)
+
+// mustSucceed returns a check, to be called with the results of a
+// request, that fails the test unless the request succeeded
+func mustSucceed(t *testing.T) func(*client.Response, error) *client.Response {
+	return func(resp *client.Response, err error) *client.Response {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.Status != "success" {
+			t.Fatalf("unexpected status %q: %s", resp.Status, resp.Message)
+		}
+		return resp
+	}
+}
+
+// mustFail returns a check, to be called with the results of a request,
+// that fails the test unless the request was refused with message
+func mustFail(t *testing.T, message string) func(*client.Response, error) {
+	return func(resp *client.Response, err error) {
+		t.Helper()
+		if err == nil {
+			t.Fatalf("expected %q, got success", message)
+		}
+		if resp == nil || resp.Status != "error" || resp.Message != message {
+			t.Fatalf("expected %q, got %+v (%v)", message, resp, err)
+		}
+	}
+}
+
+// ids returns the IDs of the rows of a response
+func ids(resp *client.Response) []int {
+	var list []int
+	for _, row := range resp.Rows {
+		list = append(list, row.ID)
+	}
+	return list
+}
+
+func newUsers(t *testing.T) *Engine {
+	t.Helper()
+	e := NewEngine()
+	mustSucceed(t)(e.CreateTable("users", []client.Column{
+		{Name: "name", Type: "TEXT"},
+		{Name: "age", Type: "INT"},
+	}, nil))
+	return e
+}
+
+func TestInsertAssignsIDs(t *testing.T) {
+	e := newUsers(t)
+	for i, name := range []string{"ann", "bob", "cid"} {
+		resp := mustSucceed(t)(e.Insert("users", map[string]interface{}{"name": name}))
+		if resp.ID != i+1 {
+			t.Fatalf("insert %d: got ID %d", i, resp.ID)
+		}
+	}
+
+	// As in engine.pl, a new row gets one more than the highest ID left
+	mustSucceed(t)(e.DeleteRows("users", []int{3}))
+	if resp := mustSucceed(t)(e.Insert("users", nil)); resp.ID != 3 {
+		t.Errorf("after deleting the last row: got ID %d, want 3", resp.ID)
+	}
+	mustSucceed(t)(e.DeleteRows("users", []int{1}))
+	if resp := mustSucceed(t)(e.Insert("users", nil)); resp.ID != 4 {
+		t.Errorf("after deleting the first row: got ID %d, want 4", resp.ID)
+	}
+
+	mustSucceed(t)(e.Truncate("users"))
+	if resp := mustSucceed(t)(e.Insert("users", nil)); resp.ID != 1 {
+		t.Errorf("after truncate: got ID %d, want 1", resp.ID)
+	}
+}
+
+func TestInsertMany(t *testing.T) {
+	e := newUsers(t)
+	resp := mustSucceed(t)(e.InsertMany("users", []map[string]interface{}{
+		{"name": "ann", "age": 30},
+		{"name": "bob"},
+	}))
+	if resp.Count != 2 {
+		t.Errorf("got count %d, want 2", resp.Count)
+	}
+
+	// One bad row keeps the whole batch out
+	mustFail(t, "Invalid values for table schema")(e.InsertMany("users", []map[string]interface{}{
+		{"name": "cid"},
+		{"nickname": "dee"},
+	}))
+
+	all := mustSucceed(t)(e.SelectAll("users"))
+	if got := ids(all); !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Errorf("got rows %v, want [1 2]", got)
+	}
+	if got := all.Rows[0].Data; !reflect.DeepEqual(got, []interface{}{"ann", int64(30)}) {
+		t.Errorf("got data %#v", got)
+	}
+	if got := all.Rows[1].Data[1]; got != nil {
+		t.Errorf("missing column: got %#v, want NULL", got)
+	}
+}
+
+func TestInsertRows(t *testing.T) {
+	e := newUsers(t)
+	mustSucceed(t)(e.InsertMany("users", []map[string]interface{}{{"name": "ann"}, {"name": "bob"}}))
+	mustSucceed(t)(e.DeleteRows("users", []int{1}))
+
+	resp := mustSucceed(t)(e.InsertRows("users", []int{1, 5}, []map[string]interface{}{{"name": "ann"}, {"name": "cid"}}))
+	if !reflect.DeepEqual(resp.IDs, []int{1, 5}) {
+		t.Errorf("got IDs %v", resp.IDs)
+	}
+	found := mustSucceed(t)(e.SelectRows("users", []int{5, 1, 9}))
+	if got := ids(found); !reflect.DeepEqual(got, []int{1, 5}) {
+		t.Errorf("select by IDs: got %v, want [1 5]", got)
+	}
+	if resp := mustSucceed(t)(e.Insert("users", nil)); resp.ID != 6 {
+		t.Errorf("after inserting ID 5: got ID %d, want 6", resp.ID)
+	}
+	if found := mustSucceed(t)(e.SelectRows("users", nil)); len(found.Rows) != 0 {
+		t.Errorf("select by no IDs: got %v", ids(found))
+	}
+
+	mustFail(t, "Record already exists")(e.InsertRows("users", []int{2}, []map[string]interface{}{{"name": "x"}}))
+	mustFail(t, "Record already exists")(e.InsertRows("users", []int{7, 7}, []map[string]interface{}{{}, {}}))
+	mustFail(t, "Invalid ids")(e.InsertRows("users", []int{8}, nil))
+	mustFail(t, "Invalid ids")(e.InsertRows("users", []int{0}, []map[string]interface{}{{}}))
+}
+
+func TestInsertManyEmpty(t *testing.T) {
+	e := newUsers(t)
+	resp := mustSucceed(t)(e.InsertMany("users", nil))
+	if resp.Count != 0 {
+		t.Errorf("got count %d, want 0", resp.Count)
+	}
+
+	mustFail(t, "Table does not exist")(e.InsertMany("missing", nil))
+}
+
+func TestSelectWhere(t *testing.T) {
+	e := NewEngine()
+	mustSucceed(t)(e.CreateTable("t", []client.Column{
+		{Name: "code", Type: "TEXT"},
+		{Name: "n", Type: "INT"},
+		{Name: "x"},
+	}, nil))
+	mustSucceed(t)(e.InsertMany("t", []map[string]interface{}{
+		{"code": "01", "n": 1, "x": 30},
+		{"code": "1", "n": 2, "x": "30"},
+		{"code": "1e3", "n": 3},
+		{"code": nil, "n": 10, "x": 2.5},
+	}))
+
+	tests := []struct {
+		name  string
+		where map[string]interface{}
+		want  []int
+	}{
+		{"nil matches all", nil, []int{1, 2, 3, 4}},
+		{"text compares as text", map[string]interface{}{"code": "1"}, []int{2}},
+		{"no number is read out of text", map[string]interface{}{"code": "1000"}, nil},
+		{"int compares with float", map[string]interface{}{"n": 2.0}, []int{2}},
+		{"untyped number equals its text", map[string]interface{}{"x": 30}, []int{1, 2}},
+		{"operator", map[string]interface{}{"n": client.Condition{Op: ">=", Value: 3}}, []int{3, 4}},
+		{"numbers order numerically", map[string]interface{}{"n": client.Condition{Op: "<", Value: 10}}, []int{1, 2, 3}},
+		{"NULL matches nothing", map[string]interface{}{"code": client.Condition{Op: "!=", Value: "x"}}, []int{1, 2, 3}},
+		{"decoded condition", map[string]interface{}{"n": map[string]interface{}{"op": "<=", "value": 1}}, []int{1}},
+		{"all conditions hold", map[string]interface{}{"code": "01", "n": 2}, nil},
+		{"unknown column", map[string]interface{}{"nope": 1}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := mustSucceed(t)(e.Select("t", tt.where))
+			if got := ids(resp); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateAndDelete(t *testing.T) {
+	e := newUsers(t)
+	mustSucceed(t)(e.InsertMany("users", []map[string]interface{}{
+		{"name": "ann", "age": 30},
+		{"name": "bob", "age": 40},
+		{"name": "cid", "age": 40},
+	}))
+
+	resp := mustSucceed(t)(e.Update("users", map[string]interface{}{"age": "41"}, map[string]interface{}{"age": 40}))
+	if resp.Count != 2 {
+		t.Errorf("update: got count %d, want 2", resp.Count)
+	}
+	// Values are stored with their column's type
+	found := mustSucceed(t)(e.Select("users", map[string]interface{}{"age": 41}))
+	if got := ids(found); !reflect.DeepEqual(got, []int{2, 3}) {
+		t.Errorf("updated rows: got %v, want [2 3]", got)
+	}
+	if got := found.Rows[0].Data[1]; got != int64(41) {
+		t.Errorf("updated value: got %#v, want int64(41)", got)
+	}
+
+	mustFail(t, "Invalid values for table schema")(e.Update("users", map[string]interface{}{"nickname": "x"}, nil))
+
+	resp = mustSucceed(t)(e.UpdateRows("users", nil, map[string]interface{}{"age": 1}))
+	if resp.Count != 0 {
+		t.Errorf("update of no IDs: got count %d, want 0", resp.Count)
+	}
+
+	resp = mustSucceed(t)(e.Delete("users", map[string]interface{}{"name": "bob"}))
+	if resp.Count != 1 {
+		t.Errorf("delete: got count %d, want 1", resp.Count)
+	}
+	resp = mustSucceed(t)(e.DeleteAll("users"))
+	if resp.Count != 2 {
+		t.Errorf("delete all: got count %d, want 2", resp.Count)
+	}
+}
+
+func TestTableErrors(t *testing.T) {
+	e := newUsers(t)
+
+	mustFail(t, "Table already exists")(e.CreateTable("users", nil, nil))
+	mustFail(t, "Table does not exist")(e.Insert("missing", nil))
+	mustFail(t, "Table does not exist")(e.Select("missing", nil))
+	mustFail(t, "Table does not exist")(e.DropTable("missing", false))
+	mustSucceed(t)(e.DropTable("missing", true))
+
+	mustSucceed(t)(e.DropTable("users", false))
+	mustFail(t, "Table does not exist")(e.Schema("users"))
+}
+
+func TestAlterTable(t *testing.T) {
+	e := newUsers(t)
+	mustSucceed(t)(e.Insert("users", map[string]interface{}{"name": "ann"}))
+
+	constraints := []client.Constraint{{Name: "users_active_default", Type: client.DefaultConstraint, Columns: []string{"active"}, Default: true}}
+	resp := mustSucceed(t)(e.AddColumn("users", client.Column{Name: "active", Type: "BOOL"}, constraints))
+	if resp.Count != 1 {
+		t.Errorf("add column: got count %d, want 1", resp.Count)
+	}
+	row := mustSucceed(t)(e.SelectAll("users")).Rows[0]
+	if !reflect.DeepEqual(row.Data, []interface{}{"ann", nil, true}) {
+		t.Errorf("after add column: got %#v", row.Data)
+	}
+	mustFail(t, "Column already exists")(e.AddColumn("users", client.Column{Name: "age"}, nil))
+
+	mustSucceed(t)(e.DropColumn("users", "age", constraints))
+	mustFail(t, "Column already exists")(e.RenameColumn("users", "name", "active", constraints))
+	mustSucceed(t)(e.RenameColumn("users", "name", "login", constraints))
+
+	schema := mustSucceed(t)(e.Schema("users"))
+	if !reflect.DeepEqual(schema.Columns, []string{"login", "active"}) || !reflect.DeepEqual(schema.Types, []string{"TEXT", "BOOL"}) {
+		t.Errorf("got columns %v of types %v", schema.Columns, schema.Types)
+	}
+	if !reflect.DeepEqual(schema.Constraints, constraints) {
+		t.Errorf("got constraints %+v", schema.Constraints)
+	}
+}
+
+func TestRenamesFollowReferences(t *testing.T) {
+	e := newUsers(t)
+	fk := client.Constraint{
+		Name:       "posts_author_fkey",
+		Type:       client.ForeignKeyConstraint,
+		Columns:    []string{"author"},
+		References: &client.Reference{Table: "users", Columns: []string{"name"}, OnDelete: client.CascadeAction},
+	}
+	mustSucceed(t)(e.CreateTable("posts", []client.Column{{Name: "author", Type: "TEXT"}}, []client.Constraint{fk}))
+
+	mustSucceed(t)(e.RenameColumn("users", "name", "login", nil))
+	mustSucceed(t)(e.RenameTable("users", "accounts"))
+
+	refs := mustSucceed(t)(e.References("accounts"))
+	if len(refs.Constraints) != 1 {
+		t.Fatalf("got references %+v", refs.Constraints)
+	}
+	ref := refs.Constraints[0]
+	if ref.Table != "posts" || ref.References.Table != "accounts" || !reflect.DeepEqual(ref.References.Columns, []string{"login"}) {
+		t.Errorf("got reference %+v to %+v", ref, ref.References)
+	}
+	if refs := mustSucceed(t)(e.References("users")); len(refs.Constraints) != 0 {
+		t.Errorf("old name still referenced: %+v", refs.Constraints)
+	}
+}
+
+func TestListAndDescribe(t *testing.T) {
+	e := newUsers(t)
+	mustSucceed(t)(e.CreateTable("a", []client.Column{{Name: "x"}}, nil))
+	mustSucceed(t)(e.InsertMany("users", []map[string]interface{}{{"name": "ann"}, {"name": "bob"}}))
+
+	list := mustSucceed(t)(e.ListTables())
+	want := []client.TableInfo{
+		{Name: "a", Columns: []string{"x"}, Types: []string{""}, Count: 0},
+		{Name: "users", Columns: []string{"name", "age"}, Types: []string{"TEXT", "INT"}, Count: 2},
+	}
+	if !reflect.DeepEqual(list.Tables, want) {
+		t.Errorf("got tables %+v", list.Tables)
+	}
+
+	desc := mustSucceed(t)(e.DescribeTable("users"))
+	if desc.RowCount != 2 || !reflect.DeepEqual(desc.Columns, []string{"name", "age"}) {
+		t.Errorf("got description %+v", desc)
+	}
+}
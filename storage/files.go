@@ -0,0 +1,83 @@
+package storage
+
+import "weird/db/engine/dbfile"
+
+// Open loads the tables the Prolog server saved in a db_files directory
+// into a new Engine
+func Open(dir string) (*Engine, error) {
+	tables, err := dbfile.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	e := NewEngine()
+	for _, t := range tables {
+		e.tables[t.Name] = tableFrom(t)
+	}
+	return e, nil
+}
+
+// Save writes every table into dir in the Prolog server's file format, so
+// engine.pl loads it on start. Files of tables the Engine no longer has
+// are removed.
+func (e *Engine) Save(dir string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, t := range e.snapshot() {
+		if err := dbfile.SaveTable(dir, t); err != nil {
+			return err
+		}
+	}
+
+	names, err := dbfile.TableNames(dir)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if _, ok := e.tables[name]; !ok {
+			if err := dbfile.RemoveTable(dir, name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func tableFrom(t *dbfile.Table) *table {
+	loaded := &table{
+		name:        t.Name,
+		columns:     t.Columns,
+		types:       t.Types,
+		constraints: t.Constraints,
+		rows:        t.Rows,
+	}
+	loaded.locate()
+	for _, row := range loaded.rows {
+		loaded.lastID = max(loaded.lastID, row.ID)
+	}
+	for _, def := range t.Indexes {
+		if !loaded.hasColumns(def.Columns) {
+			continue
+		}
+		loaded.indexes = append(loaded.indexes, newIndex(def, loaded))
+	}
+	return loaded
+}
+
+// snapshot returns the tables in file form, sorted by name. They share
+// their slices with the Engine, so e.mu must stay held while they are used.
+func (e *Engine) snapshot() []*dbfile.Table {
+	tables := make([]*dbfile.Table, 0, len(e.tables))
+	for _, name := range e.tableNames() {
+		t := e.tables[name]
+		tables = append(tables, &dbfile.Table{
+			Name:        t.name,
+			Columns:     t.columns,
+			Types:       t.types,
+			Constraints: t.constraints,
+			Rows:        t.rows,
+			Indexes:     t.indexDefs(),
+		})
+	}
+	return tables
+}
@@ -0,0 +1,465 @@
+package storage
+
+import (
+	"sort"
+	"weird/db/engine/client"
+)
+
+// CreateIndex adds an index on columns of table. Index names are unique
+// across all tables. A unique index is refused while rows repeat its key.
+func (e *Engine) CreateIndex(name string, def client.Index) (*client.Response, error) {
+	if resp, err := e.lockForWrite(); err != nil {
+		return resp, err
+	}
+	defer e.unlock()
+
+	t, ok := e.tables[name]
+	if !ok {
+		return failure("Table does not exist")
+	}
+	if _, exists := e.indexOwner(def.Name); exists {
+		return failure("Index already exists")
+	}
+	if !t.hasColumns(def.Columns) {
+		return failure("Table or column does not exist")
+	}
+
+	if def.Unique && !t.distinct(def.Columns) {
+		return failure("Duplicate key for unique index")
+	}
+
+	def = copyIndex(def)
+	if err := e.log(&record{Type: "create_index", Table: name, Index: &def}); err != nil {
+		return nil, err
+	}
+
+	t, _ = e.writable(name)
+	t.indexes = append(t.indexes, newIndex(def, t))
+
+	resp := success("Index created")
+	resp.Table = name
+	return resp, nil
+}
+
+func (e *Engine) DropIndex(name string, ifExists bool) (*client.Response, error) {
+	if resp, err := e.lockForWrite(); err != nil {
+		return resp, err
+	}
+	defer e.unlock()
+
+	owner, ok := e.indexOwner(name)
+	if !ok {
+		if ifExists {
+			return success("Index does not exist, skipped"), nil
+		}
+		return failure("Index does not exist")
+	}
+
+	if err := e.log(&record{Type: "drop_index", Table: owner, Index: &client.Index{Name: name}, IfExists: ifExists}); err != nil {
+		return nil, err
+	}
+
+	t, _ := e.writable(owner)
+	var kept []*index
+	for _, ix := range t.indexes {
+		if ix.Name != name {
+			kept = append(kept, ix)
+		}
+	}
+	t.indexes = kept
+
+	resp := success("Index dropped")
+	resp.Table = owner
+	return resp, nil
+}
+
+// indexOwner returns the table the named index belongs to
+func (e *Engine) indexOwner(name string) (string, bool) {
+	for table, t := range e.tables {
+		for _, ix := range t.indexes {
+			if ix.Name == name {
+				return table, true
+			}
+		}
+	}
+	return "", false
+}
+
+// hasColumns reports whether columns is a non-empty list of columns of t
+func (t *table) hasColumns(columns []string) bool {
+	for _, col := range columns {
+		if t.columnIndex(col) < 0 {
+			return false
+		}
+	}
+	return len(columns) > 0
+}
+
+// indexDefs returns the definitions of the indexes of t
+func (t *table) indexDefs() []client.Index {
+	var defs []client.Index
+	for _, ix := range t.indexes {
+		defs = append(defs, copyIndex(ix.Index))
+	}
+	return defs
+}
+
+// reindex moves row in the indexes whose leading column data changes. A
+// number replacing equal text, or the reverse, moves between lists.
+func (t *table) reindex(row client.Row, data []interface{}) {
+	for _, ix := range t.indexes {
+		lead := t.columnIndex(ix.Columns[0])
+		old, value := row.Data[lead], data[lead]
+		if valueKey(old) == valueKey(value) && isNumber(old) == isNumber(value) {
+			continue
+		}
+		ix.remove(row.Data[lead], row.ID)
+		ix.add(data[lead], row.ID)
+	}
+}
+
+// index is a secondary index of a table, ordered by the value of its
+// leading column the way compareValues orders values. Numbers are held
+// twice: in numeric order, for probes with a number, and in text order, for
+// probes with a string or bool, which compare with numbers as text. NULLs
+// are left out, as nothing compares to them.
+type index struct {
+	client.Index
+	nums     []numEntry
+	numTexts []textEntry
+	texts    []textEntry
+}
+
+type numEntry struct {
+	key interface{}
+	id  int
+}
+
+// textEntry is keyed by client.ValueKey, which orders as the text values
+// compare by
+type textEntry struct {
+	key string
+	id  int
+}
+
+// newIndex builds an index over the rows of t
+func newIndex(def client.Index, t *table) *index {
+	ix := &index{Index: copyIndex(def)}
+	lead := t.columnIndex(def.Columns[0])
+	for _, row := range t.rows {
+		v := client.NormalizeValue(row.Data[lead], "")
+		switch v.(type) {
+		case nil:
+		case int64, float64:
+			ix.nums = append(ix.nums, numEntry{v, row.ID})
+			ix.numTexts = append(ix.numTexts, textEntry{client.ValueKey(v), row.ID})
+		default:
+			ix.texts = append(ix.texts, textEntry{client.ValueKey(v), row.ID})
+		}
+	}
+	sort.Slice(ix.nums, func(i, j int) bool { return ix.nums[i].less(ix.nums[j]) })
+	sort.Slice(ix.numTexts, func(i, j int) bool { return ix.numTexts[i].less(ix.numTexts[j]) })
+	sort.Slice(ix.texts, func(i, j int) bool { return ix.texts[i].less(ix.texts[j]) })
+	return ix
+}
+
+func (a numEntry) less(b numEntry) bool {
+	order := client.CompareValues(a.key, b.key)
+	return order < 0 || (order == 0 && a.id < b.id)
+}
+
+func (a textEntry) less(b textEntry) bool {
+	return a.key < b.key || (a.key == b.key && a.id < b.id)
+}
+
+// add enters the row id with value as its leading column
+func (ix *index) add(value interface{}, id int) {
+	v := client.NormalizeValue(value, "")
+	switch v.(type) {
+	case nil:
+	case int64, float64:
+		e := numEntry{v, id}
+		i := sort.Search(len(ix.nums), func(i int) bool { return !ix.nums[i].less(e) })
+		ix.nums = append(ix.nums, numEntry{})
+		copy(ix.nums[i+1:], ix.nums[i:])
+		ix.nums[i] = e
+		ix.numTexts = insertText(ix.numTexts, textEntry{client.ValueKey(v), id})
+	default:
+		ix.texts = insertText(ix.texts, textEntry{client.ValueKey(v), id})
+	}
+}
+
+// isNumber reports whether value is held in the numeric lists
+func isNumber(value interface{}) bool {
+	switch client.NormalizeValue(value, "").(type) {
+	case int64, float64:
+		return true
+	default:
+		return false
+	}
+}
+
+func insertText(entries []textEntry, e textEntry) []textEntry {
+	i := sort.Search(len(entries), func(i int) bool { return !entries[i].less(e) })
+	entries = append(entries, textEntry{})
+	copy(entries[i+1:], entries[i:])
+	entries[i] = e
+	return entries
+}
+
+// remove takes out the row id entered with value
+func (ix *index) remove(value interface{}, id int) {
+	v := client.NormalizeValue(value, "")
+	switch v.(type) {
+	case nil:
+	case int64, float64:
+		e := numEntry{v, id}
+		i := sort.Search(len(ix.nums), func(i int) bool { return !ix.nums[i].less(e) })
+		if i < len(ix.nums) && ix.nums[i].id == id && !e.less(ix.nums[i]) {
+			ix.nums = append(ix.nums[:i], ix.nums[i+1:]...)
+		}
+		ix.numTexts = removeText(ix.numTexts, textEntry{client.ValueKey(v), id})
+	default:
+		ix.texts = removeText(ix.texts, textEntry{client.ValueKey(v), id})
+	}
+}
+
+func removeText(entries []textEntry, e textEntry) []textEntry {
+	i := sort.Search(len(entries), func(i int) bool { return !entries[i].less(e) })
+	if i < len(entries) && entries[i] == e {
+		entries = append(entries[:i], entries[i+1:]...)
+	}
+	return entries
+}
+
+// drop takes out every row in ids
+func (ix *index) drop(ids map[int]bool) {
+	nums := ix.nums[:0]
+	for _, e := range ix.nums {
+		if !ids[e.id] {
+			nums = append(nums, e)
+		}
+	}
+	ix.nums = nums
+	ix.numTexts = dropTexts(ix.numTexts, ids)
+	ix.texts = dropTexts(ix.texts, ids)
+}
+
+func dropTexts(entries []textEntry, ids map[int]bool) []textEntry {
+	kept := entries[:0]
+	for _, e := range entries {
+		if !ids[e.id] {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+// lookup returns the IDs of the rows whose leading column satisfies op
+// with value; ok is false for an operator the index can't answer. A number
+// finds numbers by value and strings by text; a string or bool finds
+// everything by text.
+func (ix *index) lookup(op string, value interface{}) (ids []int, ok bool) {
+	switch op {
+	case "=", "<", ">", "<=", ">=":
+	default:
+		return nil, false
+	}
+	v := client.NormalizeValue(value, "")
+	if v == nil {
+		return nil, true
+	}
+
+	key := client.ValueKey(v)
+	texts := [][]textEntry{ix.texts}
+	switch v.(type) {
+	case int64, float64:
+		lo, hi := span(op, len(ix.nums),
+			func(i int) bool { return client.CompareValues(ix.nums[i].key, v) >= 0 },
+			func(i int) bool { return client.CompareValues(ix.nums[i].key, v) > 0 })
+		for _, e := range ix.nums[lo:hi] {
+			ids = append(ids, e.id)
+		}
+	default:
+		texts = append(texts, ix.numTexts)
+	}
+
+	for _, entries := range texts {
+		lo, hi := span(op, len(entries),
+			func(i int) bool { return entries[i].key >= key },
+			func(i int) bool { return entries[i].key > key })
+		for _, e := range entries[lo:hi] {
+			ids = append(ids, e.id)
+		}
+	}
+	return ids, true
+}
+
+// span returns the range of sorted entries satisfying op, given the
+// predicates of entries at or past the key (atLeast) and past it (above)
+func span(op string, n int, atLeast, above func(int) bool) (int, int) {
+	lower := sort.Search(n, atLeast)
+	upper := sort.Search(n, above)
+	switch op {
+	case "=":
+		return lower, upper
+	case "<":
+		return 0, lower
+	case "<=":
+		return 0, upper
+	case ">":
+		return upper, n
+	default:
+		return lower, n
+	}
+}
+
+// unique reports whether rows, about to be stored in t, keep the keys of
+// its unique indexes unique among themselves and the rows of t not in
+// skip. As for UNIQUE constraints, a key with a NULL in it never collides.
+func (t *table) unique(rows [][]interface{}, skip map[int]bool) bool {
+	for _, ix := range t.indexes {
+		if !ix.Unique {
+			continue
+		}
+		columns := make([]int, len(ix.Columns))
+		for i, col := range ix.Columns {
+			columns[i] = t.columnIndex(col)
+		}
+		seen := make(map[string]bool, len(rows))
+		for _, data := range rows {
+			key, ok := indexKey(columns, data)
+			if !ok {
+				continue
+			}
+			if seen[key] || ix.holds(t, columns, data, skip) {
+				return false
+			}
+			seen[key] = true
+		}
+	}
+	return true
+}
+
+// holds reports whether a row of t not in skip has the key data has in
+// columns
+func (ix *index) holds(t *table, columns []int, data []interface{}, skip map[int]bool) bool {
+	ids, _ := ix.lookup("=", data[columns[0]])
+	for _, id := range ids {
+		if skip[id] {
+			continue
+		}
+		if pos, ok := t.position(id); ok && sameKey(columns, t.rows[pos].Data, data) {
+			return true
+		}
+	}
+	return false
+}
+
+// indexKey returns the key data has in columns, as a UNIQUE constraint
+// compares them: values of different kinds never share a key. ok is false
+// if one of them is NULL.
+func indexKey(columns []int, data []interface{}) (key string, ok bool) {
+	for _, col := range columns {
+		k := client.TypedKey(data[col])
+		if k == "" {
+			return "", false
+		}
+		key += k + "\x00"
+	}
+	return key, true
+}
+
+// valueKey returns the key of a single value, empty for NULL
+func valueKey(value interface{}) string {
+	return client.ValueKey(value)
+}
+
+// sameKey reports whether a and b hold the same key in columns
+func sameKey(columns []int, a, b []interface{}) bool {
+	for _, col := range columns {
+		if client.TypedKey(a[col]) != client.TypedKey(b[col]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (ix *index) clone() *index {
+	return &index{
+		Index:    copyIndex(ix.Index),
+		nums:     append([]numEntry(nil), ix.nums...),
+		numTexts: append([]textEntry(nil), ix.numTexts...),
+		texts:    append([]textEntry(nil), ix.texts...),
+	}
+}
+
+func copyIndex(def client.Index) client.Index {
+	def.Columns = copyStrings(def.Columns)
+	return def
+}
+
+// position returns where the row id is in t.rows
+func (t *table) position(id int) (int, bool) {
+	pos, ok := t.positions[id]
+	return pos, ok
+}
+
+// locate records where each row is. Writers keep the positions current, as
+// snapshots read the table without a lock in common.
+func (t *table) locate() {
+	t.positions = make(map[int]int, len(t.rows))
+	for i, row := range t.rows {
+		t.positions[row.ID] = i
+	}
+}
+
+// filter returns the positions of the rows selected by ids (all when ids
+// is nil) that match where, in table order. An index on the column of a
+// condition narrows the rows to check; the one leaving the fewest wins.
+func (t *table) filter(ids []int, where map[string]interface{}) []int {
+	candidates := ids
+	indexed := ids != nil
+	if !indexed {
+		for key, condition := range where {
+			op, value := conditionOperands(condition)
+			for _, ix := range t.indexes {
+				if ix.Columns[0] != key {
+					continue
+				}
+				if found, ok := ix.lookup(op, value); ok && (!indexed || len(found) < len(candidates)) {
+					candidates, indexed = found, true
+				}
+			}
+		}
+	}
+
+	var positions []int
+	if indexed {
+		for _, id := range candidates {
+			if pos, ok := t.position(id); ok {
+				positions = append(positions, pos)
+			}
+		}
+		sort.Ints(positions)
+	} else {
+		positions = make([]int, len(t.rows))
+		for i := range t.rows {
+			positions[i] = i
+		}
+	}
+
+	only := idSet(ids)
+	matched := positions[:0]
+	for i, pos := range positions {
+		// IDs may repeat
+		if i > 0 && pos == positions[i-1] {
+			continue
+		}
+		row := t.rows[pos]
+		if selected(row.ID, only) && t.matches(row.Data, where) {
+			matched = append(matched, pos)
+		}
+	}
+	return matched
+}
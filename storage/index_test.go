@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"weird/db/engine/client"
+)
+
+// newMixed returns an Engine with an untyped column holding values of
+// every kind, indexed
+func newMixed(t *testing.T) *Engine {
+	t.Helper()
+	e := NewEngine()
+	mustSucceed(t)(e.CreateTable("t", []client.Column{{Name: "x"}, {Name: "y", Type: "INT"}}, nil))
+	var rows []map[string]interface{}
+	for _, x := range []interface{}{1, 2.5, "10", "abc", "2.5", true, nil, 30, "30", -4, 2, 2, "", false} {
+		rows = append(rows, map[string]interface{}{"x": x})
+	}
+	mustSucceed(t)(e.InsertMany("t", rows))
+	mustSucceed(t)(e.CreateIndex("t", client.Index{Name: "t_x", Columns: []string{"x"}}))
+	return e
+}
+
+// scan returns the IDs of the rows of name matching op and value in
+// column, found without an index
+func scan(e *Engine, name, column, op string, value interface{}) []int {
+	t := e.tables[name]
+	where := map[string]interface{}{column: client.Condition{Op: op, Value: value}}
+	var found []int
+	for _, row := range t.rows {
+		if t.matches(row.Data, where) {
+			found = append(found, row.ID)
+		}
+	}
+	return found
+}
+
+// checkIndexes fails the test unless every index of name holds what one
+// built anew from the rows would
+func checkIndexes(t *testing.T, e *Engine, name string) {
+	t.Helper()
+	tbl := e.tables[name]
+	for _, ix := range tbl.indexes {
+		built := newIndex(ix.Index, tbl)
+		got := fmt.Sprint(ix.nums, ix.numTexts, ix.texts)
+		if want := fmt.Sprint(built.nums, built.numTexts, built.texts); got != want {
+			t.Errorf("index %s holds %s, want %s", ix.Name, got, want)
+		}
+	}
+}
+
+func TestIndexLookup(t *testing.T) {
+	e := newMixed(t)
+	ix := e.tables["t"].indexes[0]
+	probes := []interface{}{2, 2.0, 2.5, "2.5", 30, "30", -10, 100, "10", "b", "", true, nil}
+	for _, op := range []string{"=", "<", "<=", ">", ">="} {
+		for _, probe := range probes {
+			found, ok := ix.lookup(op, probe)
+			if !ok {
+				t.Fatalf("%s %#v: not answered", op, probe)
+			}
+			sort.Ints(found)
+			if want := scan(e, "t", "x", op, probe); !reflect.DeepEqual(found, want) {
+				t.Errorf("x %s %#v: got %v, want %v", op, probe, found, want)
+			}
+		}
+	}
+
+	if _, ok := ix.lookup("!=", 2); ok {
+		t.Error("!= was answered by the index")
+	}
+}
+
+func TestIndexMaintained(t *testing.T) {
+	e := newMixed(t)
+	mustSucceed(t)(e.CreateIndex("t", client.Index{Name: "t_y_x", Columns: []string{"y", "x"}}))
+	checkIndexes(t, e, "t")
+
+	steps := []struct {
+		name   string
+		change func() (*client.Response, error)
+	}{
+		{"insert", func() (*client.Response, error) { return e.Insert("t", map[string]interface{}{"x": 2, "y": 1}) }},
+		{"number to equal text", func() (*client.Response, error) {
+			return e.Update("t", map[string]interface{}{"x": "2"}, map[string]interface{}{"x": 2})
+		}},
+		{"text to number", func() (*client.Response, error) {
+			return e.UpdateRows("t", []int{4}, map[string]interface{}{"x": 7})
+		}},
+		{"to NULL", func() (*client.Response, error) {
+			return e.UpdateRows("t", []int{1}, map[string]interface{}{"x": nil, "y": 5})
+		}},
+		{"other column", func() (*client.Response, error) {
+			return e.Update("t", map[string]interface{}{"y": 3}, nil)
+		}},
+		{"delete", func() (*client.Response, error) {
+			return e.Delete("t", map[string]interface{}{"x": client.Condition{Op: ">", Value: 5}})
+		}},
+		{"insert again", func() (*client.Response, error) {
+			return e.InsertRows("t", []int{8}, []map[string]interface{}{{"x": 30}})
+		}},
+		{"truncate", func() (*client.Response, error) { return e.Truncate("t") }},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			mustSucceed(t)(step.change())
+			checkIndexes(t, e, "t")
+		})
+	}
+
+	mustSucceed(t)(e.DropIndex("t_x", false))
+	if got := mustSucceed(t)(e.Schema("t")).Indexes; len(got) != 1 || got[0].Name != "t_y_x" {
+		t.Errorf("after drop: got indexes %+v", got)
+	}
+	mustFail(t, "Index does not exist")(e.DropIndex("t_x", false))
+}
+
+func TestSelectThroughIndex(t *testing.T) {
+	e := newMixed(t)
+	for _, where := range []map[string]interface{}{
+		{"x": 2},
+		{"x": "30"},
+		{"x": client.Condition{Op: "<", Value: 3}},
+		{"x": client.Condition{Op: ">=", Value: "2"}},
+		{"x": client.Condition{Op: "!=", Value: 2}},
+		{"x": 2, "y": nil},
+	} {
+		resp := mustSucceed(t)(e.Select("t", where))
+		var want []int
+		for _, row := range e.tables["t"].rows {
+			if e.tables["t"].matches(row.Data, where) {
+				want = append(want, row.ID)
+			}
+		}
+		if got := ids(resp); !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: got %v, want %v", where, got, want)
+		}
+	}
+}
+
+func TestUniqueIndex(t *testing.T) {
+	e := newUsers(t)
+	mustSucceed(t)(e.InsertMany("users", []map[string]interface{}{
+		{"name": "ann", "age": 30},
+		{"name": "ann", "age": 40},
+		{"name": nil, "age": 30},
+	}))
+	unique := client.Index{Name: "users_name_age", Columns: []string{"name", "age"}, Unique: true}
+	mustFail(t, "Duplicate key for unique index")(e.CreateIndex("users", client.Index{Name: "users_name", Columns: []string{"name"}, Unique: true}))
+	mustSucceed(t)(e.CreateIndex("users", unique))
+	mustFail(t, "Index already exists")(e.CreateIndex("users", unique))
+
+	dup := "Duplicate key for unique index"
+	mustFail(t, dup)(e.Insert("users", map[string]interface{}{"name": "ann", "age": 30.0}))
+	mustFail(t, dup)(e.InsertMany("users", []map[string]interface{}{{"name": "bob", "age": 1}, {"name": "bob", "age": 1}}))
+	mustFail(t, dup)(e.UpdateRows("users", []int{2}, map[string]interface{}{"age": 30}))
+	mustFail(t, dup)(e.Update("users", map[string]interface{}{"age": 50}, map[string]interface{}{"name": "ann"}))
+
+	// Keys with a NULL never collide, and a row may keep its own key
+	mustSucceed(t)(e.Insert("users", map[string]interface{}{"name": nil, "age": 30}))
+	mustSucceed(t)(e.Insert("users", map[string]interface{}{"name": "ann"}))
+	mustSucceed(t)(e.Insert("users", map[string]interface{}{"name": "ann"}))
+	mustSucceed(t)(e.UpdateRows("users", []int{1}, map[string]interface{}{"age": 30}))
+	checkIndexes(t, e, "users")
+}
@@ -0,0 +1,473 @@
+package storage
+
+import (
+	"fmt"
+	"sync"
+	"weird/db/engine/client"
+)
+
+// Tx is a transaction of an Engine, run under snapshot isolation. Begin
+// gives it a private copy of the tables as they were at that moment; its
+// reads never see changes made after that by anyone else, and its own
+// changes are staged on the copy. Copies share every table until one side
+// writes to it, so Begin costs little; the first write to a table while a
+// transaction is open copies that table, and once the last one has ended
+// tables are written in place again.
+//
+// Commit applies the staged changes to the Engine's current tables, all at
+// once, and logs them as one record, so recovery replays all of them or
+// none. It fails with a write conflict, discarding them, if a change
+// committed since Begin wrote one of the rows the transaction wrote, or
+// changed the definition of a table either one wrote, or if merging both
+// left two rows with the same PRIMARY KEY, UNIQUE or unique index key, or
+// a row whose FOREIGN KEY finds no parent row.
+// The first to commit wins. Like any snapshot isolation this allows write
+// skew: two transactions may each act on a row the other changes without
+// conflict.
+type Tx struct {
+	// Engine is the private copy the transaction works on
+	*Engine
+	parent *Engine
+	// version is the parent's version the snapshot was taken at
+	version uint64
+	records []record
+}
+
+var _ client.Tx = (*Tx)(nil)
+
+// Begin starts a transaction. Any number may be open at a time.
+func (e *Engine) Begin() (client.Tx, error) {
+	if e.staged != nil {
+		return nil, fmt.Errorf("transaction already in progress")
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	tx := &Tx{Engine: e.fork(), parent: e, version: e.version}
+	tx.Engine.staged = &tx.records
+	e.txs[tx] = true
+	e.ids.start()
+	return tx, nil
+}
+
+// Commit makes the transaction's changes visible and durable
+func (tx *Tx) Commit() (*client.Response, error) {
+	p := tx.parent
+	p.mu.Lock()
+	defer p.unlock()
+
+	if !p.txs[tx] {
+		return failure("No transaction in progress")
+	}
+	tx.Engine.mu.Lock()
+	defer tx.Engine.mu.Unlock()
+	defer p.endTx(tx)
+
+	count := len(tx.records)
+	if count > 0 {
+		tables := tx.Engine.tables
+		if p.version != tx.version {
+			// Others committed since the snapshot: repeat the changes on
+			// top of theirs instead
+			var ok bool
+			if tables, ok = p.merge(tx); !ok {
+				return failure("Write conflict with a concurrent transaction")
+			}
+		}
+		if err := p.log(&record{Type: "commit", Records: tx.records}); err != nil {
+			return nil, err
+		}
+		p.tables = tables
+	}
+
+	resp := success("Transaction committed")
+	resp.Count = count
+	return resp, nil
+}
+
+// Rollback discards the transaction's changes
+func (tx *Tx) Rollback() (*client.Response, error) {
+	p := tx.parent
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.txs[tx] {
+		return failure("No transaction in progress")
+	}
+	tx.Engine.mu.Lock()
+	defer tx.Engine.mu.Unlock()
+
+	p.endTx(tx)
+	return success("Transaction rolled back"), nil
+}
+
+// Close rolls the transaction back unless it already ended
+func (tx *Tx) Close() error {
+	tx.parent.mu.Lock()
+	active := tx.parent.txs[tx]
+	tx.parent.mu.Unlock()
+
+	if active {
+		_, err := tx.Rollback()
+		return err
+	}
+	return nil
+}
+
+// endTx closes the private copy of tx and forgets the history no open
+// transaction needs anymore; e.mu and the copy's lock must be held
+func (e *Engine) endTx(tx *Tx) {
+	delete(e.txs, tx)
+	tx.Engine.tables = make(map[string]*table)
+	tx.Engine.ended = true
+
+	if len(e.txs) == 0 {
+		e.history = nil
+		e.ids.stop()
+		// No snapshot holds the tables anymore
+		for _, t := range e.tables {
+			t.shared.Store(false)
+		}
+		return
+	}
+	oldest := e.version
+	for open := range e.txs {
+		if open.version < oldest {
+			oldest = open.version
+		}
+	}
+	kept := e.history[:0]
+	for _, c := range e.history {
+		if c.version > oldest {
+			kept = append(kept, c)
+		}
+	}
+	// Let go of the write sets of the changes dropped
+	clear(e.history[len(kept):])
+	e.history = kept
+}
+
+// fork returns an Engine on a snapshot of e's tables; e.mu must be held
+func (e *Engine) fork() *Engine {
+	f := NewEngine()
+	f.ids = e.ids
+	for name, t := range e.tables {
+		t.shared.Store(true)
+		f.tables[name] = t
+	}
+	return f
+}
+
+// merge repeats the changes of tx on a snapshot of the current tables and
+// returns the result, unless they conflict with the changes committed
+// since tx began
+func (e *Engine) merge(tx *Tx) (map[string]*table, bool) {
+	mine := writesOf(tx.records)
+	concurrent := newWriteSet()
+	for _, c := range e.history {
+		if c.version <= tx.version {
+			continue
+		}
+		if mine.overlaps(c.writes) {
+			return nil, false
+		}
+		concurrent.add(c.writes)
+	}
+
+	f := e.fork()
+	var discard []record
+	f.staged = &discard
+	for _, rec := range tx.records {
+		if err := f.apply(rec); err != nil {
+			return nil, false
+		}
+	}
+
+	// The rows both sides wrote are distinct, but their keys may collide
+	for name := range mine.tables {
+		if _, ok := concurrent.tables[name]; !ok {
+			continue
+		}
+		if t, ok := f.tables[name]; ok && !t.uniqueKeys() {
+			return nil, false
+		}
+	}
+	// Nor may one side have removed rows the other's rows refer to
+	if !f.referencesHold(mine, concurrent) {
+		return nil, false
+	}
+	return f.tables, true
+}
+
+// referencesHold reports whether the rows of e find their parent rows
+// under every foreign key between a table one write set wrote and a table
+// the other wrote
+func (e *Engine) referencesHold(a, b *writeSet) bool {
+	for name, t := range e.tables {
+		for _, c := range t.constraints {
+			if c.Type != client.ForeignKeyConstraint || c.References == nil {
+				continue
+			}
+			parent := c.References.Table
+			if !(a.wrote(name) && b.wrote(parent)) && !(a.wrote(parent) && b.wrote(name)) {
+				continue
+			}
+			if !t.refersTo(c, e.tables[parent]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// refersTo reports whether parent holds the key of every row of t under
+// the foreign key c. As in the executor's check, a key with a NULL in it
+// refers to nothing.
+func (t *table) refersTo(c client.Constraint, parent *table) bool {
+	columns, ok := t.columnIndexes(c.Columns)
+	if !ok {
+		return true
+	}
+	keys := make(map[string]bool)
+	if parent != nil {
+		parentColumns, ok := parent.columnIndexes(c.References.Columns)
+		if !ok {
+			return true
+		}
+		for _, row := range parent.rows {
+			if key, ok := indexKey(parentColumns, row.Data); ok {
+				keys[key] = true
+			}
+		}
+	}
+	for _, row := range t.rows {
+		if key, ok := indexKey(columns, row.Data); ok && !keys[key] {
+			return false
+		}
+	}
+	return true
+}
+
+// columnIndexes returns the positions of columns in t, or false if one of
+// them is missing
+func (t *table) columnIndexes(columns []string) ([]int, bool) {
+	idx := make([]int, len(columns))
+	for i, col := range columns {
+		if idx[i] = t.columnIndex(col); idx[i] < 0 {
+			return nil, false
+		}
+	}
+	return idx, true
+}
+
+// uniqueKeys reports whether the PRIMARY KEY and UNIQUE constraints and
+// the unique indexes of t hold
+func (t *table) uniqueKeys() bool {
+	for _, c := range t.constraints {
+		if c.Type != client.PrimaryKeyConstraint && c.Type != client.UniqueConstraint {
+			continue
+		}
+		if !t.distinct(c.Columns) {
+			return false
+		}
+	}
+	for _, ix := range t.indexes {
+		if ix.Unique && !t.distinct(ix.Columns) {
+			return false
+		}
+	}
+	return true
+}
+
+// distinct reports whether no two rows of t hold the same values in
+// columns. As in the executor's check, a key with a NULL in it never
+// collides.
+func (t *table) distinct(columns []string) bool {
+	idx, ok := t.columnIndexes(columns)
+	if !ok {
+		return true
+	}
+	seen := make(map[string]bool, len(t.rows))
+	for _, row := range t.rows {
+		key, ok := indexKey(idx, row.Data)
+		if !ok {
+			continue
+		}
+		if seen[key] {
+			return false
+		}
+		seen[key] = true
+	}
+	return true
+}
+
+// change is a write made to an Engine while transactions were open
+type change struct {
+	version uint64
+	writes  *writeSet
+}
+
+// writeSet is what changes wrote: per table the IDs of the rows, or nil
+// for the whole table when its definition or all of its rows changed.
+// Renames also rewrite foreign keys of other tables, so they count as
+// writing everything.
+type writeSet struct {
+	all    bool
+	tables map[string]map[int]bool
+}
+
+func newWriteSet() *writeSet {
+	return &writeSet{tables: make(map[string]map[int]bool)}
+}
+
+func writesOf(records []record) *writeSet {
+	w := newWriteSet()
+	for _, rec := range records {
+		w.record(rec)
+	}
+	return w
+}
+
+func (w *writeSet) record(rec record) {
+	switch rec.Type {
+	case "commit":
+		for _, inner := range rec.Records {
+			w.record(inner)
+		}
+	case "insert", "insert_many", "update", "delete":
+		if len(rec.IDs) == 0 {
+			w.table(rec.Table)
+			return
+		}
+		w.rows(rec.Table, rec.IDs)
+	case "rename_table":
+		w.all = true
+		w.table(rec.Table)
+		w.table(rec.NewName)
+	case "rename_column":
+		w.all = true
+		w.table(rec.Table)
+	default:
+		w.table(rec.Table)
+	}
+}
+
+func (w *writeSet) table(name string) {
+	w.tables[name] = nil
+}
+
+func (w *writeSet) rows(name string, ids []int) {
+	set, ok := w.tables[name]
+	if ok && set == nil {
+		return
+	}
+	if set == nil {
+		set = make(map[int]bool, len(ids))
+		w.tables[name] = set
+	}
+	for _, id := range ids {
+		set[id] = true
+	}
+}
+
+// wrote reports whether w wrote anything of table name
+func (w *writeSet) wrote(name string) bool {
+	_, ok := w.tables[name]
+	return ok
+}
+
+func (w *writeSet) add(other *writeSet) {
+	w.all = w.all || other.all
+	for name, ids := range other.tables {
+		if ids == nil {
+			w.table(name)
+			continue
+		}
+		for id := range ids {
+			w.rows(name, []int{id})
+		}
+	}
+}
+
+func (w *writeSet) overlaps(other *writeSet) bool {
+	if (w.all && len(other.tables) > 0) || (other.all && len(w.tables) > 0) {
+		return true
+	}
+	for name, ids := range w.tables {
+		theirs, ok := other.tables[name]
+		if !ok {
+			continue
+		}
+		if ids == nil || theirs == nil {
+			return true
+		}
+		for id := range ids {
+			if theirs[id] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sequences keep concurrent transactions from giving new rows of a table
+// the same ID. While transactions are open every ID handed out is
+// remembered, and later rows get higher ones even when the row that took
+// it is not visible to them. Otherwise IDs are one more than the highest
+// existing one, as in engine.pl.
+type sequences struct {
+	mu   sync.Mutex
+	next map[string]int
+}
+
+// take returns id, or a higher one if id may already be in use
+func (s *sequences) take(table string, id int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.next == nil {
+		return id
+	}
+	if next := s.next[table]; next > id {
+		id = next
+	}
+	s.next[table] = id + 1
+	return id
+}
+
+func (s *sequences) start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.next == nil {
+		s.next = make(map[string]int)
+	}
+}
+
+func (s *sequences) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next = nil
+}
+
+func (t *table) clone() *table {
+	rows := make([]client.Row, len(t.rows))
+	for i, row := range t.rows {
+		rows[i] = copyRow(row)
+	}
+	indexes := make([]*index, len(t.indexes))
+	for i, ix := range t.indexes {
+		indexes[i] = ix.clone()
+	}
+	c := &table{
+		name:        t.name,
+		columns:     copyStrings(t.columns),
+		types:       copyStrings(t.types),
+		constraints: copyConstraints(t.constraints),
+		rows:        rows,
+		lastID:      t.lastID,
+		indexes:     indexes,
+	}
+	c.locate()
+	return c
+}
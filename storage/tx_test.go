@@ -0,0 +1,259 @@
+package storage
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"weird/db/engine/client"
+)
+
+func begin(t *testing.T, e *Engine) client.Tx {
+	t.Helper()
+	tx, err := e.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+// newPosts adds to newUsers a table of posts whose author refers to a user
+func newPosts(t *testing.T) *Engine {
+	t.Helper()
+	e := newUsers(t)
+	mustSucceed(t)(e.CreateIndex("users", client.Index{Name: "users_name", Columns: []string{"name"}, Unique: true}))
+	mustSucceed(t)(e.CreateTable("posts", []client.Column{{Name: "author", Type: "TEXT"}}, []client.Constraint{{
+		Name:       "posts_author_fkey",
+		Type:       client.ForeignKeyConstraint,
+		Columns:    []string{"author"},
+		References: &client.Reference{Table: "users", Columns: []string{"name"}, OnDelete: client.RestrictAction},
+	}}))
+	mustSucceed(t)(e.Insert("users", map[string]interface{}{"name": "ann"}))
+	return e
+}
+
+func TestSnapshotIsolation(t *testing.T) {
+	e := newUsers(t)
+	mustSucceed(t)(e.Insert("users", map[string]interface{}{"name": "ann"}))
+
+	tx := begin(t, e)
+	mustSucceed(t)(tx.Insert("users", map[string]interface{}{"name": "bob"}))
+	mustSucceed(t)(e.Insert("users", map[string]interface{}{"name": "cid"}))
+	if got := ids(mustSucceed(t)(tx.SelectAll("users"))); !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Errorf("in the transaction: got %v, want [1 2]", got)
+	}
+	if got := ids(mustSucceed(t)(e.SelectAll("users"))); !reflect.DeepEqual(got, []int{1, 3}) {
+		t.Errorf("outside: got %v, want [1 3]", got)
+	}
+
+	mustSucceed(t)(tx.Commit())
+	if got := ids(mustSucceed(t)(e.SelectAll("users"))); !reflect.DeepEqual(got, []int{1, 3, 2}) {
+		t.Errorf("after commit: got %v, want [1 3 2]", got)
+	}
+	mustFail(t, "No transaction in progress")(tx.Insert("users", nil))
+}
+
+func TestWriteConflict(t *testing.T) {
+	e := newUsers(t)
+	mustSucceed(t)(e.InsertMany("users", []map[string]interface{}{{"name": "ann"}, {"name": "bob"}}))
+
+	first, second, other := begin(t, e), begin(t, e), begin(t, e)
+	mustSucceed(t)(first.UpdateRows("users", []int{1}, map[string]interface{}{"age": 1}))
+	mustSucceed(t)(second.UpdateRows("users", []int{1}, map[string]interface{}{"age": 2}))
+	mustSucceed(t)(other.UpdateRows("users", []int{2}, map[string]interface{}{"age": 3}))
+
+	mustSucceed(t)(first.Commit())
+	mustFail(t, "Write conflict with a concurrent transaction")(second.Commit())
+	mustSucceed(t)(other.Commit())
+
+	want := map[int][]interface{}{1: {"ann", int64(1)}, 2: {"bob", int64(3)}}
+	if got := rows(t, e, "users"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestMergeChecksUniqueKeys(t *testing.T) {
+	e := newPosts(t)
+	a, b := begin(t, e), begin(t, e)
+	mustSucceed(t)(a.Insert("users", map[string]interface{}{"name": "bob"}))
+	mustSucceed(t)(b.Insert("users", map[string]interface{}{"name": "bob"}))
+	mustSucceed(t)(a.Commit())
+	mustFail(t, "Write conflict with a concurrent transaction")(b.Commit())
+}
+
+func TestMergeChecksForeignKeys(t *testing.T) {
+	post := map[string]interface{}{"author": "ann"}
+
+	t.Run("parent deleted first", func(t *testing.T) {
+		e := newPosts(t)
+		tx := begin(t, e)
+		mustSucceed(t)(tx.Insert("posts", post))
+		mustSucceed(t)(e.Delete("users", map[string]interface{}{"name": "ann"}))
+		mustFail(t, "Write conflict with a concurrent transaction")(tx.Commit())
+	})
+
+	t.Run("child inserted first", func(t *testing.T) {
+		e := newPosts(t)
+		tx := begin(t, e)
+		mustSucceed(t)(tx.Delete("users", map[string]interface{}{"name": "ann"}))
+		mustSucceed(t)(e.Insert("posts", post))
+		mustFail(t, "Write conflict with a concurrent transaction")(tx.Commit())
+		if got := len(rows(t, e, "users")); got != 1 {
+			t.Errorf("got %d users, want 1", got)
+		}
+	})
+
+	t.Run("parent kept", func(t *testing.T) {
+		e := newPosts(t)
+		tx := begin(t, e)
+		mustSucceed(t)(tx.Insert("posts", post))
+		mustSucceed(t)(tx.Insert("posts", map[string]interface{}{"author": nil}))
+		mustSucceed(t)(e.Insert("users", map[string]interface{}{"name": "bob"}))
+		mustSucceed(t)(tx.Commit())
+	})
+}
+
+func TestHistoryPruned(t *testing.T) {
+	e := newUsers(t)
+	old := begin(t, e)
+	mustSucceed(t)(e.Insert("users", nil))
+	mustSucceed(t)(e.Insert("users", nil))
+	recent := begin(t, e)
+	mustSucceed(t)(e.Insert("users", nil))
+	if len(e.history) != 3 {
+		t.Fatalf("got %d changes in the history, want 3", len(e.history))
+	}
+
+	// Only recent still needs the last change
+	mustSucceed(t)(old.Rollback())
+	if len(e.history) != 1 {
+		t.Errorf("after the oldest ended: got %d changes, want 1", len(e.history))
+	}
+	mustSucceed(t)(recent.Commit())
+	if e.history != nil {
+		t.Errorf("after all ended: got %d changes", len(e.history))
+	}
+}
+
+func TestTablesWrittenInPlaceAfterTransactions(t *testing.T) {
+	e := newPosts(t)
+	mustSucceed(t)(e.CreateTable("tags", []client.Column{{Name: "tag", Type: "TEXT"}}, nil))
+	first, second := begin(t, e), begin(t, e)
+	mustSucceed(t)(first.Insert("users", map[string]interface{}{"name": "bob"}))
+	mustSucceed(t)(first.Commit())
+
+	// second still holds the tables as they were
+	before := e.tables["users"]
+	mustSucceed(t)(e.Insert("users", map[string]interface{}{"name": "cid"}))
+	if e.tables["users"] != before {
+		t.Error("a table written by a committed transaction was copied on write")
+	}
+	posts := e.tables["posts"]
+	mustSucceed(t)(e.Insert("posts", map[string]interface{}{"author": "ann"}))
+	if e.tables["posts"] == posts {
+		t.Fatal("a table a snapshot holds was written in place")
+	}
+	if got := ids(mustSucceed(t)(second.SelectAll("posts"))); got != nil {
+		t.Errorf("in the transaction: got posts %v", got)
+	}
+
+	mustSucceed(t)(second.Rollback())
+	for name, table := range e.tables {
+		if table.shared.Load() {
+			t.Errorf("table %s is still shared after every transaction ended", name)
+		}
+	}
+	tags := e.tables["tags"]
+	mustSucceed(t)(e.Insert("tags", map[string]interface{}{"tag": "new"}))
+	if e.tables["tags"] != tags {
+		t.Error("a table no snapshot holds was copied on write")
+	}
+}
+
+func TestConcurrentTransactions(t *testing.T) {
+	const workers, rounds = 8, 25
+	e := NewEngine()
+	mustSucceed(t)(e.CreateTable("counters", []client.Column{{Name: "n", Type: "INT"}}, nil))
+	mustSucceed(t)(e.CreateTable("log", []client.Column{{Name: "worker", Type: "INT"}}, nil))
+	mustSucceed(t)(e.Insert("counters", map[string]interface{}{"n": 0}))
+
+	// Each round adds one to the counter and logs a row, retrying when
+	// another worker committed first
+	increment := func(worker int) error {
+		for {
+			tx, err := e.Begin()
+			if err != nil {
+				return err
+			}
+			resp, err := tx.SelectRows("counters", []int{1})
+			if err != nil {
+				return err
+			}
+			n := resp.Rows[0].Data[0].(int64)
+			if _, err := tx.UpdateRows("counters", []int{1}, map[string]interface{}{"n": n + 1}); err != nil {
+				return err
+			}
+			if _, err := tx.Insert("log", map[string]interface{}{"worker": worker}); err != nil {
+				return err
+			}
+			resp, err = tx.Commit()
+			if err == nil || resp == nil || resp.Message != "Write conflict with a concurrent transaction" {
+				return err
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				if err := increment(w); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	// Readers outside transactions never see a half made commit
+	var reader sync.WaitGroup
+	reader.Add(1)
+	go func() {
+		defer reader.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			counters, err := e.SelectAll("counters")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			logged, err := e.SelectAll("log")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if n := counters.Rows[0].Data[0].(int64); int64(len(logged.Rows)) < n {
+				t.Errorf("counter at %d with %d rows logged", n, len(logged.Rows))
+				return
+			}
+		}
+	}()
+	wg.Wait()
+	close(done)
+	reader.Wait()
+
+	if n := rows(t, e, "counters")[1][0]; n != int64(workers*rounds) {
+		t.Errorf("got counter %v, want %d", n, workers*rounds)
+	}
+	if got := len(rows(t, e, "log")); got != workers*rounds {
+		t.Errorf("got %d rows logged, want %d", got, workers*rounds)
+	}
+	if len(e.txs) != 0 || e.history != nil {
+		t.Errorf("left %d transactions and %d changes", len(e.txs), len(e.history))
+	}
+}
@@ -0,0 +1,313 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"strconv"
+	"weird/db/engine/client"
+)
+
+// record is one logged change: the request that made it, in the shape of
+// the /query protocol, numbered by its log sequence number. Replaying the
+// records in order on the state they were made on repeats every change,
+// IDs included.
+type record struct {
+	LSN         uint64                   `json:"lsn"`
+	Type        string                   `json:"type"`
+	Table       string                   `json:"table"`
+	Columns     []client.Column          `json:"columns,omitempty"`
+	Constraints []client.Constraint      `json:"constraints,omitempty"`
+	IfExists    bool                     `json:"if_exists,omitempty"`
+	Column      *client.Column           `json:"column,omitempty"`
+	NewName     string                   `json:"new_name,omitempty"`
+	Values      map[string]interface{}   `json:"values,omitempty"`
+	Rows        []map[string]interface{} `json:"rows,omitempty"`
+	Set         map[string]interface{}   `json:"set,omitempty"`
+	Where       map[string]interface{}   `json:"where,omitempty"`
+	IDs         []int                    `json:"ids,omitempty"`
+	Index       *client.Index            `json:"index,omitempty"`
+	// Records are the changes of a committed transaction
+	Records []record `json:"records,omitempty"`
+}
+
+// wal is the append-only log of a durable Engine. Each record is one line
+// holding the CRC-32 of its JSON and the JSON itself, so a line cut short
+// by a crash is recognized and dropped on recovery.
+type wal struct {
+	file *os.File
+	size int64
+	// lsn is the sequence number of the last record written
+	lsn    uint64
+	policy SyncPolicy
+	// dirty is set while records have been written but not synced
+	dirty bool
+	// err makes a failed log refuse every later change: the state on disk
+	// no longer matches the one in memory
+	err error
+
+	checkpointSize int64
+	stop           chan struct{}
+	done           chan struct{}
+}
+
+// log records a change about to be made to the tables, before it is
+// made: a transaction's copy stages it, an Engine appends it to its
+// write-ahead log if durable, counts it and remembers what it writes while
+// transactions are open. If log fails the change must not be made. e.mu
+// must be held.
+func (e *Engine) log(rec *record) error {
+	if e.staged != nil {
+		*e.staged = append(*e.staged, *rec)
+		return nil
+	}
+	if e.wal != nil {
+		if err := e.wal.append(rec); err != nil {
+			return err
+		}
+	}
+	e.version++
+	if len(e.txs) > 0 {
+		e.history = append(e.history, change{version: e.version, writes: writesOf([]record{*rec})})
+	}
+	return nil
+}
+
+// unlock releases e.mu after a change, first taking a checkpoint if the
+// log has grown past its size. The change is already safe in the log, so
+// a failed checkpoint is simply retried after the next one.
+func (e *Engine) unlock() {
+	if e.wal != nil && e.wal.checkpointSize > 0 && e.wal.size >= e.wal.checkpointSize {
+		e.checkpointLocked()
+	}
+	e.mu.Unlock()
+}
+
+func (w *wal) append(rec *record) error {
+	if w.err != nil {
+		return w.err
+	}
+	rec.LSN = w.lsn + 1
+	data, err := json.Marshal(rec)
+	if err != nil {
+		// A change that cannot be logged cannot be recovered either
+		w.err = fmt.Errorf("write-ahead log: %w", err)
+		return w.err
+	}
+
+	line := fmt.Sprintf("%08x %s\n", crc32.ChecksumIEEE(data), data)
+	n, err := w.file.WriteString(line)
+	w.size += int64(n)
+	if err != nil {
+		w.err = fmt.Errorf("write-ahead log: %w", err)
+		return w.err
+	}
+	w.lsn = rec.LSN
+	w.dirty = true
+	if w.policy == SyncAlways {
+		return w.sync()
+	}
+	return nil
+}
+
+// sync flushes written records to disk
+func (w *wal) sync() error {
+	if w.err != nil {
+		return w.err
+	}
+	if !w.dirty {
+		return nil
+	}
+	if err := w.file.Sync(); err != nil {
+		w.err = fmt.Errorf("write-ahead log: %w", err)
+		return w.err
+	}
+	w.dirty = false
+	return nil
+}
+
+// reset empties the log once a checkpoint holds every change in it
+func (w *wal) reset() error {
+	if err := w.file.Truncate(0); err != nil {
+		w.err = fmt.Errorf("write-ahead log: %w", err)
+		return w.err
+	}
+	w.size = 0
+	w.dirty = true
+	return w.sync()
+}
+
+// readLog returns the intact records of a log file and the offset where
+// they end. A last line that is incomplete or fails its checksum was cut
+// short by a crash and never acknowledged, so it is left out. A bad line
+// followed by others means the log is damaged, and recovery fails rather
+// than drop the records after it.
+func readLog(path string) ([]record, int64, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	var records []record
+	var offset int64
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// A last line without its newline was cut short
+			return records, offset, nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+
+		rec, ok := parseRecord(line[:len(line)-1])
+		if !ok {
+			if _, err := reader.Peek(1); err == io.EOF {
+				return records, offset, nil
+			}
+			return nil, 0, fmt.Errorf("%s: damaged record at offset %d", path, offset)
+		}
+		records = append(records, rec)
+		offset += int64(len(line))
+	}
+}
+
+func parseRecord(line []byte) (record, bool) {
+	sum, data, ok := bytes.Cut(line, []byte(" "))
+	if !ok {
+		return record{}, false
+	}
+	want, err := strconv.ParseUint(string(sum), 16, 32)
+	if err != nil || uint32(want) != crc32.ChecksumIEEE(data) {
+		return record{}, false
+	}
+
+	var rec record
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&rec); err != nil {
+		return record{}, false
+	}
+	numberRecord(&rec)
+	return rec, true
+}
+
+func numberRecord(rec *record) {
+	rec.Values = numberMap(rec.Values)
+	rec.Set = numberMap(rec.Set)
+	rec.Where = numberMap(rec.Where)
+	for i := range rec.Rows {
+		rec.Rows[i] = numberMap(rec.Rows[i])
+	}
+	for i := range rec.Constraints {
+		rec.Constraints[i].Default = numbers(rec.Constraints[i].Default)
+	}
+	for i := range rec.Records {
+		numberRecord(&rec.Records[i])
+	}
+}
+
+// numbers turns the json.Numbers of a decoded value into int64 or float64,
+// keeping integers that a float64 cannot hold exactly
+func numbers(v interface{}) interface{} {
+	switch x := v.(type) {
+	case json.Number:
+		if n, err := x.Int64(); err == nil {
+			return n
+		}
+		f, _ := x.Float64()
+		return f
+	case map[string]interface{}:
+		return numberMap(x)
+	case []interface{}:
+		for i := range x {
+			x[i] = numbers(x[i])
+		}
+		return x
+	default:
+		return v
+	}
+}
+
+func numberMap(m map[string]interface{}) map[string]interface{} {
+	for key, value := range m {
+		m[key] = numbers(value)
+	}
+	return m
+}
+
+// apply repeats a logged change. The Engine must not have a log of its
+// own yet, or the change would be logged again.
+func (e *Engine) apply(rec record) error {
+	var err error
+	switch rec.Type {
+	case "create_table":
+		_, err = e.CreateTable(rec.Table, rec.Columns, rec.Constraints)
+	case "drop_table":
+		_, err = e.DropTable(rec.Table, rec.IfExists)
+	case "truncate":
+		_, err = e.Truncate(rec.Table)
+	case "add_column":
+		_, err = e.AddColumn(rec.Table, columnOf(rec), rec.Constraints)
+	case "drop_column":
+		_, err = e.DropColumn(rec.Table, columnOf(rec).Name, rec.Constraints)
+	case "rename_column":
+		_, err = e.RenameColumn(rec.Table, columnOf(rec).Name, rec.NewName, rec.Constraints)
+	case "rename_table":
+		_, err = e.RenameTable(rec.Table, rec.NewName)
+	case "create_index":
+		_, err = e.CreateIndex(rec.Table, indexOf(rec))
+	case "drop_index":
+		_, err = e.DropIndex(indexOf(rec).Name, rec.IfExists)
+	case "insert":
+		// Records written before inserts logged their IDs take the next
+		// free one, as they did then
+		id := 0
+		if len(rec.IDs) == 1 {
+			id = rec.IDs[0]
+		}
+		_, err = e.insert(rec.Table, rec.Values, id)
+	case "insert_many":
+		var ids []int
+		if len(rec.IDs) == len(rec.Rows) {
+			ids = rec.IDs
+		}
+		_, err = e.insertMany(rec.Table, rec.Rows, ids)
+	case "update":
+		_, err = e.update(rec.Table, rec.IDs, rec.Set, rec.Where)
+	case "delete":
+		_, err = e.delete(rec.Table, rec.IDs, rec.Where)
+	case "commit":
+		for _, change := range rec.Records {
+			if err = e.apply(change); err != nil {
+				break
+			}
+		}
+	default:
+		err = fmt.Errorf("unknown record type %q", rec.Type)
+	}
+	return err
+}
+
+func columnOf(rec record) client.Column {
+	if rec.Column == nil {
+		return client.Column{}
+	}
+	return *rec.Column
+}
+
+func indexOf(rec record) client.Index {
+	if rec.Index == nil {
+		return client.Index{}
+	}
+	return *rec.Index
+}
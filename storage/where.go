@@ -0,0 +1,65 @@
+package storage
+
+import "weird/db/engine/client"
+
+// matches reports whether a row satisfies every entry of a WHERE map. An
+// entry is a plain value (equality) or a client.Condition; naming a column
+// the table does not have matches nothing.
+func (t *table) matches(data []interface{}, where map[string]interface{}) bool {
+	for key, condition := range where {
+		idx := t.columnIndex(key)
+		if idx < 0 || idx >= len(data) {
+			return false
+		}
+		op, expected := conditionOperands(condition)
+		if !compareValues(op, data[idx], expected) {
+			return false
+		}
+	}
+	return true
+}
+
+// conditionOperands splits a WHERE map entry into its operator and the
+// value to compare with. Conditions decoded from JSON arrive as maps.
+func conditionOperands(condition interface{}) (string, interface{}) {
+	switch c := condition.(type) {
+	case client.Condition:
+		return c.Op, c.Value
+	case *client.Condition:
+		return c.Op, c.Value
+	case map[string]interface{}:
+		if op, ok := c["op"].(string); ok {
+			return op, c["value"]
+		}
+	}
+	return "=", condition
+}
+
+// compareValues applies op to a and b, ordered by client.CompareValues:
+// numbers as numbers, text as text, and values of different kinds by their
+// text, so 30 and "30" are equal. Nothing compares to NULL.
+func compareValues(op string, a, b interface{}) bool {
+	a = client.NormalizeValue(a, "")
+	b = client.NormalizeValue(b, "")
+	if a == nil || b == nil {
+		return false
+	}
+
+	order := client.CompareValues(a, b)
+	switch op {
+	case "=":
+		return order == 0
+	case "!=":
+		return order != 0
+	case "<":
+		return order < 0
+	case ">":
+		return order > 0
+	case "<=":
+		return order <= 0
+	case ">=":
+		return order >= 0
+	default:
+		return false
+	}
+}
@@ -3,26 +3,81 @@ package token
 type TokenType string
 
 const (
-	SELECT_TOKEN = "SELECT"
-	FROM_TOKEN   = "FROM"
-	INSERT_TOKEN = "INSERT"
-	INTO_TOKEN   = "INTO"
-	VALUES_TOKEN = "VALUES"
-	UPDATE_TOKEN = "UPDATE"
-	DELETE_TOKEN = "DELETE"
-	SET_TOKEN    = "SET"
-	WHERE_TOKEN  = "WHERE"
+	SELECT_TOKEN     = "SELECT"
+	FROM_TOKEN       = "FROM"
+	INSERT_TOKEN     = "INSERT"
+	INTO_TOKEN       = "INTO"
+	VALUES_TOKEN     = "VALUES"
+	UPDATE_TOKEN     = "UPDATE"
+	DELETE_TOKEN     = "DELETE"
+	SET_TOKEN        = "SET"
+	WHERE_TOKEN      = "WHERE"
+	CREATE_TOKEN     = "CREATE"
+	TABLE_TOKEN      = "TABLE"
+	DROP_TOKEN       = "DROP"
+	TRUNCATE_TOKEN   = "TRUNCATE"
+	IF_TOKEN         = "IF"
+	EXISTS_TOKEN     = "EXISTS"
+	AND_TOKEN        = "AND"
+	OR_TOKEN         = "OR"
+	NOT_TOKEN        = "NOT"
+	ORDER_TOKEN      = "ORDER"
+	BY_TOKEN         = "BY"
+	ASC_TOKEN        = "ASC"
+	DESC_TOKEN       = "DESC"
+	LIMIT_TOKEN      = "LIMIT"
+	OFFSET_TOKEN     = "OFFSET"
+	GROUP_TOKEN      = "GROUP"
+	HAVING_TOKEN     = "HAVING"
+	JOIN_TOKEN       = "JOIN"
+	INNER_TOKEN      = "INNER"
+	LEFT_TOKEN       = "LEFT"
+	OUTER_TOKEN      = "OUTER"
+	ON_TOKEN         = "ON"
+	TRUE_TOKEN       = "TRUE"
+	FALSE_TOKEN      = "FALSE"
+	NULL_TOKEN       = "NULL"
+	PRIMARY_TOKEN    = "PRIMARY"
+	KEY_TOKEN        = "KEY"
+	UNIQUE_TOKEN     = "UNIQUE"
+	DEFAULT_TOKEN    = "DEFAULT"
+	CHECK_TOKEN      = "CHECK"
+	CONSTRAINT_TOKEN = "CONSTRAINT"
+	FOREIGN_TOKEN    = "FOREIGN"
+	REFERENCES_TOKEN = "REFERENCES"
+	CASCADE_TOKEN    = "CASCADE"
+	RESTRICT_TOKEN   = "RESTRICT"
+	ALTER_TOKEN      = "ALTER"
+	ADD_TOKEN        = "ADD"
+	COLUMN_TOKEN     = "COLUMN"
+	RENAME_TOKEN     = "RENAME"
+	TO_TOKEN         = "TO"
+	SHOW_TOKEN       = "SHOW"
+	TABLES_TOKEN     = "TABLES"
+	DESCRIBE_TOKEN   = "DESCRIBE"
+
+	BEGIN_TOKEN       = "BEGIN"
+	COMMIT_TOKEN      = "COMMIT"
+	ROLLBACK_TOKEN    = "ROLLBACK"
+	TRANSACTION_TOKEN = "TRANSACTION"
+
+	INDEX_TOKEN = "INDEX"
 
 	IDENT_TOKEN  = "IDENT"
 	STRING_TOKEN = "STRING"
 	NUMBER_TOKEN = "NUMBER"
 
-	COMMA_TOKEN     = ","
-	LPAREN_TOKEN    = "("
-	RPAREN_TOKEN    = ")"
-	EQUALS_TOKEN    = "="
-	ENDLINE_TOKEN   = "ENDLINE"
-	SEMICOLON_TOKEN = ";"
+	COMMA_TOKEN      = ","
+	LPAREN_TOKEN     = "("
+	RPAREN_TOKEN     = ")"
+	EQUALS_TOKEN     = "="
+	NOT_EQUALS_TOKEN = "!="
+	LT_TOKEN         = "<"
+	GT_TOKEN         = ">"
+	LTE_TOKEN        = "<="
+	GTE_TOKEN        = ">="
+	ENDLINE_TOKEN    = "ENDLINE"
+	SEMICOLON_TOKEN  = ";"
 )
 
 type Token struct {